@@ -1,20 +1,48 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
 	"fmt"
+	"log"
+	"multilayer/internal/audit"
+	"multilayer/internal/cache"
 	"multilayer/internal/controller"
 	"multilayer/internal/entity"
+	"multilayer/internal/graphqlapi"
+	"multilayer/internal/grpcapi"
+	"multilayer/internal/grpcapi/userv1"
+	"multilayer/internal/migrations"
+	"multilayer/internal/outbox"
+	"multilayer/internal/ratelimit"
 	"multilayer/internal/repository"
 	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"net"
+	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // база часовых поясов для проверки User.Timezone в минимальных образах
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/grpc"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func getDatabaseConnection() (*gorm.DB, error) {
+// getDatabaseConnection подключается к БД из DB_TYPE; для Postgres учетные данные
+// берутся из переменных userEnv и passwordEnv
+func getDatabaseConnection(userEnv, passwordEnv string) (*gorm.DB, error) {
 	dbType := os.Getenv("DB_TYPE")
 	if dbType == "" {
 		dbType = "sqlite" // default to sqlite
@@ -30,11 +58,11 @@ func getDatabaseConnection() (*gorm.DB, error) {
 		if port == "" {
 			port = "5432"
 		}
-		user := os.Getenv("DB_USER")
+		user := os.Getenv(userEnv)
 		if user == "" {
 			user = "postgres"
 		}
-		password := os.Getenv("DB_PASSWORD")
+		password := os.Getenv(passwordEnv)
 		if password == "" {
 			password = "password"
 		}
@@ -54,29 +82,484 @@ func getDatabaseConnection() (*gorm.DB, error) {
 		if dbPath == "" {
 			dbPath = "test.db"
 		}
-		return gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+		// Внешние ключи в SQLite включаются для каждого соединения
+		separator := "?"
+		if strings.Contains(dbPath, "?") {
+			separator = "&"
+		}
+		return gorm.Open(sqlite.Open(dbPath+separator+"_foreign_keys=1"), &gorm.Config{})
+	}
+}
+
+// newOutboxSink выбирает получателя событий outbox по OUTBOX_SINK (log, file, webhook, nats, kafka)
+func newOutboxSink() (outbox.Sink, error) {
+	switch sink := os.Getenv("OUTBOX_SINK"); sink {
+	case "", "log":
+		return outbox.NewLogSink(), nil
+	case "file":
+		path := os.Getenv("OUTBOX_FILE_PATH")
+		if path == "" {
+			path = "outbox.ndjson"
+		}
+		return outbox.NewFileSink(path), nil
+	case "webhook":
+		url := os.Getenv("OUTBOX_WEBHOOK_URL")
+		if url == "" {
+			return nil, fmt.Errorf("OUTBOX_WEBHOOK_URL is required for webhook sink")
+		}
+		return outbox.NewWebhookSink(url), nil
+	case "nats":
+		url := os.Getenv("OUTBOX_NATS_URL")
+		if url == "" {
+			url = nats.DefaultURL
+		}
+		subject := os.Getenv("OUTBOX_NATS_SUBJECT")
+		if subject == "" {
+			subject = "users"
+		}
+		conn, err := nats.Connect(url)
+		if err != nil {
+			return nil, err
+		}
+		return outbox.NewNATSSink(conn, subject)
+	case "kafka":
+		brokers := os.Getenv("OUTBOX_KAFKA_BROKERS")
+		if brokers == "" {
+			brokers = "localhost:9092"
+		}
+		topic := os.Getenv("OUTBOX_KAFKA_TOPIC")
+		if topic == "" {
+			topic = "users"
+		}
+		return outbox.NewKafkaSink(strings.Split(brokers, ","), topic), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox sink %q", sink)
 	}
 }
 
+// newUserCache оборачивает репозиторий кэшем пользователей по ID. USER_CACHE_REDIS_ADDR
+// включает общий для экземпляров кэш в Redis (USER_CACHE_REDIS_PASSWORD, USER_CACHE_REDIS_DB),
+// иначе USER_CACHE_SIZE > 0 - LRU в памяти процесса. USER_CACHE_TTL - срок жизни записи
+// (по умолчанию минута). Без настроек кэш выключен и возвращается nil
+func newUserCache(repo repository.UserRepositoryInterface) (*repository.CachedUserRepository, error) {
+	ttl := time.Minute
+	if value := os.Getenv("USER_CACHE_TTL"); value != "" {
+		var err error
+		if ttl, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid USER_CACHE_TTL: %w", err)
+		}
+	}
+
+	if addr := os.Getenv("USER_CACHE_REDIS_ADDR"); addr != "" {
+		config := cache.RedisConfig{Addr: addr, Password: os.Getenv("USER_CACHE_REDIS_PASSWORD")}
+		if value := os.Getenv("USER_CACHE_REDIS_DB"); value != "" {
+			db, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid USER_CACHE_REDIS_DB: %w", err)
+			}
+			config.DB = db
+		}
+		return repository.NewCachedUserRepository(repo, cache.NewRedisStore(config), ttl), nil
+	}
+
+	size, _ := strconv.Atoi(os.Getenv("USER_CACHE_SIZE"))
+	if size <= 0 {
+		return nil, nil
+	}
+	return repository.NewCachedUserRepository(repo, cache.NewLRU(size), ttl), nil
+}
+
+// rateLimits - лимиты запросов из окружения; нулевой лимит выключен
+type rateLimits struct {
+	limiter      *controller.RateLimiter
+	ip           ratelimit.Limit
+	apiKey       ratelimit.Limit
+	apiKeyHeader string
+	signup       ratelimit.Limit
+	availability ratelimit.Limit
+}
+
+// rateLimitsFromEnv читает лимиты вида "100/1m": RATE_LIMIT_IP - на адрес клиента,
+// RATE_LIMIT_API_KEY - на ключ из заголовка RATE_LIMIT_API_KEY_HEADER (по умолчанию
+// X-API-Key), RATE_LIMIT_SIGNUP - запросов, создающих пользователей, с одного
+// адреса по всем протоколам (по умолчанию 20/1h, "off" выключает),
+// RATE_LIMIT_AVAILABILITY - проверок занятости, поиска и выгрузки пользователей
+// с одного адреса, общий для этих маршрутов; поиск по списку расходует по токену
+// на идентификатор (по умолчанию 30/1m, "off" выключает). RATE_LIMIT_ALLOWLIST -
+// адреса и подсети внутренних сервисов через запятую, их запросы не ограничиваются
+func rateLimitsFromEnv() (rateLimits, error) {
+	limits := rateLimits{apiKeyHeader: os.Getenv("RATE_LIMIT_API_KEY_HEADER")}
+	if limits.apiKeyHeader == "" {
+		limits.apiKeyHeader = "X-API-Key"
+	}
+
+	signup := os.Getenv("RATE_LIMIT_SIGNUP")
+	if signup == "" {
+		signup = "20/1h"
+	}
+	availability := os.Getenv("RATE_LIMIT_AVAILABILITY")
+	if availability == "" {
+		availability = "30/1m"
+	}
+	for _, setting := range []struct {
+		env   string
+		value string
+		limit *ratelimit.Limit
+	}{
+		{"RATE_LIMIT_IP", os.Getenv("RATE_LIMIT_IP"), &limits.ip},
+		{"RATE_LIMIT_API_KEY", os.Getenv("RATE_LIMIT_API_KEY"), &limits.apiKey},
+		{"RATE_LIMIT_SIGNUP", signup, &limits.signup},
+		{"RATE_LIMIT_AVAILABILITY", availability, &limits.availability},
+	} {
+		if setting.value == "" || setting.value == "off" {
+			continue
+		}
+		limit, err := ratelimit.ParseLimit(setting.value)
+		if err != nil {
+			return limits, fmt.Errorf("invalid %s: %w", setting.env, err)
+		}
+		*setting.limit = limit
+	}
+
+	var allowlist []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv("RATE_LIMIT_ALLOWLIST"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return limits, fmt.Errorf("invalid RATE_LIMIT_ALLOWLIST entry %q", entry)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		allowlist = append(allowlist, prefix)
+	}
+	limits.limiter = controller.NewRateLimiter(ratelimit.NewMemoryStore(), allowlist...)
+	return limits, nil
+}
+
+// proxyConfig читает адрес клиента за балансировщиком: PROXY_HEADER (например,
+// X-Real-IP) - заголовок с адресом клиента для лимитов запросов, TRUSTED_PROXIES -
+// адреса и подсети балансировщиков через запятую. Заголовок учитывается только
+// в запросах от них, иначе клиент подставил бы в него любой адрес и обошел
+// лимиты; поэтому PROXY_HEADER без TRUSTED_PROXIES - ошибка. Балансировщик
+// должен перезаписывать заголовок, а не дописывать к присланному клиентом
+func proxyConfig() (fiber.Config, error) {
+	// Проверка включена всегда: без нее Fiber верит X-Forwarded-Host и
+	// X-Forwarded-Proto любого клиента, а по хосту определяется тенант
+	config := fiber.Config{ProxyHeader: os.Getenv("PROXY_HEADER"), EnableTrustedProxyCheck: true}
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(entry); err != nil {
+			if _, err := netip.ParseAddr(entry); err != nil {
+				return config, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", entry)
+			}
+		}
+		config.TrustedProxies = append(config.TrustedProxies, entry)
+	}
+	if config.ProxyHeader != "" && len(config.TrustedProxies) == 0 {
+		return config, errors.New("PROXY_HEADER requires TRUSTED_PROXIES")
+	}
+	return config, nil
+}
+
+// emailCanonicalization читает политику сравнения email из окружения:
+// EMAIL_CASE_SENSITIVE_LOCAL_PART, EMAIL_IGNORE_PLUS_TAG и EMAIL_IGNORE_DOTS_DOMAINS (через запятую)
+func emailCanonicalization() *entity.EmailCanonicalization {
+	policy := &entity.EmailCanonicalization{}
+	policy.CaseSensitiveLocalPart, _ = strconv.ParseBool(os.Getenv("EMAIL_CASE_SENSITIVE_LOCAL_PART"))
+	policy.IgnorePlusTag, _ = strconv.ParseBool(os.Getenv("EMAIL_IGNORE_PLUS_TAG"))
+	if domains := os.Getenv("EMAIL_IGNORE_DOTS_DOMAINS"); domains != "" {
+		policy.IgnoreDotsDomains = strings.Split(domains, ",")
+	}
+	return policy
+}
+
+// usernamePolicy собирает политику username из окружения: USERNAME_MIN_LENGTH,
+// USERNAME_MAX_LENGTH, USERNAME_ALLOWED_CLASSES (например "letters,digits,underscores"),
+// USERNAME_RESERVED - дополнительные имена через запятую, USERNAME_BLOCKLIST_FILE -
+// файл запрещенных слов по одному в строке ("*" перед словом - искать как подстроку)
+func usernamePolicy() (*entity.UsernamePolicy, error) {
+	policy := entity.DefaultUsernamePolicy()
+	if value := os.Getenv("USERNAME_MIN_LENGTH"); value != "" {
+		minLength, err := strconv.Atoi(value)
+		if err != nil || minLength < 1 {
+			return nil, fmt.Errorf("invalid USERNAME_MIN_LENGTH %q", value)
+		}
+		policy.MinLength = minLength
+	}
+	if value := os.Getenv("USERNAME_MAX_LENGTH"); value != "" {
+		maxLength, err := strconv.Atoi(value)
+		if err != nil || maxLength < policy.MinLength {
+			return nil, fmt.Errorf("invalid USERNAME_MAX_LENGTH %q", value)
+		}
+		policy.MaxLength = maxLength
+	}
+	if value := os.Getenv("USERNAME_ALLOWED_CLASSES"); value != "" {
+		classes, ok := entity.ParseCharacterClasses(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid USERNAME_ALLOWED_CLASSES %q", value)
+		}
+		policy.AllowedClasses = classes
+	}
+	if reserved := os.Getenv("USERNAME_RESERVED"); reserved != "" {
+		policy.Reserve(strings.Split(reserved, ",")...)
+	}
+	if path := os.Getenv("USERNAME_BLOCKLIST_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		words, err := entity.ReadWordList(file)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		policy.Block(words...)
+	}
+	return policy, nil
+}
+
+// emailValidator собирает проверки email из окружения: EMAIL_CHECK_MX включает
+// DNS-проверку домена, EMAIL_ALLOW_DISPOSABLE снимает запрет одноразовой почты,
+// EMAIL_DISPOSABLE_FILE дополняет список таких доменов, EMAIL_ALLOWED_DOMAINS и
+// EMAIL_DENIED_DOMAINS - домены через запятую для всего развертывания; списки
+// отдельных тенантов хранятся в тенанте (PUT /tenants/:slug/email-domains)
+func emailValidator(tenantDomains service.EmailDomainSource) (*service.EmailValidator, error) {
+	validator := service.NewEmailValidator()
+	validator.TenantDomains = tenantDomains
+	if checkMX, _ := strconv.ParseBool(os.Getenv("EMAIL_CHECK_MX")); checkMX {
+		validator.Resolver = net.DefaultResolver
+	}
+	if allow, _ := strconv.ParseBool(os.Getenv("EMAIL_ALLOW_DISPOSABLE")); allow {
+		validator.AllowDisposable()
+	} else if path := os.Getenv("EMAIL_DISPOSABLE_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		domains, err := entity.ReadWordList(file)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		validator.BlockDisposable(domains...)
+	}
+	if domains := os.Getenv("EMAIL_ALLOWED_DOMAINS"); domains != "" {
+		validator.AllowedDomains = strings.Split(domains, ",")
+	}
+	if domains := os.Getenv("EMAIL_DENIED_DOMAINS"); domains != "" {
+		validator.DeniedDomains = strings.Split(domains, ",")
+	}
+	return validator, nil
+}
+
+// tenantResolution описывает, как определяется тенант запроса
+type tenantResolution struct {
+	required bool
+	sources  []controller.TenantSource
+	verifier *tenancy.TokenVerifier // для gRPC и проверки пользователя из sub; nil, если токены не настроены
+	// authRequired - запросы без токена отклоняются
+	authRequired bool
+	// vary - заголовки запроса, от которых зависит тенант и пользователь
+	vary []string
+}
+
+// tenantResolutionFromEnv читает настройки тенантов: TENANT_REQUIRED отклоняет
+// запросы без тенанта (иначе они обслуживаются в тенанте по умолчанию),
+// TENANT_TOKEN_SECRET и TENANT_TOKEN_CLAIM (по умолчанию tenant) - проверка claim
+// токена Bearer. Тот же токен с claim sub проверяет статус пользователя, а с
+// ролью admin в claim roles открывает административные маршруты. Запросы без
+// токена проходят как анонимные; AUTH_REQUIRED отклоняет их, и тогда токены
+// обязательны к настройке. С токенами тенант берется только из claim; без них -
+// из заголовка TENANT_HEADER (по умолчанию X-Tenant) и поддомена
+// TENANT_BASE_DOMAIN, их клиент задает сам
+func tenantResolutionFromEnv() (tenantResolution, error) {
+	var resolution tenantResolution
+	resolution.required, _ = strconv.ParseBool(os.Getenv("TENANT_REQUIRED"))
+	resolution.authRequired, _ = strconv.ParseBool(os.Getenv("AUTH_REQUIRED"))
+	if resolution.authRequired && os.Getenv("TENANT_TOKEN_SECRET") == "" {
+		return resolution, errors.New("AUTH_REQUIRED requires TENANT_TOKEN_SECRET")
+	}
+
+	if secret := os.Getenv("TENANT_TOKEN_SECRET"); secret != "" {
+		claim := os.Getenv("TENANT_TOKEN_CLAIM")
+		if claim == "" {
+			claim = "tenant"
+		}
+		resolution.verifier = &tenancy.TokenVerifier{Secret: []byte(secret), Claim: claim}
+		resolution.sources = append(resolution.sources, controller.TenantFromToken(resolution.verifier))
+		resolution.vary = []string{fiber.HeaderAuthorization}
+		return resolution, nil
+	}
+
+	header := os.Getenv("TENANT_HEADER")
+	if header == "" {
+		header = "X-Tenant"
+	}
+	resolution.sources = append(resolution.sources, controller.TenantFromHeader(header))
+	// Поддомен входит в URL и отдельного Vary не требует
+	resolution.vary = []string{header}
+	if baseDomain := os.Getenv("TENANT_BASE_DOMAIN"); baseDomain != "" {
+		resolution.sources = append(resolution.sources, controller.TenantFromSubdomain(baseDomain))
+	}
+	return resolution, nil
+}
+
 func main() {
+	emailPolicy := emailCanonicalization()
+
 	// Инициализация БД
-	db, err := getDatabaseConnection()
+	db, err := getDatabaseConnection("DB_USER", "DB_PASSWORD")
 	if err != nil {
 		panic("failed to connect database: " + err.Error())
 	}
 
-	err = db.AutoMigrate(&entity.User{})
+	// DB_ROW_LEVEL_SECURITY включает изоляцию тенантов политиками Postgres. Им не
+	// подчиняется владелец таблиц, поэтому приложение подключается ролью без прав
+	// владельца, а схему меняет роль из DB_MIGRATION_USER и DB_MIGRATION_PASSWORD
+	rowLevelSecurity, _ := strconv.ParseBool(os.Getenv("DB_ROW_LEVEL_SECURITY"))
+	if rowLevelSecurity && db.Dialector.Name() != "postgres" {
+		panic("DB_ROW_LEVEL_SECURITY requires DB_TYPE=postgres")
+	}
+	migrationDB := db
+	if os.Getenv("DB_MIGRATION_USER") != "" {
+		migrationDB, err = getDatabaseConnection("DB_MIGRATION_USER", "DB_MIGRATION_PASSWORD")
+		if err != nil {
+			panic("failed to connect database for migrations: " + err.Error())
+		}
+	}
+
+	err = migrationDB.AutoMigrate(&entity.Tenant{}, &entity.User{}, &outbox.Message{}, &entity.UserAuditEntry{}, &entity.UserVersion{}, &entity.WebhookSubscription{}, &entity.WebhookDelivery{},
+		&entity.Organization{}, &entity.Team{}, &entity.OrganizationMember{}, &entity.TeamMember{}, &entity.IdempotencyRecord{})
 	if err != nil {
 		panic("failed to migrate database: " + err.Error())
 	}
+	// Версионные миграции данных (заполнение новых колонок и т.п.)
+	if err := migrations.Run(migrationDB, migrations.All); err != nil {
+		panic("failed to run migrations: " + err.Error())
+	}
+	// Ключи уникальности email вычислены по примененной к базе политике; смена
+	// политики пересчитывает их только с EMAIL_REKEY=true
+	rekey, _ := strconv.ParseBool(os.Getenv("EMAIL_REKEY"))
+	if err := migrations.ApplyEmailPolicy(migrationDB, emailPolicy, rekey); err != nil {
+		panic("failed to apply email policy: " + err.Error())
+	}
+	if err := migrations.ApplyRowLevelSecurity(migrationDB, rowLevelSecurity); err != nil {
+		panic("failed to apply row level security: " + err.Error())
+	}
+	if migrationDB != db {
+		if sqlDB, err := migrationDB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
 
 	// Инициализация слоёв
+	tenantService := service.NewTenantService(repository.NewTenantRepository(db))
+	tenantController := controller.NewTenantController(tenantService)
+	tenants, err := tenantResolutionFromEnv()
+	if err != nil {
+		panic("failed to configure authentication: " + err.Error())
+	}
 	userRepo := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepo)
+	userRepo.RowLevelSecurity = rowLevelSecurity
+	userRepo.EmailPolicy = emailPolicy
+	userRepo.Events = repository.EventRecorders{outbox.NewStore(), audit.NewRecorder(), audit.NewVersionRecorder()}
+	var users repository.UserRepositoryInterface = userRepo
+	userCache, err := newUserCache(userRepo)
+	if err != nil {
+		panic("failed to configure user cache: " + err.Error())
+	}
+	if userCache != nil {
+		users = userCache
+		// Счетчики попаданий в кэш доступны в /debug/vars на DEBUG_ADDR
+		expvar.Publish("user_cache", expvar.Func(func() any { return userCache.Stats() }))
+	}
+	userService := service.NewUserService(users)
+	// Чтение и изменение пользователя выполняются в одной транзакции. В Postgres -
+	// с изоляцией REPEATABLE READ: параллельное изменение той же строки прерывает
+	// транзакцию, и TxManager повторяет ее вместо потери обновления
+	transactions := repository.NewTxManager(db)
+	if db.Dialector.Name() == "postgres" {
+		transactions.Options = &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+	}
+	userService.Transactions = transactions
+	userService.UsernamePolicy, err = usernamePolicy()
+	if err != nil {
+		panic("failed to configure username policy: " + err.Error())
+	}
+	userService.EmailValidator, err = emailValidator(tenantService)
+	if err != nil {
+		panic("failed to configure email validation: " + err.Error())
+	}
 	userController := controller.NewUserController(userService)
+	userController.Vary = tenants.vary
+	organizationRepo := repository.NewOrganizationRepository(db)
+	organizationRepo.RowLevelSecurity = rowLevelSecurity
+	organizationController := controller.NewOrganizationController(service.NewOrganizationService(organizationRepo, users))
+	teamRepo := repository.NewTeamRepository(db)
+	teamRepo.RowLevelSecurity = rowLevelSecurity
+	teamController := controller.NewTeamController(service.NewTeamService(teamRepo, organizationRepo))
+	// WEBHOOK_ALLOW_PRIVATE_DESTINATIONS разрешает вебхуки на локальные адреса (разработка)
+	allowPrivateWebhooks, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE_DESTINATIONS"))
+	webhookRepo := repository.NewWebhookRepository(db)
+	webhookRepo.RowLevelSecurity = rowLevelSecurity
+	webhookService := service.NewWebhookService(webhookRepo, service.WebhookConfig{AllowPrivateDestinations: allowPrivateWebhooks})
+	webhookController := controller.NewWebhookController(webhookService)
+
+	// Запускаем релей, публикующий события из outbox
+	outboxSink, err := newOutboxSink()
+	if err != nil {
+		panic("failed to configure outbox sink: " + err.Error())
+	}
+	pollInterval, _ := time.ParseDuration(os.Getenv("OUTBOX_POLL_INTERVAL"))
+	// Фоновые воркеры останавливаются по SIGINT/SIGTERM вместе с серверами
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	sinks := outbox.MultiSink{outboxSink, webhookService}
+	relay := outbox.NewRelay(db, sinks, outbox.RelayConfig{PollInterval: pollInterval, RowLevelSecurity: rowLevelSecurity})
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(ctx)
+	}()
+
+	// Ответы на запросы с Idempotency-Key хранятся IDEMPOTENCY_TTL (по умолчанию сутки)
+	idempotencyTTL, _ := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	idempotencyRepo.RowLevelSecurity = rowLevelSecurity
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, service.IdempotencyConfig{TTL: idempotencyTTL})
+	go idempotencyService.RunCleanup(ctx, time.Hour)
+
+	// Запускаем воркер доставки вебхуков
+	webhookInterval, _ := time.ParseDuration(os.Getenv("WEBHOOK_POLL_INTERVAL"))
+	if webhookInterval <= 0 {
+		webhookInterval = time.Second
+	}
+	go webhookService.RunDeliveryWorker(ctx, webhookInterval)
+
+	limits, err := rateLimitsFromEnv()
+	if err != nil {
+		panic("failed to configure rate limits: " + err.Error())
+	}
 
-	// Создаем Fiber приложение
-	app := fiber.New()
+	// Создаем Fiber приложение. Тела читаются потоком: импорт не ограничен по
+	// размеру, остальные маршруты получают тело в памяти не больше
+	// fiber.DefaultBodyLimit
+	config, err := proxyConfig()
+	if err != nil {
+		panic("failed to configure proxies: " + err.Error())
+	}
+	config.EnableIPValidation = true
+	config.StreamRequestBody = true
+	app := fiber.New(config)
+	app.Use(controller.BufferBody(fiber.DefaultBodyLimit, "/admin/users:import"))
 
 	// Health check endpoint для Kubernetes
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -86,10 +569,155 @@ func main() {
 		})
 	})
 
+	// ID запроса и инициатор изменения попадают в журнал аудита
+	app.Use(requestid.New())
+	app.Use(controller.RequestMetadata)
+	app.Use(limits.limiter.Limit("ip", limits.ip, controller.ByIP))
+	app.Use(limits.limiter.Limit("api_key", limits.apiKey, controller.ByAPIKey(limits.apiKeyHeader)))
+
+	// Управление тенантами - только с ролью platform_admin: роль admin выдается
+	// в токенах тенанта и не должна открывать чужие тенанты. Регистрируется до
+	// middleware тенанта
+	requirePlatformAdmin := controller.RequireRole(tenants.verifier, tenancy.PlatformAdminRole)
+	app.Post("/tenants", requirePlatformAdmin, tenantController.CreateTenant)
+	app.Get("/tenants", requirePlatformAdmin, tenantController.ListTenants)
+	app.Put("/tenants/:slug/email-domains", requirePlatformAdmin, tenantController.SetEmailDomains)
+
+	if tenants.authRequired {
+		// Все маршруты ниже доступны только с токеном
+		app.Use(controller.RequireToken(tenants.verifier))
+	}
+	// Все маршруты ниже работают с данными тенанта запроса
+	app.Use(controller.ResolveTenant(tenantService, tenants.required, tenants.sources...))
+	if tenants.verifier != nil {
+		// Заблокированные и деактивированные пользователи отклоняются до обработчиков
+		app.Use(controller.Authenticate(userService, tenants.verifier))
+	}
+	// Ключи идемпотентности уникальны для пользователя из токена, без него - для
+	// ключа API или адреса клиента
+	app.Use(controller.Idempotency(idempotencyService, controller.CallerByActor(limits.apiKeyHeader)))
+
+	// Маршруты, по ответам которых можно узнать, зарегистрирован ли username или
+	// email, делят один лимит: иначе перебор переходил бы на соседний маршрут.
+	// Поиск по списку расходует токен на каждый идентификатор, GraphQL users -
+	// на каждое поле (ниже)
+	enumerationLimit := limits.limiter.Limit("availability", limits.availability, controller.ByIP)
+	userController.LookupLimit = func(ctx *fiber.Ctx, identifiers int) bool {
+		return limits.limiter.AllowN(ctx, "availability", limits.availability, controller.ByIP, identifiers)
+	}
+
+	// Все запросы, создающие пользователей, делят лимит регистраций - в том числе
+	// GraphQL registerUser и gRPC RegisterUser ниже; пакетные операции и импорт
+	// расходуют один токен на запрос
+	signupLimit := limits.limiter.Limit("signup", limits.signup, controller.ByIP)
+
 	// Настраиваем роуты
-	app.Post("/users", userController.Register)
-	app.Get("/users/:id", userController.GetUser)
+	app.Post("/users", signupLimit, userController.Register)
+	app.Post("/users\\:lookup", userController.LookupUsers)
+	app.Get("/users\\:export", enumerationLimit, userController.ExportUsers)
+	// /users/search и другие фиксированные пути регистрируются до /users/:id, иначе сегмент примется за ID
+	app.Get("/users/search", enumerationLimit, userController.SearchUsers)
+	app.Get("/users/availability", enumerationLimit, userController.CheckAvailability)
+	app.Get("/users/by-username/:username", enumerationLimit, userController.GetUserByUsername)
+	app.Get("/users/by-email/:email", enumerationLimit, userController.GetUserByEmail)
+	app.Put("/users/by-email/:email", signupLimit, enumerationLimit, userController.UpsertUserByEmail)
+	app.Get("/users/:id", userController.GetUser) // ?as_of=<RFC3339> - состояние на момент времени
 	app.Put("/users/:id", userController.UpdateUser)
+	app.Put("/users/:id/profile", userController.UpdateProfile)
+	app.Get("/users/:id/history", userController.GetUserHistory)
+	app.Get("/users/:id/memberships", organizationController.ListUserMemberships)
+
+	// Административные операции - только с ролью admin в claim roles токена
+	requireAdmin := controller.RequireAdmin(tenants.verifier)
+	admin := app.Group("/admin", requireAdmin)
+	admin.Post("/users/:id/status", userController.ChangeUserStatus)
+	admin.Post("/users\\:import", signupLimit, userController.ImportUsers)
+	admin.Post("/users\\:batch", signupLimit, userController.BatchUsers)
+
+	app.Post("/organizations", organizationController.CreateOrganization)
+	app.Get("/organizations", organizationController.ListOrganizations)
+	app.Get("/organizations/:id", organizationController.GetOrganization)
+	app.Delete("/organizations/:id", organizationController.DeleteOrganization) // 409, пока у организации есть команды
+	app.Get("/organizations/:id/members", organizationController.ListMembers)
+	app.Post("/organizations/:id/members", organizationController.AddMember)
+	app.Delete("/organizations/:id/members/:userId", organizationController.RemoveMember)
+	app.Post("/organizations/:id/teams", teamController.CreateTeam)
+	app.Get("/organizations/:id/teams", teamController.ListTeams)
+	app.Delete("/organizations/:id/teams/:teamId", teamController.DeleteTeam)
+	app.Get("/organizations/:id/teams/:teamId/members", teamController.ListMembers)
+	app.Post("/organizations/:id/teams/:teamId/members", teamController.AddMember)
+	app.Delete("/organizations/:id/teams/:teamId/members/:userId", teamController.RemoveMember)
+
+	// Подписка получает все события пользователей тенанта: управление ими - только
+	// с ролью admin
+	webhooks := app.Group("/webhooks", requireAdmin)
+	webhooks.Post("", webhookController.CreateSubscription)
+	webhooks.Get("", webhookController.ListSubscriptions)
+	webhooks.Get("/:id", webhookController.GetSubscription)
+	webhooks.Delete("/:id", webhookController.DeleteSubscription)
+	webhooks.Get("/:id/deliveries", webhookController.ListDeliveries)
+	webhooks.Post("/:id/deliveries/:deliveryId/redeliver", webhookController.Redeliver)
+
+	// GraphQL endpoint; лимит сложности запроса настраивается через окружение
+	maxComplexity, _ := strconv.Atoi(os.Getenv("GRAPHQL_MAX_COMPLEXITY"))
+	graphqlHandler, err := graphqlapi.NewHandler(userService, maxComplexity)
+	if err != nil {
+		panic("failed to build graphql schema: " + err.Error())
+	}
+	graphqlHandler.FieldLimits = map[string]graphqlapi.FieldLimit{
+		"registerUser": func(ctx *fiber.Ctx) bool {
+			return limits.limiter.Allow(ctx, "signup", limits.signup, controller.ByIP)
+		},
+		"users": func(ctx *fiber.Ctx) bool {
+			return limits.limiter.Allow(ctx, "availability", limits.availability, controller.ByIP)
+		},
+	}
+	app.Get("/graphql", graphqlHandler.Serve)
+	app.Post("/graphql", graphqlHandler.Serve)
+
+	// Запускаем gRPC сервер на отдельном порту
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	grpcListener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		panic("failed to listen for grpc: " + err.Error())
+	}
+	interceptors := []grpc.UnaryServerInterceptor{grpcapi.TenantInterceptor(tenantService, tenants.required, tenants.verifier)}
+	if tenants.authRequired {
+		interceptors = append([]grpc.UnaryServerInterceptor{grpcapi.RequireTokenInterceptor(tenants.verifier)}, interceptors...)
+	}
+	if tenants.verifier != nil {
+		interceptors = append(interceptors, grpcapi.AuthInterceptor(userService, tenants.verifier))
+	}
+	interceptors = append(interceptors, grpcapi.AdminInterceptor(tenants.verifier))
+	interceptors = append(interceptors, grpcapi.RateLimitInterceptor(map[string]grpcapi.MethodLimit{
+		userv1.UserService_RegisterUser_FullMethodName: func(ctx context.Context, addr string) bool {
+			return limits.limiter.AllowAddr(ctx, "signup", limits.signup, addr)
+		},
+	}))
+	grpcServer := grpcapi.NewServer(userService, grpc.ChainUnaryInterceptor(interceptors...))
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			panic("failed to start grpc server: " + err.Error())
+		}
+	}()
+
+	// Отладочные счетчики (/debug/vars) не публикуются на основном порту:
+	// отдельный адрес, по умолчанию доступный только с самого хоста
+	debugAddr := os.Getenv("DEBUG_ADDR")
+	if debugAddr == "" {
+		debugAddr = "127.0.0.1:6060"
+	}
+	debugMux := http.NewServeMux()
+	debugMux.Handle("/debug/vars", expvar.Handler())
+	debugServer := &http.Server{Addr: debugAddr, Handler: debugMux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("debug server: %v", err)
+		}
+	}()
 
 	// Получаем порт из переменной окружения
 	port := os.Getenv("PORT")
@@ -97,9 +725,27 @@ func main() {
 		port = "8080"
 	}
 
+	go func() {
+		<-ctx.Done()
+		if err := app.Shutdown(); err != nil {
+			log.Printf("http shutdown: %v", err)
+		}
+		grpcServer.GracefulStop()
+		if err := debugServer.Close(); err != nil {
+			log.Printf("debug shutdown: %v", err)
+		}
+	}()
+
 	// Запускаем сервер
 	err = app.Listen(":" + port)
 	if err != nil {
 		panic("failed to start server: " + err.Error())
 	}
+
+	// Дожидаемся релея, чтобы не закрыть соединения посреди публикации
+	stop()
+	<-relayDone
+	if err := sinks.Close(); err != nil {
+		log.Printf("close outbox sinks: %v", err)
+	}
 }
@@ -0,0 +1,89 @@
+// (Журнал аудита изменений пользователей)
+package audit
+
+import (
+	"context"
+	"multilayer/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// SystemActor записывается, если изменение выполнено вне запроса (фоновые задачи)
+	SystemActor = "system"
+	// AnonymousActor записывается, если запрос не аутентифицирован
+	AnonymousActor = "anonymous"
+	// UserActorPrefix предшествует claim sub проверенного токена: "user:42"
+	UserActorPrefix = "user:"
+)
+
+type metadataKey struct{}
+
+// Metadata описывает, кто и в рамках какого запроса изменяет данные
+type Metadata struct {
+	Actor     string
+	RequestID string
+}
+
+// WithMetadata кладет метаданные запроса в контекст
+func WithMetadata(ctx context.Context, metadata Metadata) context.Context {
+	if metadata.Actor == "" {
+		metadata.Actor = AnonymousActor
+	}
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+// WithActor заменяет инициатора в метаданных контекста, сохраняя ID запроса.
+// Вызывается после проверки токена: инициатор берется только из claim sub
+func WithActor(ctx context.Context, subject string) context.Context {
+	metadata := FromContext(ctx)
+	metadata.Actor = UserActorPrefix + subject
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+// FromContext возвращает метаданные запроса или SystemActor, если их нет
+func FromContext(ctx context.Context) Metadata {
+	if ctx != nil {
+		if metadata, ok := ctx.Value(metadataKey{}).(Metadata); ok {
+			return metadata
+		}
+	}
+	return Metadata{Actor: SystemActor}
+}
+
+// Recorder пишет запись аудита на каждое событие пользователя. Метаданные
+// берутся из контекста транзакции, поэтому репозиторий должен открывать ее
+// через DB.WithContext
+type Recorder struct{}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record сохраняет запись через переданную транзакцию; обновление без
+// изменившихся полей в журнал не попадает
+func (r *Recorder) Record(tx *gorm.DB, event entity.UserEvent) error {
+	var changes []entity.FieldChange
+	switch event.Type {
+	case entity.UserRegistered:
+		changes = entity.DiffUsers(nil, &event.User)
+	case entity.UserDeleted:
+		changes = entity.DiffUsers(&event.User, nil)
+	default:
+		changes = entity.DiffUsers(event.Previous, &event.User)
+		if len(changes) == 0 {
+			return nil
+		}
+	}
+
+	metadata := FromContext(tx.Statement.Context)
+	return tx.Create(&entity.UserAuditEntry{
+		TenantID:  event.User.TenantID,
+		UserID:    event.User.ID,
+		Action:    event.Type,
+		Actor:     metadata.Actor,
+		RequestID: metadata.RequestID,
+		Changes:   changes,
+		CreatedAt: event.OccurredAt,
+	}).Error
+}
@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"multilayer/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+// VersionRecorder ведет историю версий пользователя: каждое изменение закрывает
+// текущую версию и открывает новую с момента события
+type VersionRecorder struct{}
+
+func NewVersionRecorder() *VersionRecorder {
+	return &VersionRecorder{}
+}
+
+// Record сохраняет версию через переданную транзакцию; обновление без
+// изменившихся полей новой версии не создает
+func (r *VersionRecorder) Record(tx *gorm.DB, event entity.UserEvent) error {
+	if event.Type == entity.UserUpdated && len(entity.DiffUsers(event.Previous, &event.User)) == 0 {
+		return nil
+	}
+
+	err := tx.Model(&entity.UserVersion{}).
+		Where("user_id = ? AND valid_to IS NULL", event.User.ID).
+		Update("valid_to", event.OccurredAt).Error
+	if err != nil || event.Type == entity.UserDeleted {
+		return err
+	}
+
+	return tx.Create(&entity.UserVersion{
+		TenantID:  event.User.TenantID,
+		UserID:    event.User.ID,
+		Data:      event.User,
+		ValidFrom: event.OccurredAt,
+	}).Error
+}
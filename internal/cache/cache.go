@@ -0,0 +1,19 @@
+// (Хранилища кэша: LRU в памяти процесса и Redis)
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store хранит значения по ключу с ограниченным сроком жизни. Реализации
+// должны быть безопасны для конкурентного использования; ошибка означает
+// недоступность хранилища, а не отсутствие ключа
+type Store interface {
+	// Get возвращает значение и true, если ключ есть и срок его жизни не истек
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set сохраняет значение; ttl <= 0 - без ограничения срока
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete удаляет ключи; отсутствующие ключи не считаются ошибкой
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,62 @@
+package cache_test
+
+import (
+	"context"
+	"multilayer/internal/cache"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	lru := cache.NewLRU(2)
+	require.NoError(t, lru.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, lru.Set(ctx, "b", []byte("2"), 0))
+
+	// Обращение к a делает вытесняемым b
+	_, ok, _ := lru.Get(ctx, "a")
+	assert.True(t, ok)
+	require.NoError(t, lru.Set(ctx, "c", []byte("3"), 0))
+
+	_, ok, _ = lru.Get(ctx, "b")
+	assert.False(t, ok)
+	value, ok, _ := lru.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 2, lru.Len())
+}
+
+func TestLRU_OverwriteAndDelete(t *testing.T) {
+	ctx := context.Background()
+	lru := cache.NewLRU(2)
+	require.NoError(t, lru.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, lru.Set(ctx, "a", []byte("2"), 0))
+	assert.Equal(t, 1, lru.Len())
+
+	value, _, _ := lru.Get(ctx, "a")
+	assert.Equal(t, []byte("2"), value)
+
+	require.NoError(t, lru.Delete(ctx, "a", "missing"))
+	_, ok, _ := lru.Get(ctx, "a")
+	assert.False(t, ok)
+}
+
+func TestLRU_Expires(t *testing.T) {
+	ctx := context.Background()
+	lru := cache.NewLRU(10)
+	require.NoError(t, lru.Set(ctx, "short", []byte("1"), 20*time.Millisecond))
+	require.NoError(t, lru.Set(ctx, "forever", []byte("2"), 0))
+
+	_, ok, _ := lru.Get(ctx, "short")
+	assert.True(t, ok)
+
+	time.Sleep(40 * time.Millisecond)
+	_, ok, _ = lru.Get(ctx, "short")
+	assert.False(t, ok)
+	_, ok, _ = lru.Get(ctx, "forever")
+	assert.True(t, ok)
+	assert.Equal(t, 1, lru.Len())
+}
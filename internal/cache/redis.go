@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig - параметры подключения к Redis
+type RedisConfig struct {
+	Addr     string // host:port
+	Password string
+	DB       int
+	// Timeout ограничивает подключение и каждую команду, если у ctx нет
+	// более раннего дедлайна; по умолчанию 500мс
+	Timeout time.Duration
+	// PoolSize - сколько соединений держать в пуле; по умолчанию 8
+	PoolSize int
+}
+
+// RedisStore - хранилище в Redis, общее для всех экземпляров сервиса;
+// использует только GET, SET и DEL
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore создает хранилище; соединения открываются при первых командах
+func NewRedisStore(config RedisConfig) *RedisStore {
+	if config.Timeout <= 0 {
+		config.Timeout = 500 * time.Millisecond
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = 8
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:         config.Addr,
+		Password:     config.Password,
+		DB:           config.DB,
+		DialTimeout:  config.Timeout,
+		ReadTimeout:  config.Timeout,
+		WriteTimeout: config.Timeout,
+		PoolSize:     config.PoolSize,
+		// Кэш не должен ждать соединения дольше, чем выполнять команду
+		PoolTimeout: config.Timeout,
+		// Кэш - необязательный слой: при недоступности Redis запрос идет в базу,
+		// а не повторяется
+		MaxRetries: -1,
+		// CLIENT SETINFO лишь подписывает соединение в CLIENT LIST
+		DisableIdentity: true,
+	})
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	switch {
+	case ttl <= 0:
+		ttl = 0 // без срока
+	case ttl < time.Millisecond:
+		// Redis не принимает срок меньше миллисекунды
+		ttl = time.Millisecond
+	}
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// Close закрывает соединения
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,176 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"multilayer/internal/cache"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis - сервер RESP2 в памяти, понимающий AUTH, SELECT, GET, SET [PX] и DEL;
+// на остальные команды, включая HELLO, отвечает ошибкой, как старый Redis
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]string
+	ttls     map[string]string
+	commands []string
+}
+
+func startFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeRedis{listener: listener, password: password, values: map[string]string{}, ttls: map[string]string{}}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		args[0] = strings.ToUpper(args[0])
+		s.mu.Lock()
+		s.commands = append(s.commands, args[0])
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authenticated = args[1] == s.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "GET":
+			if value, ok := s.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case args[0] == "SET":
+			s.values[args[1]] = args[2]
+			if len(args) == 5 && strings.EqualFold(args[3], "PX") {
+				s.ttls[args[1]] = args[4]
+			}
+			reply = "+OK\r\n"
+		case args[0] == "DEL":
+			deleted := 0
+			for _, key := range args[1:] {
+				if _, ok := s.values[key]; ok {
+					delete(s.values, key)
+					deleted++
+				}
+			}
+			reply = ":" + strconv.Itoa(deleted) + "\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		length, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:length])
+	}
+	return args, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	server := startFakeRedis(t, "secret")
+	store := cache.NewRedisStore(cache.RedisConfig{Addr: server.listener.Addr().String(), Password: "secret", DB: 2})
+	defer store.Close()
+	ctx := context.Background()
+
+	_, ok, err := store.Get(ctx, "user:1:1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Значение передается как есть, включая переводы строк
+	value := []byte("line\r\nbinary\x00")
+	require.NoError(t, store.Set(ctx, "user:1:1", value, 1500*time.Millisecond))
+	got, ok, err := store.Get(ctx, "user:1:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, value, got)
+
+	require.NoError(t, store.Delete(ctx, "user:1:1", "missing"))
+	_, ok, err = store.Get(ctx, "user:1:1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, "1500", server.ttls["user:1:1"])
+	// Без HELLO клиент переходит на RESP2; AUTH и SELECT выполняются один раз:
+	// соединение переиспользуется
+	assert.Equal(t, []string{"HELLO", "AUTH", "SELECT", "GET", "SET", "GET", "DEL", "GET"}, server.commands)
+}
+
+func TestRedisStore_Errors(t *testing.T) {
+	server := startFakeRedis(t, "secret")
+	ctx := context.Background()
+
+	t.Run("Wrong password", func(t *testing.T) {
+		store := cache.NewRedisStore(cache.RedisConfig{Addr: server.listener.Addr().String(), Password: "wrong"})
+		_, _, err := store.Get(ctx, "key")
+		assert.ErrorContains(t, err, "WRONGPASS")
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		store := cache.NewRedisStore(cache.RedisConfig{Addr: addr, Timeout: 100 * time.Millisecond})
+		assert.Error(t, store.Set(ctx, "key", []byte("value"), 0))
+	})
+}
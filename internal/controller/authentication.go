@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"errors"
+	"multilayer/internal/audit"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Authenticate проверяет пользователя из claim sub токена в заголовке
+// Authorization: Bearer. Пользователь, которому статус не позволяет входить
+// (suspended, locked, deactivated), получает 403, неизвестный - 401. Запросы без
+// токена или с токеном без sub (межсервисные) пропускаются; отклонить запросы без
+// токена можно через RequireToken. Регистрируется
+// после ResolveTenant: пользователь ищется в тенанте запроса, и после
+// RequestMetadata: проверенный sub становится инициатором в журнале аудита
+func Authenticate(userService service.UserServiceInterface, verifier *tenancy.TokenVerifier) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok {
+			return ctx.Next()
+		}
+		subject, err := verifier.Subject(strings.TrimSpace(token))
+		if err != nil {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if subject == "" {
+			return ctx.Next()
+		}
+		id, err := strconv.ParseUint(subject, 10, 0)
+		if err != nil {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid token subject",
+			})
+		}
+
+		_, err = userService.AuthenticateUser(ctx.UserContext(), uint(id))
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "unknown user",
+			})
+		case errors.Is(err, service.ErrUserInactive):
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case err != nil:
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		ctx.SetUserContext(audit.WithActor(ctx.UserContext(), subject))
+		return ctx.Next()
+	}
+}
+
+// RequireToken отклоняет с 401 запросы без токена Bearer с действительной
+// подписью. Проверку пользователя из sub выполняет Authenticate
+func RequireToken(verifier *tenancy.TokenVerifier) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "token is required",
+			})
+		}
+		if _, err := verifier.Subject(strings.TrimSpace(token)); err != nil {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return ctx.Next()
+	}
+}
+
+// RequireAdmin пропускает только запросы с токеном, в claim roles которого
+// есть tenancy.AdminRole. Без verifier (токены не настроены) маршруты закрыты
+func RequireAdmin(verifier *tenancy.TokenVerifier) fiber.Handler {
+	return RequireRole(verifier, tenancy.AdminRole)
+}
+
+// RequireRole пропускает только запросы с токеном, в claim roles которого есть role
+func RequireRole(verifier *tenancy.TokenVerifier, role string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if verifier == nil {
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "admin access is not configured",
+			})
+		}
+		token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "token is required",
+			})
+		}
+		granted, err := verifier.HasRole(strings.TrimSpace(token), role)
+		if err != nil {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if !granted {
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": role + " role is required",
+			})
+		}
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"io"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BufferBody читает тело запроса в память, отклоняя тела больше limit байт.
+// Нужен при fiber.Config.StreamRequestBody: тогда fiber не ограничивает размер
+// тела, и ctx.Body() прочитал бы поток целиком. Пути streamed получают тело
+// потоком (ctx.Request().BodyStream()) без ограничения
+func BufferBody(limit int, streamed ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		stream := ctx.Request().BodyStream()
+		if stream == nil || slices.Contains(streamed, ctx.Path()) {
+			return ctx.Next()
+		}
+		body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if len(body) > limit {
+			return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": "request body is too large",
+			})
+		}
+		ctx.Request().SetBody(body)
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultCacheControl разрешает клиенту хранить ответ, но требует
+// перепроверять его по ETag перед каждым использованием
+const DefaultCacheControl = "private, no-cache"
+
+// DefaultCacheControlRoutes - политики кэширования UserController по умолчанию
+func DefaultCacheControlRoutes() map[string]string {
+	return map[string]string{
+		"/users/:id":                   DefaultCacheControl,
+		"/users/by-username/:username": DefaultCacheControl,
+		"/users/by-email/:email":       DefaultCacheControl,
+	}
+}
+
+// DefaultVary - заголовки Vary UserController по умолчанию: заголовок тенанта
+// и токен
+func DefaultVary() []string {
+	return []string{"X-Tenant", fiber.HeaderAuthorization}
+}
+
+// sendCacheable отправляет body с валидаторами ETag и Last-Modified и
+// отвечает 304 без тела, если у клиента уже есть актуальная версия.
+// ETag строгий: хэш от байтов тела, поэтому совпадает только у одинаковых ответов
+func (c *UserController) sendCacheable(ctx *fiber.Ctx, body any, lastModified time.Time) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	lastModified = lastModified.UTC().Truncate(time.Second)
+
+	ctx.Set(fiber.HeaderETag, etag)
+	// Vary нужен и в 304: по нему кэш выбирает, какую сохраненную копию обновить
+	ctx.Vary(c.Vary...)
+	if !lastModified.IsZero() {
+		ctx.Set(fiber.HeaderLastModified, lastModified.Format(http.TimeFormat))
+	}
+	if cacheControl, ok := c.CacheControl[ctx.Route().Path]; ok {
+		ctx.Set(fiber.HeaderCacheControl, cacheControl)
+	}
+
+	if notModified(ctx, etag, lastModified) {
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return ctx.Send(data)
+}
+
+// notModified проверяет условия запроса по RFC 9110: If-None-Match, если он
+// есть, иначе If-Modified-Since
+func notModified(ctx *fiber.Ctx, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := ctx.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			// If-None-Match сравнивается слабо: W/"x" совпадает с "x"
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ctx.Get(fiber.HeaderIfModifiedSince))
+	return err == nil && !lastModified.After(since)
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"multilayer/internal/audit"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Заголовки идемпотентных запросов
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// IdempotencyCaller выделяет клиента, в пределах которого уникальны ключи
+type IdempotencyCaller func(ctx *fiber.Ctx) string
+
+// CallerByActor выделяет клиента по проверенному claim sub токена (его кладет
+// в контекст Authenticate), а для запросов без него - по ключу API из
+// заголовка apiKeyHeader или адресу клиента. Ключ API не проверяется, поэтому
+// пользователь с токеном не делит ключи идемпотентности с тем, кто подставит
+// тот же заголовок
+func CallerByActor(apiKeyHeader string) IdempotencyCaller {
+	return func(ctx *fiber.Ctx) string {
+		if actor := audit.FromContext(ctx.UserContext()).Actor; strings.HasPrefix(actor, audit.UserActorPrefix) {
+			return actor
+		}
+		if apiKey := ctx.Get(apiKeyHeader); apiKey != "" {
+			return "api_key:" + apiKey
+		}
+		return "ip:" + ctx.IP()
+	}
+}
+
+// Idempotency выполняет изменяющий запрос с заголовком Idempotency-Key один
+// раз: первый ответ сохраняется и повторяется на запросы с тем же ключом от
+// того же клиента. Повтор ключа с другим методом, путем или телом дает 422,
+// пока первый запрос выполняется - 409. Ответы 5xx, 401, 403, 409 и 429 не
+// сохраняются: они зависят от учетных данных или состояния на момент запроса,
+// и повтор выполнит запрос заново. Запросы с потоковым телом (пути streamed в
+// BufferBody, например импорт) middleware не обрабатывает: тело не хэшируется
+// до выполнения, и повтор ключа с другим файлом получил бы чужой отчет; такие
+// запросы выполняются каждый раз. Регистрируется после ResolveTenant
+func Idempotency(idempotencyService service.IdempotencyServiceInterface, caller IdempotencyCaller) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(IdempotencyKeyHeader)
+		if key == "" || ctx.Method() == fiber.MethodGet || ctx.Method() == fiber.MethodHead || ctx.Method() == fiber.MethodOptions {
+			return ctx.Next()
+		}
+		if ctx.Request().BodyStream() != nil {
+			return ctx.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+		}
+
+		record, replay, err := idempotencyService.Begin(ctx.UserContext(), hashString(caller(ctx)), key, requestHash(ctx))
+		switch {
+		case errors.Is(err, service.ErrIdempotencyKeyReused):
+			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, service.ErrIdempotencyKeyInFlight), errors.Is(err, service.ErrIdempotencyKeyCollision):
+			ctx.Set(fiber.HeaderRetryAfter, "1")
+			return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case err != nil:
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if replay {
+			ctx.Set(IdempotentReplayedHeader, "true")
+			if record.ResponseContentType != "" {
+				ctx.Set(fiber.HeaderContentType, record.ResponseContentType)
+			}
+			return ctx.Status(record.ResponseStatus).Send(record.ResponseBody)
+		}
+
+		keepAlive, stopKeepAlive := context.WithCancel(ctx.UserContext())
+		go idempotencyService.KeepAlive(keepAlive, record)
+		err = ctx.Next()
+		stopKeepAlive()
+		if err != nil {
+			release(ctx, idempotencyService, record)
+			return err
+		}
+		// Потоковый ответ сохранить нельзя, а повторяемый - не окончательный результат
+		status := ctx.Response().StatusCode()
+		if retryableStatus(status) || ctx.Response().IsBodyStream() {
+			release(ctx, idempotencyService, record)
+			return nil
+		}
+		body := append([]byte(nil), ctx.Response().Body()...)
+		contentType := string(ctx.Response().Header.ContentType())
+		if err := idempotencyService.Complete(ctx.UserContext(), record, status, contentType, body); err != nil {
+			// Запрос уже выполнен: отдаем ответ, а ключ освободится по LockTimeout
+			log.Printf("idempotency key %q: failed to store response: %v", record.IdempotencyKey, err)
+		}
+		return nil
+	}
+}
+
+// retryableStatus - ответы, которые повтор запроса может изменить
+func retryableStatus(status int) bool {
+	switch status {
+	case fiber.StatusUnauthorized, fiber.StatusForbidden, fiber.StatusConflict, fiber.StatusTooManyRequests:
+		return true
+	}
+	return status >= fiber.StatusInternalServerError
+}
+
+func release(ctx *fiber.Ctx, idempotencyService service.IdempotencyServiceInterface, record *entity.IdempotencyRecord) {
+	if err := idempotencyService.Release(ctx.UserContext(), record); err != nil {
+		log.Printf("idempotency key %q: failed to release: %v", record.IdempotencyKey, err)
+	}
+}
+
+// requestHash связывает ключ с конкретным запросом: метод, путь с параметрами и тело
+func requestHash(ctx *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(ctx.Method() + " " + ctx.OriginalURL() + "\n"))
+	hash.Write(ctx.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// hashString не дает хранить идентификаторы клиентов (например, ключи API) открыто
+func hashString(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
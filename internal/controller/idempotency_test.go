@@ -0,0 +1,196 @@
+package controller_test
+
+import (
+	"fmt"
+	"io"
+	"multilayer/internal/audit"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupIdempotencyApp собирает приложение, где POST /users считает вызовы
+func setupIdempotencyApp(t *testing.T) (*fiber.App, *atomic.Int32) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.IdempotencyRecord{}))
+	idempotencyService := service.NewIdempotencyService(repository.NewIdempotencyRepository(db), service.IdempotencyConfig{})
+
+	calls := new(atomic.Int32)
+	app := fiber.New()
+	// Без источников все запросы обслуживаются в тенанте по умолчанию
+	app.Use(controller.ResolveTenant(nil, false))
+	app.Use(controller.Idempotency(idempotencyService, func(ctx *fiber.Ctx) string {
+		return ctx.Get("X-API-Key")
+	}))
+	app.Post("/users", func(ctx *fiber.Ctx) error {
+		n := calls.Add(1)
+		switch string(ctx.Body()) {
+		case `{"fail":true}`:
+			return ctx.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "try later"})
+		case `{"limited":true}`:
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "rate limit exceeded"})
+		}
+		return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{"id": n})
+	})
+	return app, calls
+}
+
+func idempotentRequest(t *testing.T, app *fiber.App, apiKey, key, body string) (int, string, string) {
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	if key != "" {
+		req.Header.Set(controller.IdempotencyKeyHeader, key)
+	}
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), resp.Header.Get(controller.IdempotentReplayedHeader)
+}
+
+func TestIdempotency_ReplaysFirstResponse(t *testing.T) {
+	app, calls := setupIdempotencyApp(t)
+	body := `{"username":"alice"}`
+
+	status, first, replayed := idempotentRequest(t, app, "client", "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Empty(t, replayed)
+
+	status, second, replayed := idempotentRequest(t, app, "client", "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, first, second)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Тот же ключ с другим телом
+	status, _, _ = idempotentRequest(t, app, "client", "key-1", `{"username":"bob"}`)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// Ключ другого клиента и запросы без ключа выполняются
+	status, _, _ = idempotentRequest(t, app, "other", "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, status)
+	idempotentRequest(t, app, "client", "", body)
+	idempotentRequest(t, app, "client", "", body)
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestIdempotency_RetryableResponsesAreNotStored(t *testing.T) {
+	app, calls := setupIdempotencyApp(t)
+
+	for i := 0; i < 2; i++ {
+		status, _, replayed := idempotentRequest(t, app, "client", "key-1", `{"fail":true}`)
+		assert.Equal(t, fiber.StatusServiceUnavailable, status)
+		assert.Empty(t, replayed)
+
+		status, _, replayed = idempotentRequest(t, app, "client", "key-2", `{"limited":true}`)
+		assert.Equal(t, fiber.StatusTooManyRequests, status)
+		assert.Empty(t, replayed)
+	}
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestIdempotency_ConcurrentDuplicate(t *testing.T) {
+	app, calls := setupIdempotencyApp(t)
+	release := make(chan struct{})
+	app.Post("/slow", func(ctx *fiber.Ctx) error {
+		calls.Add(1)
+		<-release
+		return ctx.SendStatus(fiber.StatusCreated)
+	})
+
+	done := make(chan int)
+	go func() {
+		req := httptest.NewRequest("POST", "/slow", nil)
+		req.Header.Set(controller.IdempotencyKeyHeader, "key-1")
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		done <- resp.StatusCode
+	}()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// Первый запрос еще выполняется
+	req := httptest.NewRequest("POST", "/slow", nil)
+	req.Header.Set(controller.IdempotencyKeyHeader, "key-1")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, fiber.StatusCreated, <-done)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_StreamedBodiesAreSkipped(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.IdempotencyRecord{}))
+	idempotencyService := service.NewIdempotencyService(repository.NewIdempotencyRepository(db), service.IdempotencyConfig{})
+
+	app := fiber.New(fiber.Config{StreamRequestBody: true})
+	app.Use(controller.BufferBody(1024, "/users:import"))
+	app.Use(controller.ResolveTenant(nil, false))
+	app.Use(controller.Idempotency(idempotencyService, func(*fiber.Ctx) string { return "client" }))
+	app.Post("/users\\:import", func(ctx *fiber.Ctx) error {
+		body, err := io.ReadAll(ctx.Request().BodyStream())
+		if err != nil {
+			return err
+		}
+		return ctx.Status(fiber.StatusOK).Send(body)
+	})
+
+	// Повтор ключа с другим телом выполняется заново, а не получает первый ответ
+	for _, body := range []string{"first.csv", "second.csv"} {
+		req := httptest.NewRequest("POST", "/users:import", strings.NewReader(body))
+		req.Header.Set(controller.IdempotencyKeyHeader, "key-1")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, body, string(data))
+		assert.Empty(t, resp.Header.Get(controller.IdempotentReplayedHeader))
+	}
+}
+
+func TestCallerByActor(t *testing.T) {
+	caller := controller.CallerByActor("X-API-Key")
+	app := fiber.New()
+	app.Use(controller.RequestMetadata)
+	// Вместо Authenticate: проверенный sub становится инициатором
+	app.Use(func(ctx *fiber.Ctx) error {
+		if subject := ctx.Get("X-Test-Subject"); subject != "" {
+			ctx.SetUserContext(audit.WithActor(ctx.UserContext(), subject))
+		}
+		return ctx.Next()
+	})
+	app.Get("/", func(ctx *fiber.Ctx) error { return ctx.SendString(caller(ctx)) })
+
+	send := func(headers map[string]string) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	assert.Equal(t, "user:42", send(map[string]string{"X-Test-Subject": "42", "X-API-Key": "shared"}))
+	assert.Equal(t, "api_key:shared", send(map[string]string{"X-API-Key": "shared"}))
+	assert.Equal(t, "ip:0.0.0.0", send(nil))
+}
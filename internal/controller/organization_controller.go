@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OrganizationController struct {
+	organizationService service.OrganizationServiceInterface
+}
+
+func NewOrganizationController(organizationService service.OrganizationServiceInterface) *OrganizationController {
+	return &OrganizationController{organizationService: organizationService}
+}
+
+// membershipInput - тело запроса на добавление участника
+type membershipInput struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+func (c *OrganizationController) CreateOrganization(ctx *fiber.Ctx) error {
+	var input struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	organization, err := c.organizationService.CreateOrganization(ctx.UserContext(), input.Slug, input.Name)
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(organization)
+}
+
+func (c *OrganizationController) ListOrganizations(ctx *fiber.Ctx) error {
+	organizations, err := c.organizationService.ListOrganizations(ctx.UserContext())
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.JSON(fiber.Map{"organizations": organizations})
+}
+
+func (c *OrganizationController) GetOrganization(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	organization, err := c.organizationService.GetOrganization(ctx.UserContext(), ids[0])
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.JSON(organization)
+}
+
+// DeleteOrganization удаляет организацию без команд; членство в ней удаляется вместе с ней
+func (c *OrganizationController) DeleteOrganization(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := c.organizationService.DeleteOrganization(ctx.UserContext(), ids[0]); err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (c *OrganizationController) ListMembers(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	members, err := c.organizationService.ListMembers(ctx.UserContext(), ids[0])
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.JSON(fiber.Map{"members": members})
+}
+
+// AddMember добавляет пользователя в организацию с ролью owner, admin или member
+func (c *OrganizationController) AddMember(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	var input membershipInput
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	member, err := c.organizationService.AddMember(ctx.UserContext(), ids[0], input.UserID, input.Role)
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(member)
+}
+
+// RemoveMember исключает пользователя из организации и всех ее команд
+func (c *OrganizationController) RemoveMember(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id", "userId")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := c.organizationService.RemoveMember(ctx.UserContext(), ids[0], ids[1]); err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// ListUserMemberships возвращает организации и команды пользователя
+func (c *OrganizationController) ListUserMemberships(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	memberships, err := c.organizationService.ListUserMemberships(ctx.UserContext(), ids[0])
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.JSON(fiber.Map{"memberships": memberships})
+}
+
+// idParamLabels - названия параметров пути в сообщениях об ошибке
+var idParamLabels = map[string]string{
+	"id":     "ID",
+	"teamId": "team ID",
+	"userId": "user ID",
+}
+
+// idParams разбирает числовые параметры пути в порядке names
+func idParams(ctx *fiber.Ctx, names ...string) ([]uint, error) {
+	ids := make([]uint, len(names))
+	for i, name := range names {
+		id, err := strconv.Atoi(ctx.Params(name))
+		if err != nil || id < 0 {
+			return nil, errors.New("Invalid " + idParamLabels[name])
+		}
+		ids[i] = uint(id)
+	}
+	return ids, nil
+}
+
+// organizationError переводит ошибки сервисов организаций и команд в HTTP-ответ
+func organizationError(ctx *fiber.Ctx, err error) error {
+	var validationErr *entity.ValidationError
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.As(err, &validationErr):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, service.ErrOrganizationNotFound), errors.Is(err, service.ErrTeamNotFound),
+		errors.Is(err, service.ErrMemberNotFound), errors.Is(err, service.ErrUserNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, service.ErrOrganizationAlreadyExists), errors.Is(err, service.ErrTeamAlreadyExists),
+		errors.Is(err, service.ErrAlreadyMember), errors.Is(err, service.ErrOrganizationHasTeams),
+		errors.Is(err, service.ErrNotOrganizationMember):
+		status = fiber.StatusConflict
+	}
+	return ctx.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
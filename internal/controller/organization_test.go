@@ -0,0 +1,169 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupOrganizationApp собирает приложение с организациями и командами на sqlite
+func setupOrganizationApp(t *testing.T) (*fiber.App, *service.UserService) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared&_foreign_keys=1", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}, &entity.Organization{}, &entity.Team{}, &entity.OrganizationMember{}, &entity.TeamMember{}))
+
+	userRepo := repository.NewUserRepository(db)
+	userService := service.NewUserService(userRepo)
+	organizationRepo := repository.NewOrganizationRepository(db)
+	organizationController := controller.NewOrganizationController(service.NewOrganizationService(organizationRepo, userRepo))
+	teamController := controller.NewTeamController(service.NewTeamService(repository.NewTeamRepository(db), organizationRepo))
+
+	app := fiber.New()
+	// Без источников все запросы обслуживаются в тенанте по умолчанию
+	app.Use(controller.ResolveTenant(nil, false))
+	app.Post("/users", controller.NewUserController(userService).Register)
+	app.Get("/users/:id/memberships", organizationController.ListUserMemberships)
+	app.Post("/organizations", organizationController.CreateOrganization)
+	app.Get("/organizations/:id", organizationController.GetOrganization)
+	app.Delete("/organizations/:id", organizationController.DeleteOrganization)
+	app.Get("/organizations/:id/members", organizationController.ListMembers)
+	app.Post("/organizations/:id/members", organizationController.AddMember)
+	app.Delete("/organizations/:id/members/:userId", organizationController.RemoveMember)
+	app.Post("/organizations/:id/teams", teamController.CreateTeam)
+	app.Get("/organizations/:id/teams", teamController.ListTeams)
+	app.Delete("/organizations/:id/teams/:teamId", teamController.DeleteTeam)
+	app.Get("/organizations/:id/teams/:teamId/members", teamController.ListMembers)
+	app.Post("/organizations/:id/teams/:teamId/members", teamController.AddMember)
+	app.Delete("/organizations/:id/teams/:teamId/members/:userId", teamController.RemoveMember)
+	return app, userService
+}
+
+// createResource выполняет POST и возвращает ID созданной записи
+func createResource(t *testing.T, app *fiber.App, path, body string) uint {
+	resp, data := tenantRequest(t, app, "POST", path, "", body)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode, data)
+	var created struct {
+		ID uint `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(data), &created))
+	return created.ID
+}
+
+func TestOrganizations_Memberships(t *testing.T) {
+	app, userService := setupOrganizationApp(t)
+
+	alice := createResource(t, app, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	bob := createResource(t, app, "/users", `{"username":"bob","email":"bob@example.com"}`)
+	org := createResource(t, app, "/organizations", `{"slug":"acme","name":"Acme Inc"}`)
+	orgPath := fmt.Sprintf("/organizations/%d", org)
+	team := createResource(t, app, orgPath+"/teams", `{"slug":"platform"}`)
+	teamPath := fmt.Sprintf("%s/teams/%d", orgPath, team)
+
+	send := func(method, path, body string) (int, string) {
+		resp, data := tenantRequest(t, app, method, path, "", body)
+		return resp.StatusCode, data
+	}
+
+	t.Run("Add members", func(t *testing.T) {
+		status, body := send("POST", orgPath+"/members", fmt.Sprintf(`{"user_id":%d,"role":"owner"}`, alice))
+		require.Equal(t, fiber.StatusCreated, status, body)
+		assert.Contains(t, body, `"role":"owner"`)
+
+		status, body = send("POST", teamPath+"/members", fmt.Sprintf(`{"user_id":%d}`, alice))
+		require.Equal(t, fiber.StatusCreated, status, body)
+		assert.Contains(t, body, `"role":"member"`)
+
+		status, body = send("GET", fmt.Sprintf("/users/%d/memberships", alice), "")
+		require.Equal(t, fiber.StatusOK, status)
+		var response struct {
+			Memberships []entity.UserMembership `json:"memberships"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(body), &response))
+		require.Len(t, response.Memberships, 2)
+		assert.Equal(t, "acme", response.Memberships[0].Organization.Slug)
+		assert.Nil(t, response.Memberships[0].Team)
+		require.NotNil(t, response.Memberships[1].Team)
+		assert.Equal(t, "platform", response.Memberships[1].Team.Slug)
+		assert.Equal(t, "acme", response.Memberships[1].Organization.Slug)
+	})
+
+	t.Run("Rejected memberships", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			path      string
+			body      string
+			want      int
+			wantError string
+		}{
+			{"Duplicate", orgPath + "/members", fmt.Sprintf(`{"user_id":%d}`, alice), fiber.StatusConflict, service.ErrAlreadyMember.Error()},
+			{"Unknown user", orgPath + "/members", `{"user_id":999}`, fiber.StatusNotFound, service.ErrUserNotFound.Error()},
+			{"Unknown role", orgPath + "/members", fmt.Sprintf(`{"user_id":%d,"role":"root"}`, bob), fiber.StatusBadRequest, "role must be one of owner, admin, member"},
+			{"Unknown organization", "/organizations/999/members", fmt.Sprintf(`{"user_id":%d}`, bob), fiber.StatusNotFound, service.ErrOrganizationNotFound.Error()},
+			{"Team without organization membership", teamPath + "/members", fmt.Sprintf(`{"user_id":%d}`, bob), fiber.StatusConflict, service.ErrNotOrganizationMember.Error()},
+			{"Team of another organization", orgPath + "/teams/999/members", fmt.Sprintf(`{"user_id":%d}`, alice), fiber.StatusNotFound, service.ErrTeamNotFound.Error()},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				status, body := send("POST", tt.path, tt.body)
+				assert.Equal(t, tt.want, status)
+				assert.Contains(t, body, tt.wantError)
+			})
+		}
+	})
+
+	t.Run("Removing organization member removes team membership", func(t *testing.T) {
+		createResource(t, app, orgPath+"/members", fmt.Sprintf(`{"user_id":%d}`, bob))
+		createResource(t, app, teamPath+"/members", fmt.Sprintf(`{"user_id":%d}`, bob))
+
+		status, _ := send("DELETE", fmt.Sprintf("%s/members/%d", orgPath, bob), "")
+		require.Equal(t, fiber.StatusNoContent, status)
+
+		_, body := send("GET", teamPath+"/members", "")
+		assert.NotContains(t, body, fmt.Sprintf(`"user_id":%d`, bob))
+		status, _ = send("DELETE", fmt.Sprintf("%s/members/%d", orgPath, bob), "")
+		assert.Equal(t, fiber.StatusNotFound, status)
+	})
+
+	t.Run("Deleting user removes memberships", func(t *testing.T) {
+		carol := createResource(t, app, "/users", `{"username":"carol","email":"carol@example.com"}`)
+		createResource(t, app, orgPath+"/members", fmt.Sprintf(`{"user_id":%d}`, carol))
+		createResource(t, app, teamPath+"/members", fmt.Sprintf(`{"user_id":%d}`, carol))
+
+		require.NoError(t, userService.DeleteUser(tenancy.WithTenant(context.Background(), entity.DefaultTenantID), carol))
+
+		for _, path := range []string{orgPath + "/members", teamPath + "/members"} {
+			_, body := send("GET", path, "")
+			assert.NotContains(t, body, fmt.Sprintf(`"user_id":%d`, carol), path)
+		}
+	})
+
+	t.Run("Organization with teams cannot be deleted", func(t *testing.T) {
+		status, body := send("DELETE", orgPath, "")
+		assert.Equal(t, fiber.StatusConflict, status)
+		assert.Contains(t, body, service.ErrOrganizationHasTeams.Error())
+
+		status, _ = send("DELETE", teamPath, "")
+		require.Equal(t, fiber.StatusNoContent, status)
+		status, _ = send("DELETE", orgPath, "")
+		require.Equal(t, fiber.StatusNoContent, status)
+
+		status, _ = send("GET", orgPath, "")
+		assert.Equal(t, fiber.StatusNotFound, status)
+		_, body = send("GET", fmt.Sprintf("/users/%d/memberships", alice), "")
+		assert.JSONEq(t, `{"memberships":[]}`, body)
+	})
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// parseBeforePage читает параметры limit и before журналов, листаемых от новых
+// записей к старым; before - ID последней записи предыдущей страницы
+func parseBeforePage(ctx *fiber.Ctx) (beforeID uint, limit int, err error) {
+	limit = ctx.QueryInt("limit", defaultPageLimit)
+	if limit <= 0 || limit > maxPageLimit {
+		return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+	}
+	before := ctx.QueryInt("before", 0)
+	if before < 0 {
+		return 0, 0, errors.New("before must be a non-negative ID")
+	}
+	return uint(before), limit, nil
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"math"
+	"multilayer/internal/ratelimit"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimitKey выделяет клиента, которому принадлежит корзина; пустая строка -
+// лимит к запросу не применяется
+type RateLimitKey func(ctx *fiber.Ctx) string
+
+// ByIP считает запросы по адресу клиента (ctx.IP учитывает ProxyHeader приложения)
+func ByIP(ctx *fiber.Ctx) string {
+	return ctx.IP()
+}
+
+// ByAPIKey считает запросы по ключу API из заголовка; запросы без ключа не ограничивает
+func ByAPIKey(header string) RateLimitKey {
+	return func(ctx *fiber.Ctx) string {
+		return ctx.Get(header)
+	}
+}
+
+// RateLimiter создает middleware ограничения частоты запросов над общим
+// хранилищем корзин. Адреса из allowlist (внутренние сервисы) не ограничиваются
+type RateLimiter struct {
+	store     ratelimit.Store
+	allowlist []netip.Prefix
+	now       func() time.Time
+}
+
+func NewRateLimiter(store ratelimit.Store, allowlist ...netip.Prefix) *RateLimiter {
+	return &RateLimiter{store: store, allowlist: allowlist, now: time.Now}
+}
+
+// rateLimitRemainingKey - ключ ctx.Locals с наименьшим остатком среди
+// пройденных лимитов: заголовки RateLimit-* описывают самый строгий из них
+const rateLimitRemainingKey = "ratelimit_remaining"
+
+// Limit возвращает middleware с лимитом name для каждого клиента из key.
+// Лимиты можно вкладывать: общий через app.Use и отдельный на маршрут.
+// При превышении отвечает 429 с Retry-After; при сбое хранилища пропускает запрос
+func (l *RateLimiter) Limit(name string, limit ratelimit.Limit, key RateLimitKey) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if !l.Allow(ctx, name, limit, key) {
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "rate limit exceeded",
+			})
+		}
+		return ctx.Next()
+	}
+}
+
+// Allow забирает токен лимита name, как Limit, но сам не отвечает: для
+// обработчиков, которые решают о лимите после разбора запроса (например,
+// GraphQL по полям операции). Выставляет заголовки RateLimit-* и при
+// превышении Retry-After; false - лимит исчерпан
+func (l *RateLimiter) Allow(ctx *fiber.Ctx, name string, limit ratelimit.Limit, key RateLimitKey) bool {
+	return l.AllowN(ctx, name, limit, key, 1)
+}
+
+// AllowN забирает n токенов сразу, как Allow: для запросов, стоимость которых
+// зависит от тела, например поиска по списку идентификаторов
+func (l *RateLimiter) AllowN(ctx *fiber.Ctx, name string, limit ratelimit.Limit, key RateLimitKey, n int) bool {
+	if !limit.Enabled() || l.allowed(ctx.IP()) {
+		return true
+	}
+	client := key(ctx)
+	if client == "" {
+		return true
+	}
+
+	result, err := l.store.Take(ctx.UserContext(), name+":"+client, limit, n, l.now())
+	if err != nil {
+		log.Printf("rate limit %s: %v", name, err)
+		return true
+	}
+
+	if remaining, ok := ctx.Locals(rateLimitRemainingKey).(int); !ok || result.Remaining <= remaining || !result.Allowed {
+		ctx.Locals(rateLimitRemainingKey, result.Remaining)
+		ctx.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		ctx.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		ctx.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.Itoa(ceilSeconds(limit.Period)))
+	}
+	if !result.Allowed {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+	}
+	return result.Allowed
+}
+
+// AllowAddr забирает токен лимита name для клиента с адресом addr вне HTTP
+// (например, в gRPC): корзины общие с Limit и ByIP, поэтому клиент не обойдет
+// лимит, сменив протокол. При сбое хранилища разрешает запрос
+func (l *RateLimiter) AllowAddr(ctx context.Context, name string, limit ratelimit.Limit, addr string) bool {
+	if !limit.Enabled() || addr == "" || l.allowed(addr) {
+		return true
+	}
+	result, err := l.store.Take(ctx, name+":"+addr, limit, 1, l.now())
+	if err != nil {
+		log.Printf("rate limit %s: %v", name, err)
+		return true
+	}
+	return result.Allowed
+}
+
+func (l *RateLimiter) allowed(ip string) bool {
+	if len(l.allowlist) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.allowlist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
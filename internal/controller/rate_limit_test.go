@@ -0,0 +1,123 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/controller"
+	"multilayer/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRateLimitApp(limiter *controller.RateLimiter) *fiber.App {
+	app := fiber.New(fiber.Config{ProxyHeader: "X-Forwarded-For"})
+	app.Use(limiter.Limit("ip", ratelimit.Limit{Requests: 5, Period: time.Minute}, controller.ByIP))
+	app.Use(limiter.Limit("api_key", ratelimit.Limit{Requests: 3, Period: time.Minute}, controller.ByAPIKey("X-API-Key")))
+	app.Post("/users", limiter.Limit("signup", ratelimit.Limit{Requests: 2, Period: time.Hour}, controller.ByIP), func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusCreated)
+	})
+	app.Get("/users", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+func rateLimitedRequest(t *testing.T, app *fiber.App, method, ip, apiKey string) *http.Response {
+	req := httptest.NewRequest(method, "/users", nil)
+	req.Header.Set("X-Forwarded-For", ip)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestRateLimiter_PerRoute(t *testing.T) {
+	app := setupRateLimitApp(controller.NewRateLimiter(ratelimit.NewMemoryStore()))
+
+	first := rateLimitedRequest(t, app, "POST", "203.0.113.1", "")
+	assert.Equal(t, fiber.StatusCreated, first.StatusCode)
+	// Заголовки описывают самый строгий лимит - регистрации
+	assert.Equal(t, "2", first.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "1", first.Header.Get("RateLimit-Remaining"))
+	assert.Equal(t, "1800", first.Header.Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=3600", first.Header.Get("RateLimit-Policy"))
+
+	assert.Equal(t, fiber.StatusCreated, rateLimitedRequest(t, app, "POST", "203.0.113.1", "").StatusCode)
+	denied := rateLimitedRequest(t, app, "POST", "203.0.113.1", "")
+	assert.Equal(t, fiber.StatusTooManyRequests, denied.StatusCode)
+	assert.Equal(t, "1800", denied.Header.Get("Retry-After"))
+	assert.Equal(t, "0", denied.Header.Get("RateLimit-Remaining"))
+
+	// Лимит регистраций не касается других маршрутов и других адресов
+	assert.Equal(t, fiber.StatusOK, rateLimitedRequest(t, app, "GET", "203.0.113.1", "").StatusCode)
+	assert.Equal(t, fiber.StatusCreated, rateLimitedRequest(t, app, "POST", "203.0.113.2", "").StatusCode)
+}
+
+func TestRateLimiter_PerIPAndAPIKey(t *testing.T) {
+	app := setupRateLimitApp(controller.NewRateLimiter(ratelimit.NewMemoryStore()))
+
+	// Ключ API ограничен независимо от адреса
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		assert.Equal(t, fiber.StatusOK, rateLimitedRequest(t, app, "GET", ip, "key-1").StatusCode)
+	}
+	assert.Equal(t, fiber.StatusTooManyRequests, rateLimitedRequest(t, app, "GET", "203.0.113.4", "key-1").StatusCode)
+	assert.Equal(t, fiber.StatusOK, rateLimitedRequest(t, app, "GET", "203.0.113.4", "key-2").StatusCode)
+
+	// Адрес ограничен и без ключа; первый адрес уже потратил один запрос
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, fiber.StatusOK, rateLimitedRequest(t, app, "GET", "203.0.113.1", "").StatusCode)
+	}
+	resp := rateLimitedRequest(t, app, "GET", "203.0.113.1", "")
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "12", resp.Header.Get("Retry-After"))
+}
+
+func TestRateLimiter_Allowlist(t *testing.T) {
+	app := setupRateLimitApp(controller.NewRateLimiter(ratelimit.NewMemoryStore(), netip.MustParsePrefix("10.0.0.0/8")))
+
+	for i := 0; i < 5; i++ {
+		resp := rateLimitedRequest(t, app, "POST", "10.1.2.3", "")
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("RateLimit-Limit"))
+	}
+	assert.Equal(t, fiber.StatusCreated, rateLimitedRequest(t, app, "POST", "203.0.113.1", "").StatusCode)
+}
+
+func TestRateLimiter_AllowAddrSharesBuckets(t *testing.T) {
+	limiter := controller.NewRateLimiter(ratelimit.NewMemoryStore(), netip.MustParsePrefix("10.0.0.0/8"))
+	app := setupRateLimitApp(limiter)
+	signup := ratelimit.Limit{Requests: 2, Period: time.Hour}
+
+	// Регистрация через другой протокол расходует ту же корзину signup
+	assert.True(t, limiter.AllowAddr(context.Background(), "signup", signup, "203.0.113.1"))
+	assert.Equal(t, fiber.StatusCreated, rateLimitedRequest(t, app, "POST", "203.0.113.1", "").StatusCode)
+	assert.False(t, limiter.AllowAddr(context.Background(), "signup", signup, "203.0.113.1"))
+	assert.Equal(t, fiber.StatusTooManyRequests, rateLimitedRequest(t, app, "POST", "203.0.113.1", "").StatusCode)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.AllowAddr(context.Background(), "signup", signup, "10.1.2.3"))
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(context.Context, string, ratelimit.Limit, int, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store unavailable")
+}
+
+func TestRateLimiter_StoreFailureAllowsRequests(t *testing.T) {
+	app := setupRateLimitApp(controller.NewRateLimiter(failingRateLimitStore{}))
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, fiber.StatusCreated, rateLimitedRequest(t, app, "POST", "203.0.113.1", "").StatusCode)
+	}
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"multilayer/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TeamController обслуживает команды по путям /organizations/:id/teams
+type TeamController struct {
+	teamService service.TeamServiceInterface
+}
+
+func NewTeamController(teamService service.TeamServiceInterface) *TeamController {
+	return &TeamController{teamService: teamService}
+}
+
+func (c *TeamController) CreateTeam(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	var input struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	team, err := c.teamService.CreateTeam(ctx.UserContext(), ids[0], input.Slug, input.Name)
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(team)
+}
+
+func (c *TeamController) ListTeams(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	teams, err := c.teamService.ListTeams(ctx.UserContext(), ids[0])
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.JSON(fiber.Map{"teams": teams})
+}
+
+// DeleteTeam удаляет команду; членство в ней удаляется вместе с ней
+func (c *TeamController) DeleteTeam(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id", "teamId")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := c.teamService.DeleteTeam(ctx.UserContext(), ids[0], ids[1]); err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (c *TeamController) ListMembers(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id", "teamId")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	members, err := c.teamService.ListMembers(ctx.UserContext(), ids[0], ids[1])
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.JSON(fiber.Map{"members": members})
+}
+
+// AddMember добавляет в команду участника организации
+func (c *TeamController) AddMember(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id", "teamId")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	var input membershipInput
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	member, err := c.teamService.AddMember(ctx.UserContext(), ids[0], ids[1], input.UserID, input.Role)
+	if err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(member)
+}
+
+func (c *TeamController) RemoveMember(ctx *fiber.Ctx) error {
+	ids, err := idParams(ctx, "id", "teamId", "userId")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := c.teamService.RemoveMember(ctx.UserContext(), ids[0], ids[1], ids[2]); err != nil {
+		return organizationError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TenantSource извлекает slug тенанта из запроса; пустая строка означает, что
+// источник к запросу не применим
+type TenantSource func(ctx *fiber.Ctx) (string, error)
+
+// TenantFromHeader берет slug из заголовка, например X-Tenant
+func TenantFromHeader(name string) TenantSource {
+	return func(ctx *fiber.Ctx) (string, error) {
+		return strings.TrimSpace(ctx.Get(name)), nil
+	}
+}
+
+// TenantFromSubdomain берет slug из поддомена baseDomain:
+// acme.users.example.com при baseDomain "users.example.com" дает "acme"
+func TenantFromSubdomain(baseDomain string) TenantSource {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return func(ctx *fiber.Ctx) (string, error) {
+		host := strings.ToLower(ctx.Hostname())
+		if colon := strings.LastIndexByte(host, ':'); colon >= 0 && !strings.HasSuffix(host, "]") {
+			host = host[:colon]
+		}
+		subdomain, ok := strings.CutSuffix(host, suffix)
+		if !ok || strings.Contains(subdomain, ".") {
+			return "", nil
+		}
+		return subdomain, nil
+	}
+}
+
+// TenantFromToken берет slug из claim токена в заголовке Authorization: Bearer
+func TenantFromToken(verifier *tenancy.TokenVerifier) TenantSource {
+	return func(ctx *fiber.Ctx) (string, error) {
+		token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok {
+			return "", nil
+		}
+		return verifier.Tenant(strings.TrimSpace(token))
+	}
+}
+
+// ResolveTenant определяет тенанта запроса по источникам и кладет его ID в
+// контекст. Источники, назвавшие разных тенантов, - попытка выйти за пределы
+// своего тенанта, такой запрос отклоняется. Без тенанта запрос либо
+// отклоняется (required), либо обслуживается в entity.DefaultTenantID.
+// Заголовок и поддомен задает сам клиент: если токены настроены, источником
+// должен быть только TenantFromToken
+func ResolveTenant(tenantService service.TenantServiceInterface, required bool, sources ...TenantSource) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		var slug string
+		for _, source := range sources {
+			candidate, err := source(ctx)
+			if err != nil {
+				return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			if candidate == "" {
+				continue
+			}
+			if slug != "" && !strings.EqualFold(slug, candidate) {
+				return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "conflicting tenant in request",
+				})
+			}
+			slug = candidate
+		}
+
+		if slug == "" {
+			if required {
+				return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "tenant is required",
+				})
+			}
+			ctx.SetUserContext(tenancy.WithTenant(ctx.UserContext(), entity.DefaultTenantID))
+			return ctx.Next()
+		}
+
+		tenant, err := tenantService.GetTenant(slug)
+		if err != nil {
+			return tenantError(ctx, err)
+		}
+		ctx.SetUserContext(tenancy.WithTenant(ctx.UserContext(), tenant.ID))
+		return ctx.Next()
+	}
+}
+
+type TenantController struct {
+	tenantService service.TenantServiceInterface
+}
+
+func NewTenantController(tenantService service.TenantServiceInterface) *TenantController {
+	return &TenantController{tenantService: tenantService}
+}
+
+func (c *TenantController) CreateTenant(ctx *fiber.Ctx) error {
+	var input struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	tenant, err := c.tenantService.CreateTenant(input.Slug, input.Name)
+	if err != nil {
+		return tenantError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(tenant)
+}
+
+// SetEmailDomains заменяет списки доменов email тенанта
+func (c *TenantController) SetEmailDomains(ctx *fiber.Ctx) error {
+	var input struct {
+		Allowed []string `json:"allowed_email_domains"`
+		Denied  []string `json:"denied_email_domains"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	tenant, err := c.tenantService.SetEmailDomains(ctx.Params("slug"), input.Allowed, input.Denied)
+	if err != nil {
+		return tenantError(ctx, err)
+	}
+	return ctx.JSON(tenant)
+}
+
+func (c *TenantController) ListTenants(ctx *fiber.Ctx) error {
+	tenants, err := c.tenantService.ListTenants()
+	if err != nil {
+		return tenantError(ctx, err)
+	}
+	return ctx.JSON(fiber.Map{"tenants": tenants})
+}
+
+func tenantError(ctx *fiber.Ctx, err error) error {
+	var validationErr *entity.ValidationError
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.As(err, &validationErr):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, service.ErrTenantNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, service.ErrTenantAlreadyExists):
+		status = fiber.StatusConflict
+	}
+	return ctx.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,254 @@
+package controller_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"multilayer/internal/audit"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var testTokenSecret = []byte("tenant-token-secret")
+
+// setupTenantApp собирает приложение на настоящих сервисах и sqlite с
+// тенантами acme и globex
+func setupTenantApp(t *testing.T) *fiber.App {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.Tenant{}, &entity.User{}, &entity.UserAuditEntry{}, &entity.UserVersion{}))
+
+	tenantService := service.NewTenantService(repository.NewTenantRepository(db))
+	for _, slug := range []string{"acme", "globex"} {
+		_, err := tenantService.CreateTenant(slug, "")
+		require.NoError(t, err)
+	}
+
+	userRepo := repository.NewUserRepository(db)
+	userRepo.Events = repository.EventRecorders{audit.NewRecorder(), audit.NewVersionRecorder()}
+	userService := service.NewUserService(userRepo)
+	userService.EmailValidator.TenantDomains = tenantService
+	userController := controller.NewUserController(userService)
+
+	app := fiber.New()
+	app.Put("/tenants/:slug/email-domains", controller.NewTenantController(tenantService).SetEmailDomains)
+	app.Use(controller.ResolveTenant(tenantService, true,
+		controller.TenantFromHeader("X-Tenant"),
+		controller.TenantFromSubdomain("users.example.com"),
+		controller.TenantFromToken(&tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"}),
+	))
+	app.Post("/users", userController.Register)
+	app.Post("/users\\:import", userController.ImportUsers)
+	app.Get("/users\\:export", userController.ExportUsers)
+	app.Get("/users/:id", userController.GetUser)
+	app.Put("/users/:id", userController.UpdateUser)
+	app.Put("/users/:id/profile", userController.UpdateProfile)
+	app.Get("/users/:id/history", userController.GetUserHistory)
+	return app
+}
+
+func tenantRequest(t *testing.T, app *fiber.App, method, path, tenant, body string) (*http.Response, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if tenant != "" {
+		req.Header.Set("X-Tenant", tenant)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func signTenantToken(claims map[string]any) string {
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, testTokenSecret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestUserController_TenantIsolation(t *testing.T) {
+	app := setupTenantApp(t)
+
+	resp, body := tenantRequest(t, app, "POST", "/users", "acme", `{"username":"alice","email":"alice@example.com"}`)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode, body)
+	var created controller.UserResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	userPath := fmt.Sprintf("/users/%d", created.ID)
+
+	t.Run("Own tenant reads", func(t *testing.T) {
+		resp, _ := tenantRequest(t, app, "GET", userPath, "acme", "")
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("No cross-tenant reads", func(t *testing.T) {
+		for _, path := range []string{userPath, userPath + "?as_of=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339), userPath + "/history"} {
+			resp, _ := tenantRequest(t, app, "GET", path, "globex", "")
+			assert.Equal(t, fiber.StatusNotFound, resp.StatusCode, path)
+		}
+
+		resp, body := tenantRequest(t, app, "GET", "/users:export?format=ndjson", "globex", "")
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.NotContains(t, body, "alice")
+	})
+
+	t.Run("No cross-tenant writes", func(t *testing.T) {
+		resp, _ := tenantRequest(t, app, "PUT", userPath, "globex", `{"username":"mallory","email":"mallory@example.com"}`)
+		assert.NotEqual(t, fiber.StatusOK, resp.StatusCode)
+		resp, _ = tenantRequest(t, app, "PUT", userPath+"/profile", "globex", `{"display_name":"Mallory"}`)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+		resp, body := tenantRequest(t, app, "GET", userPath, "acme", "")
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Contains(t, body, `"username":"alice"`)
+		assert.NotContains(t, body, "Mallory")
+	})
+
+	t.Run("Uniqueness per tenant", func(t *testing.T) {
+		resp, body := tenantRequest(t, app, "POST", "/users", "globex", `{"username":"Alice","email":"alice@example.com"}`)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode, body)
+
+		resp, _ = tenantRequest(t, app, "POST", "/users", "acme", `{"username":"ALICE","email":"other@example.com"}`)
+		assert.NotEqual(t, fiber.StatusCreated, resp.StatusCode)
+	})
+}
+
+func TestTenantController_EmailDomains(t *testing.T) {
+	app := setupTenantApp(t)
+
+	resp, body := tenantRequest(t, app, "PUT", "/tenants/acme/email-domains", "", `{"allowed_email_domains":["Acme.com"," "]}`)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, `"allowed_email_domains":["acme.com"]`)
+	resp, body = tenantRequest(t, app, "PUT", "/tenants/acme/email-domains", "", `{"denied_email_domains":["not a domain"]}`)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, body)
+	resp, _ = tenantRequest(t, app, "PUT", "/tenants/initech/email-domains", "", `{}`)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	// Список acme не действует на globex
+	resp, body = tenantRequest(t, app, "POST", "/users", "acme", `{"username":"alice","email":"alice@gmail.com"}`)
+	assert.NotEqual(t, fiber.StatusCreated, resp.StatusCode)
+	assert.Contains(t, body, "not allowed for this tenant")
+	resp, body = tenantRequest(t, app, "POST", "/users", "acme", `{"username":"alice","email":"alice@acme.com"}`)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode, body)
+	resp, body = tenantRequest(t, app, "POST", "/users", "globex", `{"username":"alice","email":"alice@gmail.com"}`)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode, body)
+}
+
+func TestResolveTenant(t *testing.T) {
+	app := setupTenantApp(t)
+
+	send := func(configure func(req *http.Request)) (int, string) {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		configure(req)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Error
+	}
+
+	tests := []struct {
+		name      string
+		configure func(req *http.Request)
+		want      int
+		wantError string
+	}{
+		{"Missing tenant", func(req *http.Request) {}, fiber.StatusBadRequest, "tenant is required"},
+		{"Unknown tenant", func(req *http.Request) { req.Header.Set("X-Tenant", "initech") }, fiber.StatusNotFound, "tenant not found"},
+		{"Subdomain", func(req *http.Request) { req.Host = "acme.users.example.com" }, fiber.StatusNotFound, "User not found"},
+		{"Token claim", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+signTenantToken(map[string]any{"tenant": "acme"}))
+		}, fiber.StatusNotFound, "User not found"},
+		{"Expired token", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+signTenantToken(map[string]any{"tenant": "acme", "exp": time.Now().Add(-time.Minute).Unix()}))
+		}, fiber.StatusUnauthorized, tenancy.ErrInvalidToken.Error()},
+		{"Forged token", func(req *http.Request) {
+			token := signTenantToken(map[string]any{"tenant": "acme"})
+			req.Header.Set("Authorization", "Bearer "+token[:len(token)-2]+"AA")
+		}, fiber.StatusUnauthorized, tenancy.ErrInvalidToken.Error()},
+		{"Header contradicts token", func(req *http.Request) {
+			req.Header.Set("X-Tenant", "globex")
+			req.Header.Set("Authorization", "Bearer "+signTenantToken(map[string]any{"tenant": "acme"}))
+		}, fiber.StatusForbidden, "conflicting tenant in request"},
+		{"Header contradicts subdomain", func(req *http.Request) {
+			req.Header.Set("X-Tenant", "globex")
+			req.Host = "acme.users.example.com"
+		}, fiber.StatusForbidden, "conflicting tenant in request"},
+	}
+
+	// Пользователя 1 нет ни в одном тенанте: "User not found" означает, что тенант определен
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message := send(tt.configure)
+			assert.Equal(t, tt.want, status)
+			assert.Equal(t, tt.wantError, message)
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	verifier := &tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"}
+	app := fiber.New()
+	app.Get("/tenants", controller.RequireAdmin(verifier), func(ctx *fiber.Ctx) error { return ctx.SendStatus(fiber.StatusOK) })
+	app.Get("/unconfigured", controller.RequireAdmin(nil), func(ctx *fiber.Ctx) error { return ctx.SendStatus(fiber.StatusOK) })
+
+	send := func(path string, claims map[string]any) int {
+		req := httptest.NewRequest("GET", path, nil)
+		if claims != nil {
+			req.Header.Set("Authorization", "Bearer "+signTenantToken(claims))
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusUnauthorized, send("/tenants", nil))
+	assert.Equal(t, fiber.StatusForbidden, send("/tenants", map[string]any{"sub": "1"}))
+	assert.Equal(t, fiber.StatusForbidden, send("/tenants", map[string]any{"roles": "administrator"}))
+	assert.Equal(t, fiber.StatusOK, send("/tenants", map[string]any{"roles": []string{"support", "admin"}}))
+	assert.Equal(t, fiber.StatusOK, send("/tenants", map[string]any{"roles": "support admin"}))
+	assert.Equal(t, fiber.StatusUnauthorized, send("/tenants", map[string]any{"roles": "admin", "exp": time.Now().Add(-time.Minute).Unix()}))
+	// Без настроенных токенов административные маршруты закрыты
+	assert.Equal(t, fiber.StatusForbidden, send("/unconfigured", map[string]any{"roles": "admin"}))
+}
+
+func TestRequireRole_PlatformAdmin(t *testing.T) {
+	verifier := &tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"}
+	app := fiber.New()
+	app.Get("/tenants", controller.RequireRole(verifier, tenancy.PlatformAdminRole), func(ctx *fiber.Ctx) error { return ctx.SendStatus(fiber.StatusOK) })
+
+	send := func(claims map[string]any) int {
+		req := httptest.NewRequest("GET", "/tenants", nil)
+		req.Header.Set("Authorization", "Bearer "+signTenantToken(claims))
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// Администратор тенанта не управляет тенантами
+	assert.Equal(t, fiber.StatusForbidden, send(map[string]any{"tenant": "acme", "roles": "admin"}))
+	assert.Equal(t, fiber.StatusOK, send(map[string]any{"roles": "platform_admin"}))
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// CheckAvailability сообщает, свободны ли username и email из параметров запроса,
+// до регистрации. Ответ зависит от чужих данных, поэтому не кэшируется. От
+// перебора маршрут защищает ограничение частоты запросов, общее с поиском
+// пользователя по username и email (GetUserByUsername, GetUserByEmail,
+// LookupUsers): их ответы раскрывают то же самое, и лимит только на этом
+// маршруте перебор не остановил бы
+func (c *UserController) CheckAvailability(ctx *fiber.Ctx) error {
+	username, email := ctx.Query("username"), ctx.Query("email")
+	if username == "" && email == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "username or email is required",
+		})
+	}
+
+	availability, err := c.userService.CheckAvailability(ctx.UserContext(), username, email)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.JSON(availability)
+}
@@ -0,0 +1,45 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"multilayer/internal/controller"
+	"multilayer/internal/service"
+	"multilayer/internal/service/mocks"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserController_CheckAvailability(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Get("/users/availability", userController.CheckAvailability)
+
+	mockService.On("CheckAvailability", "alice", "alice@example.com").Return(&service.Availability{
+		Username: &service.FieldAvailability{
+			Value: "alice", Reason: service.AvailabilityTaken, Error: "username is already taken",
+			Suggestions: []string{"alice1", "alice2", "alice3"},
+		},
+		Email: &service.FieldAvailability{Value: "alice@example.com", Available: true},
+	}, nil)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/availability?username=alice&email=alice%40example.com", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	var body map[string]map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, false, body["username"]["available"])
+	assert.Equal(t, "taken", body["username"]["reason"])
+	assert.Len(t, body["username"]["suggestions"], 3)
+	assert.Equal(t, true, body["email"]["available"])
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/users/availability", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
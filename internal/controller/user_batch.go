@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"errors"
+	"multilayer/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// batchRequest - тело POST /admin/users:batch
+type batchRequest struct {
+	Mode       service.BatchMode `json:"mode"` // atomic (по умолчанию) или best_effort
+	Operations []struct {
+		Op       service.BatchOp `json:"op"`
+		ID       uint            `json:"id"`
+		Username string          `json:"username"`
+		Email    string          `json:"email"`
+	} `json:"operations"`
+}
+
+// BatchResultResponse - результат операции в ответе POST /admin/users:batch
+type BatchResultResponse struct {
+	service.BatchResult
+	User *UserResponse `json:"user,omitempty"`
+}
+
+// batchReport - ответ POST /admin/users:batch; Applied - применены ли изменения пакета
+type batchReport struct {
+	Mode    service.BatchMode           `json:"mode"`
+	Applied bool                        `json:"applied"`
+	Summary map[service.BatchStatus]int `json:"summary"`
+	Results []BatchResultResponse       `json:"results"`
+}
+
+// BatchUsers выполняет список операций create, update и delete. Ответ 200
+// содержит результат каждой операции; откаченный атомарный пакет дает 422
+// с тем же отчетом, где неудачные операции указаны со своими ошибками
+func (c *UserController) BatchUsers(ctx *fiber.Ctx) error {
+	var input batchRequest
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if input.Mode == "" {
+		input.Mode = service.BatchAtomic
+	}
+	if input.Mode != service.BatchAtomic && input.Mode != service.BatchBestEffort {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "mode must be atomic or best_effort",
+		})
+	}
+	if len(input.Operations) == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "operations must not be empty",
+		})
+	}
+
+	ops := make([]service.BatchOperation, len(input.Operations))
+	for i, op := range input.Operations {
+		ops[i] = service.BatchOperation{Op: op.Op, ID: op.ID, Username: op.Username, Email: op.Email}
+	}
+	results, err := c.userService.BatchUsers(ctx.UserContext(), input.Mode, ops)
+	if errors.Is(err, service.ErrBatchTooLarge) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	report := batchReport{
+		Mode:    input.Mode,
+		Summary: map[service.BatchStatus]int{},
+		Results: make([]BatchResultResponse, len(results)),
+	}
+	for i, result := range results {
+		report.Results[i] = BatchResultResponse{BatchResult: result}
+		if result.User != nil {
+			user := NewUserResponse(result.User)
+			report.Results[i].User = &user
+		}
+		report.Summary[result.Status]++
+		report.Applied = report.Applied || result.Succeeded()
+	}
+	if input.Mode == service.BatchAtomic && !report.Applied {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(report)
+	}
+	return ctx.JSON(report)
+}
@@ -0,0 +1,110 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"multilayer/internal/service/mocks"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type batchReport struct {
+	Mode    string         `json:"mode"`
+	Applied bool           `json:"applied"`
+	Summary map[string]int `json:"summary"`
+	Results []struct {
+		Index  int                      `json:"index"`
+		Op     string                   `json:"op"`
+		Status string                   `json:"status"`
+		UserID uint                     `json:"user_id"`
+		User   *controller.UserResponse `json:"user"`
+		Error  string                   `json:"error"`
+	} `json:"results"`
+}
+
+func TestUserController_BatchUsers(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Post("/users\\:batch", userController.BatchUsers)
+
+	post := func(t *testing.T, body string) (int, batchReport) {
+		req := httptest.NewRequest("POST", "/users:batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var report batchReport
+		json.NewDecoder(resp.Body).Decode(&report)
+		return resp.StatusCode, report
+	}
+
+	t.Run("Atomic by default", func(t *testing.T) {
+		mockService.On("BatchUsers", service.BatchAtomic, []service.BatchOperation{
+			{Op: service.BatchCreate, Username: "alice", Email: "alice@example.com"},
+			{Op: service.BatchDelete, ID: 7},
+		}).Return([]service.BatchResult{
+			{Index: 0, Op: service.BatchCreate, Status: service.BatchCreated, UserID: 1,
+				User: &entity.User{ID: 1, Username: "alice", Email: "alice@example.com"}},
+			{Index: 1, Op: service.BatchDelete, Status: service.BatchDeleted, UserID: 7},
+		}, nil).Once()
+
+		status, report := post(t, `{"operations":[
+			{"op":"create","username":"alice","email":"alice@example.com"},
+			{"op":"delete","id":7}
+		]}`)
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, "atomic", report.Mode)
+		assert.True(t, report.Applied)
+		assert.Equal(t, map[string]int{"created": 1, "deleted": 1}, report.Summary)
+		require.Len(t, report.Results, 2)
+		require.NotNil(t, report.Results[0].User)
+		assert.Equal(t, "alice", report.Results[0].User.Username)
+		assert.Nil(t, report.Results[1].User)
+	})
+
+	t.Run("Aborted atomic batch", func(t *testing.T) {
+		mockService.On("BatchUsers", service.BatchAtomic, mock.Anything).Return([]service.BatchResult{
+			{Index: 0, Op: service.BatchUpdate, Status: service.BatchNotFound, UserID: 9, Error: service.ErrUserNotFound.Error()},
+			{Index: 1, Op: service.BatchDelete, Status: service.BatchAborted, UserID: 7},
+		}, nil).Once()
+
+		status, report := post(t, `{"mode":"atomic","operations":[{"op":"update","id":9},{"op":"delete","id":7}]}`)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+		assert.False(t, report.Applied)
+		assert.Equal(t, "user not found", report.Results[0].Error)
+	})
+
+	t.Run("Best effort", func(t *testing.T) {
+		mockService.On("BatchUsers", service.BatchBestEffort, mock.Anything).Return([]service.BatchResult{
+			{Index: 0, Op: service.BatchCreate, Status: service.BatchConflict, Error: service.ErrUserAlreadyExists.Error()},
+		}, nil).Once()
+
+		status, report := post(t, `{"mode":"best_effort","operations":[{"op":"create","username":"bob","email":"bob@example.com"}]}`)
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.False(t, report.Applied)
+		assert.Equal(t, 1, report.Summary["conflict"])
+	})
+
+	t.Run("Bad requests", func(t *testing.T) {
+		mockService.On("BatchUsers", service.BatchBestEffort, mock.Anything).Return(nil, service.ErrBatchTooLarge).Once()
+
+		for _, body := range []string{
+			`{"operations":[]}`,
+			`{"mode":"sometimes","operations":[{"op":"delete","id":1}]}`,
+			`{"operations":`,
+			`{"mode":"best_effort","operations":[{"op":"delete","id":1}]}`,
+		} {
+			status, _ := post(t, body)
+			assert.Equal(t, fiber.StatusBadRequest, status, body)
+		}
+		mockService.AssertExpectations(t)
+	})
+}
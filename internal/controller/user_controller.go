@@ -1,18 +1,41 @@
 package controller
 
 import (
+	"errors"
+	"multilayer/internal/entity"
 	"multilayer/internal/service"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type UserController struct {
 	userService service.UserServiceInterface
+	// CacheControl - значение Cache-Control для кэшируемых GET-маршрутов по
+	// шаблону пути, как он зарегистрирован (например "/users/:id"); маршрут
+	// без записи отдается без Cache-Control
+	CacheControl map[string]string
+	// Vary - заголовки, от которых зависит ответ кэшируемых маршрутов: те, из
+	// которых определяется тенант, и Authorization. Без них общий кэш отдал бы
+	// пользователя одного тенанта запросу другого
+	Vary []string
+	// ImportLimit ограничивает файл POST /admin/users:import
+	ImportLimit ImportLimit
+	// LookupLimit расходует лимит POST /users:lookup по токену на каждый
+	// запрошенный username и email (см. RateLimiter.AllowN); false - 429.
+	// nil - без ограничения
+	LookupLimit func(ctx *fiber.Ctx, identifiers int) bool
 }
 
 func NewUserController(userService service.UserServiceInterface) *UserController {
-	return &UserController{userService: userService}
+	return &UserController{
+		userService:  userService,
+		CacheControl: DefaultCacheControlRoutes(),
+		Vary:         DefaultVary(),
+		ImportLimit:  ImportLimit{Rows: DefaultMaxImportRows, Bytes: DefaultMaxImportBytes},
+	}
 }
 
 func (c *UserController) UpdateUser(ctx *fiber.Ctx) error {
@@ -36,14 +59,29 @@ func (c *UserController) UpdateUser(ctx *fiber.Ctx) error {
 	}
 
 	// Вызываем сервис
-	user, err := c.userService.UpdateUser(uint(id), input.Username, input.Email)
-	if err != nil {
+	user, err := c.userService.UpdateUser(ctx.UserContext(), uint(id), input.Username, input.Email)
+	var validationErr *entity.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"field": validationErr.Field,
+		})
+	case errors.Is(err, service.ErrUserNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	case errors.Is(err, service.ErrUserAlreadyExists):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return ctx.JSON(user)
+	return ctx.JSON(NewUserResponse(user))
 }
 
 func (c *UserController) Register(ctx *fiber.Ctx) error {
@@ -58,23 +96,152 @@ func (c *UserController) Register(ctx *fiber.Ctx) error {
 		})
 	}
 
-	user, err := c.userService.RegisterUser(input.Username, input.Email)
-	if err != nil {
+	user, err := c.userService.RegisterUser(ctx.UserContext(), input.Username, input.Email)
+	var validationErr *entity.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"field": validationErr.Field,
+		})
+	case errors.Is(err, service.ErrUserAlreadyExists):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return ctx.Status(fiber.StatusCreated).JSON(user)
+	return ctx.Status(fiber.StatusCreated).JSON(NewUserResponse(user))
 }
 
+// GetUser возвращает пользователя; с параметром as_of (RFC3339) - в состоянии на этот момент.
+// Поддерживает условные запросы: If-None-Match и If-Modified-Since дают 304 без тела
 func (c *UserController) GetUser(ctx *fiber.Ctx) error {
 	id, _ := strconv.Atoi(ctx.Params("id"))
-	user, err := c.userService.GetUser(uint(id))
+
+	var (
+		user *entity.User
+		err  error
+	)
+	if asOf := ctx.Query("as_of"); asOf != "" {
+		at, parseErr := time.Parse(time.RFC3339, asOf)
+		if parseErr != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "as_of must be an RFC3339 timestamp",
+			})
+		}
+		user, err = c.userService.GetUserAt(ctx.UserContext(), uint(id), at)
+	} else {
+		user, err = c.userService.GetUser(ctx.UserContext(), uint(id))
+	}
 	if err != nil {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
-	return ctx.JSON(user)
+	return c.sendCacheable(ctx, NewUserResponse(user), user.UpdatedAt)
+}
+
+// UpdateProfile заменяет поля профиля: display_name, locale, timezone, avatar_url
+func (c *UserController) UpdateProfile(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	var input struct {
+		DisplayName string `json:"display_name"`
+		Locale      string `json:"locale"`
+		Timezone    string `json:"timezone"`
+		AvatarURL   string `json:"avatar_url"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	user, err := c.userService.UpdateProfile(ctx.UserContext(), uint(id), entity.UserProfile{
+		DisplayName: input.DisplayName,
+		Locale:      input.Locale,
+		Timezone:    input.Timezone,
+		AvatarURL:   input.AvatarURL,
+	})
+	var validationErr *entity.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"field": validationErr.Field,
+		})
+	case errors.Is(err, service.ErrUserNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.JSON(NewUserResponse(user))
+}
+
+// UpsertUserByEmail создает или обновляет пользователя с email из пути: username
+// и поля профиля заменяются значениями из тела. Ответ 201 - пользователь создан,
+// 200 - обновлен
+func (c *UserController) UpsertUserByEmail(ctx *fiber.Ctx) error {
+	email, err := url.PathUnescape(ctx.Params("email"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid email",
+		})
+	}
+
+	var input struct {
+		Username    string `json:"username"`
+		DisplayName string `json:"display_name"`
+		Locale      string `json:"locale"`
+		Timezone    string `json:"timezone"`
+		AvatarURL   string `json:"avatar_url"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	user, created, err := c.userService.UpsertUserByEmail(ctx.UserContext(), email, input.Username, entity.UserProfile{
+		DisplayName: input.DisplayName,
+		Locale:      input.Locale,
+		Timezone:    input.Timezone,
+		AvatarURL:   input.AvatarURL,
+	})
+	var validationErr *entity.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"field": validationErr.Field,
+		})
+	case errors.Is(err, service.ErrUserAlreadyExists):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if created {
+		ctx.Location("/users/" + strconv.FormatUint(uint64(user.ID), 10))
+		return ctx.Status(fiber.StatusCreated).JSON(NewUserResponse(user))
+	}
+	return ctx.JSON(NewUserResponse(user))
 }
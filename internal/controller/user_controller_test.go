@@ -3,50 +3,28 @@ package controller_test
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"multilayer/internal/controller"
 	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"multilayer/internal/service/mocks"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-type MockUserService struct {
-	mock.Mock
-}
-
-func (m *MockUserService) UpdateUser(id uint, username, email string) (*entity.User, error) {
-	args := m.Called(id, username, email)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*entity.User), args.Error(1)
-}
-
-func (m *MockUserService) RegisterUser(username, email string) (*entity.User, error) {
-	args := m.Called(username, email)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*entity.User), args.Error(1)
-}
-
-func (m *MockUserService) GetUser(id uint) (*entity.User, error) {
-	args := m.Called(id)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*entity.User), args.Error(1)
-}
-
 func TestUserController_UpdateUser(t *testing.T) {
 	// Создаем Fiber app для тестов
 	app := fiber.New()
 
 	// Мок сервиса
-	mockService := new(MockUserService)
+	mockService := new(mocks.UserService)
 	userController := controller.NewUserController(mockService)
 
 	// Тестовый маршрут
@@ -82,4 +60,299 @@ func TestUserController_UpdateUser(t *testing.T) {
 		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 		mockService.AssertExpectations(t)
 	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mockService.On("UpdateUser", uint(2), "new", "new@example.com").
+			Return(nil, service.ErrUserNotFound)
+
+		req := httptest.NewRequest("PUT", "/users/2", strings.NewReader(`{"username":"new","email":"new@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		mockService.On("UpdateUser", uint(3), "taken", "taken@example.com").
+			Return(nil, service.ErrUserAlreadyExists)
+
+		req := httptest.NewRequest("PUT", "/users/3", strings.NewReader(`{"username":"taken","email":"taken@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Validation error", func(t *testing.T) {
+		mockService.On("UpdateUser", uint(4), "admin", "admin@example.com").
+			Return(nil, &entity.ValidationError{Field: "username", Message: "username is reserved"})
+
+		req := httptest.NewRequest("PUT", "/users/4", strings.NewReader(`{"username":"admin","email":"admin@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "username", body["field"])
+	})
+}
+
+func TestUserController_Register(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Post("/users", userController.Register)
+
+	register := func(body string) *http.Response {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("RegisterUser", "john_doe", "john@example.com").
+			Return(&entity.User{ID: 1, Username: "john_doe", Email: "john@example.com"}, nil)
+
+		resp := register(`{"username":"john_doe","email":"john@example.com"}`)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		mockService.On("RegisterUser", "john_doe", "other@example.com").
+			Return(nil, service.ErrUserAlreadyExists)
+
+		resp := register(`{"username":"john_doe","email":"other@example.com"}`)
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Validation error", func(t *testing.T) {
+		mockService.On("RegisterUser", "root", "root@example.com").
+			Return(nil, &entity.ValidationError{Field: "username", Message: "username is reserved"})
+
+		resp := register(`{"username":"root","email":"root@example.com"}`)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "username", body["field"])
+	})
+}
+
+func TestUserController_GetUserAsOf(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Get("/users/:id", userController.GetUser)
+
+	t.Run("Success", func(t *testing.T) {
+		at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		mockService.On("GetUserAt", uint(1), at).
+			Return(&entity.User{ID: 1, Username: "old", Email: "old@example.com"}, nil)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/1?as_of=2024-03-01T12:00:00Z", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid as_of", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/1?as_of=yesterday", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestUserController_UpdateProfile(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Put("/users/:id/profile", userController.UpdateProfile)
+
+	t.Run("Success", func(t *testing.T) {
+		profile := entity.UserProfile{DisplayName: "John", Locale: "en-US", Timezone: "Europe/Moscow"}
+		mockService.On("UpdateProfile", uint(1), profile).Return(&entity.User{
+			ID: 1, Username: "john_doe", Email: "john@example.com",
+			DisplayName: "John", Locale: "en-US", Timezone: "Europe/Moscow",
+		}, nil)
+
+		jsonBody, _ := json.Marshal(map[string]string{"display_name": "John", "locale": "en-US", "timezone": "Europe/Moscow"})
+		req := httptest.NewRequest("PUT", "/users/1/profile", bytes.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var body controller.UserResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "John", body.DisplayName)
+		assert.Equal(t, "Europe/Moscow", body.Timezone)
+	})
+
+	t.Run("Validation error", func(t *testing.T) {
+		mockService.On("UpdateProfile", uint(2), entity.UserProfile{Timezone: "Mars/Olympus"}).
+			Return(nil, &entity.ValidationError{Field: "timezone", Message: "timezone must be a valid IANA time zone name"})
+
+		req := httptest.NewRequest("PUT", "/users/2/profile", strings.NewReader(`{"timezone":"Mars/Olympus"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestUserController_UpsertUserByEmail(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Put("/users/by-email/:email", userController.UpsertUserByEmail)
+
+	upsert := func(email, body string) *http.Response {
+		req := httptest.NewRequest("PUT", "/users/by-email/"+email, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	profile := entity.UserProfile{DisplayName: "John"}
+	mockService.On("UpsertUserByEmail", "john@example.com", "john_doe", profile).
+		Return(&entity.User{ID: 5, Username: "john_doe", Email: "john@example.com", DisplayName: "John"}, true, nil).Once()
+	mockService.On("UpsertUserByEmail", "john@example.com", "john_doe", profile).
+		Return(&entity.User{ID: 5, Username: "john_doe", Email: "john@example.com", DisplayName: "John"}, false, nil).Once()
+	mockService.On("UpsertUserByEmail", "jane@example.com", "john_doe", entity.UserProfile{}).
+		Return(nil, false, service.ErrUserAlreadyExists).Once()
+
+	// @ в пути может прийти в процентной кодировке
+	resp := upsert("john%40example.com", `{"username":"john_doe","display_name":"John"}`)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/users/5", resp.Header.Get("Location"))
+
+	resp = upsert("john@example.com", `{"username":"john_doe","display_name":"John"}`)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var body controller.UserResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, uint(5), body.ID)
+
+	resp = upsert("jane@example.com", `{"username":"john_doe"}`)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestUserController_SearchUsers(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Get("/users/search", userController.SearchUsers)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("SearchUsers", "ali", 20).Return([]service.UserSearchResult{{
+			User:       entity.User{ID: 1, Username: "alice", Email: "alice@example.com"},
+			Score:      1,
+			Highlights: map[string]string{"username": "<mark>ali</mark>ce"},
+		}}, nil)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/search?q=ali", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body struct {
+			Results []controller.UserSearchResultResponse `json:"results"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Len(t, body.Results, 1)
+		assert.Equal(t, "alice", body.Results[0].User.Username)
+		assert.Equal(t, "<mark>ali</mark>ce", body.Results[0].Highlights["username"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Empty query", func(t *testing.T) {
+		mockService.On("SearchUsers", "", 20).
+			Return(nil, &entity.ValidationError{Field: "q", Message: "search query is required"})
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/search", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/search?q=ali&limit=500", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestUserController_GetUserConditional(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Get("/users/:id", userController.GetUser)
+
+	updatedAt := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	mockService.On("GetUser", uint(1)).
+		Return(&entity.User{ID: 1, Username: "alice", Email: "alice@example.com", UpdatedAt: updatedAt}, nil)
+
+	get := func(headers map[string]string) *http.Response {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	first := get(nil)
+	assert.Equal(t, fiber.StatusOK, first.StatusCode)
+	etag := first.Header.Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	assert.Equal(t, "Fri, 01 Mar 2024 12:00:00 GMT", first.Header.Get("Last-Modified"))
+	assert.Equal(t, controller.DefaultCacheControl, first.Header.Get("Cache-Control"))
+	assert.Equal(t, "X-Tenant, Authorization", first.Header.Get("Vary"))
+	assert.Equal(t, etag, get(nil).Header.Get("ETag"), "ETag must be stable")
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected int
+	}{
+		{"Matching ETag", map[string]string{"If-None-Match": etag}, fiber.StatusNotModified},
+		{"Weak matching ETag in list", map[string]string{"If-None-Match": `"other", W/` + etag}, fiber.StatusNotModified},
+		{"Any ETag", map[string]string{"If-None-Match": "*"}, fiber.StatusNotModified},
+		{"Stale ETag", map[string]string{"If-None-Match": `"other"`}, fiber.StatusOK},
+		{"Not modified since", map[string]string{"If-Modified-Since": "Fri, 01 Mar 2024 12:00:00 GMT"}, fiber.StatusNotModified},
+		{"Modified since", map[string]string{"If-Modified-Since": "Fri, 01 Mar 2024 11:59:59 GMT"}, fiber.StatusOK},
+		{"Invalid date", map[string]string{"If-Modified-Since": "yesterday"}, fiber.StatusOK},
+		// If-None-Match важнее If-Modified-Since
+		{"Stale ETag wins over date", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": "Fri, 01 Mar 2024 12:00:00 GMT"}, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := get(tt.headers)
+			assert.Equal(t, tt.expected, resp.StatusCode)
+			assert.Equal(t, etag, resp.Header.Get("ETag"))
+			if tt.expected == fiber.StatusNotModified {
+				body, _ := io.ReadAll(resp.Body)
+				assert.Empty(t, body)
+				assert.Equal(t, controller.DefaultCacheControl, resp.Header.Get("Cache-Control"))
+				assert.Equal(t, "X-Tenant, Authorization", resp.Header.Get("Vary"))
+			}
+		})
+	}
+
+	t.Run("Cache-Control per route", func(t *testing.T) {
+		userController.CacheControl["/users/:id"] = "private, max-age=30"
+		assert.Equal(t, "private, max-age=30", get(nil).Header.Get("Cache-Control"))
+
+		delete(userController.CacheControl, "/users/:id")
+		assert.Empty(t, get(nil).Header.Get("Cache-Control"))
+	})
 }
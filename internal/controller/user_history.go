@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"errors"
+	"multilayer/internal/audit"
+	"multilayer/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestMetadata кладет ID запроса в контекст для журнала аудита. ID берется из
+// ответа middleware requestid или из заголовка запроса. Инициатор до проверки
+// токена - anonymous; Authenticate заменяет его на claim sub
+func RequestMetadata(ctx *fiber.Ctx) error {
+	requestID := ctx.GetRespHeader(fiber.HeaderXRequestID, ctx.Get(fiber.HeaderXRequestID))
+	ctx.SetUserContext(audit.WithMetadata(ctx.UserContext(), audit.Metadata{
+		RequestID: requestID,
+	}))
+	return ctx.Next()
+}
+
+// GetUserHistory возвращает журнал изменений пользователя, новые записи первыми.
+// Параметры: limit (по умолчанию 50) и before - ID, с которого продолжить
+func (c *UserController) GetUserHistory(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+	before, limit, err := parseBeforePage(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	entries, err := c.userService.GetUserHistory(ctx.UserContext(), uint(id), before, limit)
+	if errors.Is(err, service.ErrUserNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := fiber.Map{"entries": entries}
+	if len(entries) == limit {
+		response["next_before"] = entries[len(entries)-1].ID
+	}
+	return ctx.JSON(response)
+}
@@ -0,0 +1,57 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"multilayer/internal/service/mocks"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserController_GetUserHistory(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Get("/users/:id/history", userController.GetUserHistory)
+
+	t.Run("Success", func(t *testing.T) {
+		entries := []entity.UserAuditEntry{
+			{ID: 7, UserID: 1, Action: entity.UserUpdated, Actor: "admin"},
+			{ID: 3, UserID: 1, Action: entity.UserRegistered, Actor: "admin"},
+		}
+		mockService.On("GetUserHistory", uint(1), uint(9), 2).Return(entries, nil)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/1/history?limit=2&before=9", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var body struct {
+			Entries    []entity.UserAuditEntry `json:"entries"`
+			NextBefore uint                    `json:"next_before"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Len(t, body.Entries, 2)
+		assert.Equal(t, uint(3), body.NextBefore)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mockService.On("GetUserHistory", uint(2), uint(0), 50).Return(nil, service.ErrUserNotFound)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/2/history", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/1/history?limit=0", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
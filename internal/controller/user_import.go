@@ -0,0 +1,346 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Ограничения импорта по умолчанию (UserController.ImportLimit): отчет по всем
+// строкам собирается в памяти, поэтому больший файл отклоняется с 413
+const (
+	DefaultMaxImportRows  = 50000
+	DefaultMaxImportBytes = 32 << 20
+)
+
+// ImportLimit - наибольший размер файла импорта
+type ImportLimit struct {
+	Rows  int
+	Bytes int64
+}
+
+var errImportTooLarge = errors.New("import file is too large")
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	mimeCSV    = "text/csv"
+	mimeNDJSON = "application/x-ndjson"
+)
+
+// importReport - ответ POST /admin/users:import
+type importReport struct {
+	Summary map[service.ImportStatus]int `json:"summary"`
+	Results []service.ImportResult       `json:"results"`
+}
+
+// importParser читает входной поток импорта: корректные строки передает в row,
+// непригодные - в invalid. Ошибка row прерывает чтение и возвращается как есть
+type importParser func(r io.Reader, row func(service.ImportRow) error, invalid func(service.ImportResult)) error
+
+// ImportUsers принимает CSV (с заголовком username,email) или NDJSON и
+// возвращает отчет по каждой строке. Тело читается потоком и импортируется
+// порциями по service.ImportBatchSize строк, поэтому при ошибке посреди файла
+// уже обработанные строки остаются в отчете. Файл больше ImportLimit дает 413;
+// если это выяснилось посреди чтения, отчет
+// содержит строки, импортированные до превышения. Idempotency-Key к потоковому
+// телу не применяется (см. Idempotency): повтор сообщит об уже созданных
+// пользователях как о дубликатах
+func (c *UserController) ImportUsers(ctx *fiber.Ctx) error {
+	limit := c.ImportLimit
+	tooLarge := fmt.Sprintf("import must contain at most %d rows and %d bytes", limit.Rows, limit.Bytes)
+	if int64(ctx.Request().Header.ContentLength()) > limit.Bytes {
+		return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": tooLarge,
+		})
+	}
+	var parse importParser
+	switch format := importFormat(ctx); format {
+	case formatCSV:
+		parse = parseCSVImport
+	case formatNDJSON:
+		parse = parseNDJSONImport
+	default:
+		return ctx.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": "Content-Type must be text/csv or application/x-ndjson",
+		})
+	}
+	stream := ctx.Request().BodyStream()
+	if stream == nil {
+		stream = bytes.NewReader(ctx.Body())
+	}
+	body := &importBody{r: stream, remaining: limit.Bytes}
+
+	var (
+		rows      int
+		results   []service.ImportResult
+		batch     = make([]service.ImportRow, 0, service.ImportBatchSize)
+		importErr error
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		batchResults, err := c.userService.ImportUsers(ctx.UserContext(), batch)
+		if err != nil {
+			importErr = err
+			return err
+		}
+		results = append(results, batchResults...)
+		batch = make([]service.ImportRow, 0, service.ImportBatchSize)
+		return nil
+	}
+	err := parse(body, func(row service.ImportRow) error {
+		if rows++; rows > limit.Rows {
+			return errImportTooLarge
+		}
+		batch = append(batch, row)
+		if len(batch) < service.ImportBatchSize {
+			return nil
+		}
+		return flush()
+	}, func(result service.ImportResult) {
+		rows++
+		results = append(results, result)
+	})
+	if err == nil && rows > limit.Rows {
+		err = errImportTooLarge
+	}
+	if body.exceeded || errors.Is(err, errImportTooLarge) {
+		err = errImportTooLarge
+	} else if err == nil {
+		err = flush()
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Row < results[j].Row })
+	report := importReport{
+		Summary: map[service.ImportStatus]int{
+			service.ImportCreated:   0,
+			service.ImportDuplicate: 0,
+			service.ImportInvalid:   0,
+		},
+		Results: results,
+	}
+	for _, result := range results {
+		report.Summary[result.Status]++
+	}
+
+	switch {
+	case errors.Is(err, errImportTooLarge):
+		return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":   tooLarge,
+			"summary": report.Summary,
+			"results": report.Results,
+		})
+	case importErr != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   importErr.Error(),
+			"summary": report.Summary,
+			"results": report.Results,
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   err.Error(),
+			"summary": report.Summary,
+			"results": report.Results,
+		})
+	}
+	return ctx.JSON(report)
+}
+
+// importBody ограничивает тело импорта remaining байтами; после превышения
+// чтение возвращает errImportTooLarge
+type importBody struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (b *importBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		b.exceeded = true
+		return 0, errImportTooLarge
+	}
+	// Читаем на байт больше лимита, чтобы отличить файл ровно в лимит от большего
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		b.exceeded = true
+		return 0, errImportTooLarge
+	}
+	return n, err
+}
+
+// ExportUsers потоково отдает всех пользователей в CSV или NDJSON. Статус 200
+// отправляется до чтения данных, поэтому ошибка посреди выгрузки завершает поток
+// строкой ошибки: {"error": "..."} в NDJSON или записью "#error,<сообщение>" в CSV
+func (c *UserController) ExportUsers(ctx *fiber.Ctx) error {
+	format := ctx.Query("format")
+	if format == "" {
+		format = formatNDJSON
+		if ctx.Accepts(mimeNDJSON, mimeCSV) == mimeCSV {
+			format = formatCSV
+		}
+	}
+
+	switch format {
+	case formatCSV:
+		ctx.Set(fiber.HeaderContentType, mimeCSV+"; charset=utf-8")
+	case formatNDJSON:
+		ctx.Set(fiber.HeaderContentType, mimeNDJSON)
+	default:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or ndjson",
+		})
+	}
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="users.%s"`, format))
+
+	userCtx := ctx.UserContext()
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writeUser := newExportWriter(format, w)
+		err := c.userService.ExportUsers(userCtx, func(user *entity.User) error {
+			if err := writeUser(user); err != nil {
+				return err
+			}
+			return w.Flush()
+		})
+		if err != nil {
+			writeExportError(format, w, err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// writeExportError завершает выгрузку строкой с ошибкой, по которой клиент
+// отличает оборванный файл от полного
+func writeExportError(format string, w io.Writer, err error) {
+	if format == formatCSV {
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write([]string{"#error", err.Error()})
+		csvWriter.Flush()
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// newExportWriter возвращает функцию записи одного пользователя в выбранном формате
+func newExportWriter(format string, w io.Writer) func(user *entity.User) error {
+	if format == formatCSV {
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write([]string{"id", "username", "email", "display_name", "locale", "timezone", "avatar_url", "created_at", "updated_at"})
+		return func(user *entity.User) error {
+			csvWriter.Write([]string{
+				strconv.FormatUint(uint64(user.ID), 10), user.Username, user.Email,
+				user.DisplayName, user.Locale, user.Timezone, user.AvatarURL,
+				user.CreatedAt.UTC().Format(time.RFC3339), user.UpdatedAt.UTC().Format(time.RFC3339),
+			})
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	}
+	encoder := json.NewEncoder(w)
+	return func(user *entity.User) error {
+		return encoder.Encode(NewUserResponse(user))
+	}
+}
+
+func importFormat(ctx *fiber.Ctx) string {
+	contentType := strings.ToLower(ctx.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(contentType, mimeCSV):
+		return formatCSV
+	case strings.HasPrefix(contentType, mimeNDJSON), strings.HasPrefix(contentType, "application/jsonl"):
+		return formatNDJSON
+	}
+	return ""
+}
+
+// parseCSVImport читает CSV с заголовком; колонки username и email обязательны,
+// их порядок произвольный. Номер строки - номер записи без учета заголовка
+func parseCSVImport(r io.Reader, row func(service.ImportRow) error, invalid func(service.ImportResult)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return errors.New("csv header is required")
+	}
+	usernameCol, emailCol := -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "username":
+			usernameCol = i
+		case "email":
+			emailCol = i
+		}
+	}
+	if usernameCol < 0 || emailCol < 0 {
+		return errors.New("csv header must contain username and email columns")
+	}
+
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return err
+			}
+			invalid(service.ImportResult{Row: n, Status: service.ImportInvalid, Error: parseErr.Err.Error()})
+			continue
+		}
+		if usernameCol >= len(record) || emailCol >= len(record) {
+			invalid(service.ImportResult{Row: n, Status: service.ImportInvalid, Error: "missing columns"})
+			continue
+		}
+		if err := row(service.ImportRow{Row: n, Username: record[usernameCol], Email: record[emailCol]}); err != nil {
+			return err
+		}
+	}
+}
+
+// parseNDJSONImport читает по одному JSON-объекту на строку; пустые строки
+// пропускаются, номер строки совпадает с номером строки файла
+func parseNDJSONImport(r io.Reader, row func(service.ImportRow) error, invalid func(service.ImportResult)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var input struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+		}
+		if err := json.Unmarshal(line, &input); err != nil {
+			invalid(service.ImportResult{Row: n, Status: service.ImportInvalid, Error: "invalid json: " + err.Error()})
+			continue
+		}
+		if err := row(service.ImportRow{Row: n, Username: input.Username, Email: input.Email}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,247 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"multilayer/internal/service/mocks"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserController_ImportUsers(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Post("/users\\:import", userController.ImportUsers)
+
+	t.Run("CSV", func(t *testing.T) {
+		mockService.On("ImportUsers", []service.ImportRow{
+			{Row: 1, Username: "alice", Email: "alice@example.com"},
+			{Row: 3, Username: "alice", Email: "alice2@example.com"},
+		}).Return([]service.ImportResult{
+			{Row: 1, Status: service.ImportCreated, UserID: 1},
+			{Row: 3, Status: service.ImportDuplicate, Error: service.ErrUserAlreadyExists.Error()},
+		}, nil).Once()
+
+		body := "email,username\nalice@example.com,alice\nbro\"ken,bob\nalice2@example.com,alice\n"
+		req := httptest.NewRequest("POST", "/users:import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var report struct {
+			Summary map[string]int         `json:"summary"`
+			Results []service.ImportResult `json:"results"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		assert.Equal(t, map[string]int{"created": 1, "duplicate": 1, "invalid": 1}, report.Summary)
+		assert.Len(t, report.Results, 3)
+		assert.Equal(t, service.ImportInvalid, report.Results[1].Status)
+	})
+
+	t.Run("NDJSON with malformed line", func(t *testing.T) {
+		mockService.On("ImportUsers", []service.ImportRow{
+			{Row: 1, Username: "carol", Email: "carol@example.com"},
+		}).Return([]service.ImportResult{
+			{Row: 1, Status: service.ImportCreated, UserID: 2},
+		}, nil).Once()
+
+		body := "{\"username\":\"carol\",\"email\":\"carol@example.com\"}\n{not json}\n"
+		req := httptest.NewRequest("POST", "/users:import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		var report struct {
+			Summary map[string]int         `json:"summary"`
+			Results []service.ImportResult `json:"results"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		assert.Equal(t, 1, report.Summary["created"])
+		assert.Equal(t, 1, report.Summary["invalid"])
+		assert.Equal(t, 2, report.Results[1].Row)
+	})
+
+	t.Run("Unsupported content type", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/users:import", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+}
+
+func TestUserController_ExportUsers(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Get("/users\\:export", userController.ExportUsers)
+
+	mockService.On("ExportUsers", mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(0).(func(user *entity.User) error)
+		createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		fn(&entity.User{ID: 1, Username: "alice", Email: "alice@example.com", Locale: "en", Timezone: "UTC", CreatedAt: createdAt, UpdatedAt: createdAt})
+		fn(&entity.User{ID: 2, Username: "bob", Email: "bob@example.com", DisplayName: "Bob", Locale: "de-DE", Timezone: "Europe/Berlin", CreatedAt: createdAt, UpdatedAt: createdAt})
+	}).Return(nil)
+
+	t.Run("CSV", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users:export?format=csv", nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "id,username,email,display_name,locale,timezone,avatar_url,created_at,updated_at\n"+
+			"1,alice,alice@example.com,,en,UTC,,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z\n"+
+			"2,bob,bob@example.com,Bob,de-DE,Europe/Berlin,,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z\n", string(body))
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users:export", nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		assert.Len(t, lines, 2)
+		assert.JSONEq(t, `{"id":1,"username":"alice","email":"alice@example.com","display_name":"","locale":"en",`+
+			`"timezone":"UTC","created_at":"2024-01-02T03:04:05Z","updated_at":"2024-01-02T03:04:05Z","status":"active"}`, lines[0])
+	})
+}
+
+func TestUserController_ImportUsersStreaming(t *testing.T) {
+	app := fiber.New(fiber.Config{StreamRequestBody: true})
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Use(controller.BufferBody(1024, "/users:import"))
+	app.Post("/users\\:import", userController.ImportUsers)
+	app.Post("/echo", func(ctx *fiber.Ctx) error {
+		return ctx.Send(ctx.Body())
+	})
+
+	created := func(n int) []service.ImportResult {
+		results := make([]service.ImportResult, n)
+		for i := range results {
+			results[i].Status = service.ImportCreated
+		}
+		return results
+	}
+	batchOf := func(n int) interface{} {
+		return mock.MatchedBy(func(rows []service.ImportRow) bool { return len(rows) == n })
+	}
+	mockService.On("ImportUsers", batchOf(service.ImportBatchSize)).Return(created(service.ImportBatchSize), nil).Twice()
+	mockService.On("ImportUsers", batchOf(50)).Return(created(50), nil).Once()
+
+	// Тело больше лимита BufferBody: импорт читает его потоком порциями
+	var body strings.Builder
+	for i := 0; i < 2*service.ImportBatchSize+50; i++ {
+		fmt.Fprintf(&body, "{\"username\":\"user%d\",\"email\":\"user%d@example.com\"}\n", i, i)
+	}
+	req := httptest.NewRequest("POST", "/users:import", strings.NewReader(body.String()))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var report struct {
+		Summary map[string]int `json:"summary"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	assert.Equal(t, 2*service.ImportBatchSize+50, report.Summary["created"])
+	mockService.AssertExpectations(t)
+
+	// Остальные маршруты по-прежнему ограничены
+	resp, err = app.Test(httptest.NewRequest("POST", "/echo", strings.NewReader(strings.Repeat("x", 2048))))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("POST", "/echo", strings.NewReader("small")))
+	require.NoError(t, err)
+	echoed, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "small", string(echoed))
+}
+
+func TestUserController_ExportUsersError(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Get("/users\\:export", userController.ExportUsers)
+
+	mockService.On("ExportUsers", mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(0).(func(user *entity.User) error)
+		fn(&entity.User{ID: 1, Username: "alice", Email: "alice@example.com"})
+	}).Return(errors.New("connection reset"))
+
+	t.Run("NDJSON", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users:export", nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		require.Len(t, lines, 2)
+		assert.JSONEq(t, `{"error":"connection reset"}`, lines[1])
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users:export?format=csv", nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.True(t, strings.HasSuffix(string(body), "#error,connection reset\n"), string(body))
+	})
+}
+
+func TestUserController_ImportUsersLimit(t *testing.T) {
+	app := fiber.New(fiber.Config{StreamRequestBody: true})
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	userController.ImportLimit = controller.ImportLimit{Rows: 3, Bytes: 256}
+	app.Use(controller.BufferBody(1024, "/users:import"))
+	app.Post("/users\\:import", userController.ImportUsers)
+
+	ndjson := func(rows int) string {
+		var body strings.Builder
+		for i := 0; i < rows; i++ {
+			fmt.Fprintf(&body, "{\"username\":\"user%d\",\"email\":\"u%d@example.com\"}\n", i, i)
+		}
+		return body.String()
+	}
+	mockService.On("ImportUsers", mock.Anything).Return([]service.ImportResult{
+		{Row: 1, Status: service.ImportCreated}, {Row: 2, Status: service.ImportCreated}, {Row: 3, Status: service.ImportCreated},
+	}, nil).Once()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"Within limit", ndjson(3), fiber.StatusOK},
+		{"Too many rows", ndjson(4), fiber.StatusRequestEntityTooLarge},
+		{"Too many bytes", strings.Repeat(" ", 257), fiber.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/users:import", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-ndjson")
+			resp, err := app.Test(req, -1)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+		})
+	}
+	// Строки сверх лимита не импортируются
+	mockService.AssertNumberOfCalls(t, "ImportUsers", 1)
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userLookupResponse - ответ POST /users:lookup: каждому запрошенному значению
+// соответствует пользователь или null, если он не найден
+type userLookupResponse struct {
+	Usernames map[string]*UserResponse `json:"usernames"`
+	Emails    map[string]*UserResponse `json:"emails"`
+}
+
+// GetUserByUsername возвращает пользователя по username без учета регистра
+func (c *UserController) GetUserByUsername(ctx *fiber.Ctx) error {
+	return c.getUserBy(ctx, "username", c.userService.GetUserByUsername)
+}
+
+// GetUserByEmail возвращает пользователя по email без учета регистра
+func (c *UserController) GetUserByEmail(ctx *fiber.Ctx) error {
+	return c.getUserBy(ctx, "email", c.userService.GetUserByEmail)
+}
+
+// getUserBy отдает пользователя, найденного find по параметру пути param,
+// с теми же условными ответами, что и GetUser
+func (c *UserController) getUserBy(ctx *fiber.Ctx, param string, find func(ctx context.Context, value string) (*entity.User, error)) error {
+	value, err := url.PathUnescape(ctx.Params(param))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + param,
+		})
+	}
+
+	user, err := find(ctx.UserContext(), value)
+	if errors.Is(err, service.ErrUserNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.sendCacheable(ctx, NewUserResponse(user), user.UpdatedAt)
+}
+
+// LookupUsers находит пользователей по спискам usernames и emails одним запросом
+func (c *UserController) LookupUsers(ctx *fiber.Ctx) error {
+	var input struct {
+		Usernames []string `json:"usernames"`
+		Emails    []string `json:"emails"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(input.Usernames) == 0 && len(input.Emails) == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "usernames or emails are required",
+		})
+	}
+
+	if c.LookupLimit != nil && !c.LookupLimit(ctx, len(input.Usernames)+len(input.Emails)) {
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "rate limit exceeded",
+		})
+	}
+
+	result, err := c.userService.LookupUsers(ctx.UserContext(), input.Usernames, input.Emails)
+	if errors.Is(err, service.ErrLookupTooLarge) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := userLookupResponse{
+		Usernames: lookupResponses(input.Usernames, result.ByUsername),
+		Emails:    lookupResponses(input.Emails, result.ByEmail),
+	}
+	return ctx.JSON(response)
+}
+
+func lookupResponses(values []string, found map[string]*entity.User) map[string]*UserResponse {
+	responses := make(map[string]*UserResponse, len(values))
+	for _, value := range values {
+		responses[value] = nil
+		if user, ok := found[value]; ok {
+			response := NewUserResponse(user)
+			responses[value] = &response
+		}
+	}
+	return responses
+}
@@ -0,0 +1,116 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/ratelimit"
+	"multilayer/internal/service"
+	"multilayer/internal/service/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserController_GetUserByUsernameAndEmail(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Get("/users/by-username/:username", userController.GetUserByUsername)
+	app.Get("/users/by-email/:email", userController.GetUserByEmail)
+
+	john := &entity.User{ID: 1, Username: "john_doe", Email: "john@example.com"}
+	mockService.On("GetUserByUsername", "John_Doe").Return(john, nil)
+	mockService.On("GetUserByEmail", "john@example.com").Return(john, nil)
+	mockService.On("GetUserByUsername", "ghost").Return(nil, service.ErrUserNotFound)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/by-username/John_Doe", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, controller.DefaultCacheControl, resp.Header.Get("Cache-Control"))
+	assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/users/by-email/john%40example.com", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var body controller.UserResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "john_doe", body.Username)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/users/by-username/ghost", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestUserController_LookupUsers(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Post("/users\\:lookup", userController.LookupUsers)
+
+	john := &entity.User{ID: 1, Username: "john_doe", Email: "john@example.com"}
+	mockService.On("LookupUsers", []string{"JOHN_DOE", "ghost"}, []string{"john@example.com"}).Return(&service.UserLookupResult{
+		ByUsername: map[string]*entity.User{"JOHN_DOE": john},
+		ByEmail:    map[string]*entity.User{"john@example.com": john},
+	}, nil)
+
+	req := httptest.NewRequest("POST", "/users:lookup", strings.NewReader(`{"usernames":["JOHN_DOE","ghost"],"emails":["john@example.com"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Usernames map[string]*controller.UserResponse `json:"usernames"`
+		Emails    map[string]*controller.UserResponse `json:"emails"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Contains(t, body.Usernames, "ghost")
+	assert.Nil(t, body.Usernames["ghost"])
+	assert.Equal(t, uint(1), body.Usernames["JOHN_DOE"].ID)
+	assert.Equal(t, uint(1), body.Emails["john@example.com"].ID)
+
+	req = httptest.NewRequest("POST", "/users:lookup", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestUserController_LookupUsersLimit(t *testing.T) {
+	app := fiber.New(fiber.Config{ProxyHeader: "X-Forwarded-For"})
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	limiter := controller.NewRateLimiter(ratelimit.NewMemoryStore())
+	limit := ratelimit.Limit{Requests: 5, Period: time.Minute}
+	userController.LookupLimit = func(ctx *fiber.Ctx, identifiers int) bool {
+		return limiter.AllowN(ctx, "availability", limit, controller.ByIP, identifiers)
+	}
+	app.Post("/users\\:lookup", userController.LookupUsers)
+	mockService.On("LookupUsers", []string{"a", "b", "c"}, []string{"d@example.com"}).
+		Return(&service.UserLookupResult{}, nil)
+
+	lookup := func() *http.Response {
+		req := httptest.NewRequest("POST", "/users:lookup", strings.NewReader(`{"usernames":["a","b","c"],"emails":["d@example.com"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Четыре идентификатора расходуют четыре токена из пяти
+	resp := lookup()
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("RateLimit-Remaining"))
+	resp = lookup()
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	mockService.AssertNumberOfCalls(t, "LookupUsers", 1)
+}
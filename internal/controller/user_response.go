@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"multilayer/internal/entity"
+	"time"
+)
+
+// UserResponse - представление пользователя в REST API. Отделяет формат ответа
+// от модели хранения: новые колонки не попадают в API без явного решения
+type UserResponse struct {
+	ID              uint              `json:"id"`
+	Username        string            `json:"username"`
+	Email           string            `json:"email"`
+	DisplayName     string            `json:"display_name"`
+	Locale          string            `json:"locale"`
+	Timezone        string            `json:"timezone"`
+	AvatarURL       string            `json:"avatar_url,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	Status          entity.UserStatus `json:"status"`
+	StatusReason    string            `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time        `json:"status_changed_at,omitempty"`
+}
+
+func NewUserResponse(user *entity.User) UserResponse {
+	return UserResponse{
+		ID:              user.ID,
+		Username:        user.Username,
+		Email:           user.Email,
+		DisplayName:     user.DisplayName,
+		Locale:          user.Locale,
+		Timezone:        user.Timezone,
+		AvatarURL:       user.AvatarURL,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		Status:          user.EffectiveStatus(),
+		StatusReason:    user.StatusReason,
+		StatusChangedAt: user.StatusChangedAt,
+	}
+}
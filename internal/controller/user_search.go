@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"errors"
+	"multilayer/internal/entity"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// UserSearchResultResponse - найденный пользователь в ответе поиска
+type UserSearchResultResponse struct {
+	User       UserResponse      `json:"user"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchUsers ищет пользователей по частичному совпадению или с опечатками.
+// Параметры: q - запрос, limit (по умолчанию 20, не больше 100). В highlights
+// совпадения выделены тегами <mark>, остальной текст экранирован для HTML
+func (c *UserController) SearchUsers(ctx *fiber.Ctx) error {
+	limit := ctx.QueryInt("limit", defaultSearchLimit)
+	if limit <= 0 || limit > maxSearchLimit {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit),
+		})
+	}
+
+	results, err := c.userService.SearchUsers(ctx.UserContext(), ctx.Query("q"), limit)
+	if err != nil {
+		var validationErr *entity.ValidationError
+		if errors.As(err, &validationErr) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": validationErr.Error(),
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := make([]UserSearchResultResponse, len(results))
+	for i := range results {
+		response[i] = UserSearchResultResponse{
+			User:       NewUserResponse(&results[i].User),
+			Score:      results[i].Score,
+			Highlights: results[i].Highlights,
+		}
+	}
+	return ctx.JSON(fiber.Map{"results": response})
+}
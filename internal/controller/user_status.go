@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChangeUserStatus - административный перевод пользователя в другой статус.
+// Тело: {"status": "suspended", "reason": "..."}; запрещенный переход дает 409
+func (c *UserController) ChangeUserStatus(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	var input struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var user *entity.User
+	status, err := entity.ParseUserStatus(input.Status)
+	if err == nil {
+		user, err = c.userService.ChangeUserStatus(ctx.UserContext(), uint(id), status, input.Reason)
+	}
+	var validationErr *entity.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"field": validationErr.Field,
+		})
+	case errors.Is(err, service.ErrUserNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	case errors.Is(err, entity.ErrInvalidStatusTransition):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.JSON(NewUserResponse(user))
+}
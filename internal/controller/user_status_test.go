@@ -0,0 +1,144 @@
+package controller_test
+
+import (
+	"fmt"
+	"io"
+	"multilayer/internal/audit"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"multilayer/internal/service/mocks"
+	"multilayer/internal/tenancy"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserController_ChangeUserStatus(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	userController := controller.NewUserController(mockService)
+	app.Post("/admin/users/:id/status", userController.ChangeUserStatus)
+
+	mockService.On("ChangeUserStatus", uint(1), entity.UserSuspended, "spam").
+		Return(&entity.User{ID: 1, Username: "spammer", Status: entity.UserSuspended, StatusReason: "spam"}, nil)
+	mockService.On("ChangeUserStatus", uint(2), entity.UserLocked, "x").
+		Return(nil, fmt.Errorf("%w: deactivated -> locked", entity.ErrInvalidStatusTransition))
+	mockService.On("ChangeUserStatus", uint(3), entity.UserSuspended, "").
+		Return(nil, &entity.ValidationError{Field: "reason", Message: "reason is required for status suspended"})
+	mockService.On("ChangeUserStatus", uint(4), entity.UserActive, "").Return(nil, service.ErrUserNotFound)
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"Suspend", "/admin/users/1/status", `{"status":"suspended","reason":"spam"}`, fiber.StatusOK, `"status":"suspended"`},
+		{"Invalid transition", "/admin/users/2/status", `{"status":"locked","reason":"x"}`, fiber.StatusConflict, "not allowed"},
+		{"Missing reason", "/admin/users/3/status", `{"status":"suspended"}`, fiber.StatusBadRequest, `"field":"reason"`},
+		{"Unknown status", "/admin/users/1/status", `{"status":"banned"}`, fiber.StatusBadRequest, `"field":"status"`},
+		{"Not found", "/admin/users/4/status", `{"status":"active"}`, fiber.StatusNotFound, "User not found"},
+		{"Invalid ID", "/admin/users/abc/status", `{"status":"active"}`, fiber.StatusBadRequest, "Invalid ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCode, resp.StatusCode, string(body))
+			assert.Contains(t, string(body), tt.wantBody)
+		})
+	}
+	mockService.AssertNumberOfCalls(t, "ChangeUserStatus", 4)
+}
+
+func TestAuthenticate(t *testing.T) {
+	app := fiber.New()
+	mockService := new(mocks.UserService)
+	app.Use(controller.RequestMetadata)
+	app.Use(controller.Authenticate(mockService, &tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"}))
+	app.Get("/ping", func(ctx *fiber.Ctx) error {
+		return ctx.SendString(audit.FromContext(ctx.UserContext()).Actor)
+	})
+
+	mockService.On("AuthenticateUser", uint(1)).Return(&entity.User{ID: 1, Status: entity.UserActive}, nil)
+	mockService.On("AuthenticateUser", uint(2)).Return(nil, fmt.Errorf("%w: suspended", service.ErrUserInactive))
+	mockService.On("AuthenticateUser", uint(3)).Return(nil, service.ErrUserNotFound)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      int
+		wantActor     string
+	}{
+		{"Without token", "", fiber.StatusOK, audit.AnonymousActor},
+		{"Token without subject", "Bearer " + signTenantToken(map[string]any{"tenant": "acme"}), fiber.StatusOK, audit.AnonymousActor},
+		{"Active user", "Bearer " + signTenantToken(map[string]any{"sub": "1"}), fiber.StatusOK, "user:1"},
+		{"Suspended user", "Bearer " + signTenantToken(map[string]any{"sub": "2"}), fiber.StatusForbidden, ""},
+		{"Unknown user", "Bearer " + signTenantToken(map[string]any{"sub": "3"}), fiber.StatusUnauthorized, ""},
+		{"Malformed subject", "Bearer " + signTenantToken(map[string]any{"sub": "alice"}), fiber.StatusUnauthorized, ""},
+		{"Invalid signature", "Bearer " + signTenantToken(map[string]any{"sub": "1"}) + "x", fiber.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/ping", nil)
+			// Заявленный клиентом инициатор в журнал аудита не попадает
+			req.Header.Set("X-Actor", "admin")
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+			if tt.wantActor != "" {
+				body, _ := io.ReadAll(resp.Body)
+				assert.Equal(t, tt.wantActor, string(body))
+			}
+		})
+	}
+	mockService.AssertNumberOfCalls(t, "AuthenticateUser", 3)
+}
+
+func TestRequireToken(t *testing.T) {
+	app := fiber.New()
+	app.Use(controller.RequireToken(&tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"}))
+	app.Get("/ping", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      int
+	}{
+		{"Without token", "", fiber.StatusUnauthorized},
+		{"Not a bearer token", "Basic YWxpY2U6c2VjcmV0", fiber.StatusUnauthorized},
+		{"Invalid signature", "Bearer " + signTenantToken(map[string]any{"sub": "1"}) + "x", fiber.StatusUnauthorized},
+		{"Service token", "Bearer " + signTenantToken(map[string]any{"tenant": "acme"}), fiber.StatusOK},
+		{"User token", "Bearer " + signTenantToken(map[string]any{"sub": "1"}), fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/ping", nil)
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+		})
+	}
+}
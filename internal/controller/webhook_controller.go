@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookController struct {
+	webhookService service.WebhookServiceInterface
+}
+
+func NewWebhookController(webhookService service.WebhookServiceInterface) *WebhookController {
+	return &WebhookController{webhookService: webhookService}
+}
+
+// CreateSubscription создает подписку. Секрет возвращается только в этом ответе
+func (c *WebhookController) CreateSubscription(ctx *fiber.Ctx) error {
+	var input struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+		Secret     string   `json:"secret"`
+	}
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	subscription, err := c.webhookService.CreateSubscription(ctx.UserContext(), input.URL, input.EventTypes, input.Secret)
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(struct {
+		*entity.WebhookSubscription
+		Secret string `json:"secret"`
+	}{subscription, subscription.Secret})
+}
+
+func (c *WebhookController) ListSubscriptions(ctx *fiber.Ctx) error {
+	subscriptions, err := c.webhookService.ListSubscriptions(ctx.UserContext())
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+	return ctx.JSON(fiber.Map{"subscriptions": subscriptions})
+}
+
+func (c *WebhookController) GetSubscription(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+	subscription, err := c.webhookService.GetSubscription(ctx.UserContext(), uint(id))
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+	return ctx.JSON(subscription)
+}
+
+func (c *WebhookController) DeleteSubscription(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+	if err := c.webhookService.DeleteSubscription(ctx.UserContext(), uint(id)); err != nil {
+		return webhookError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// ListDeliveries возвращает журнал доставок подписки, новые первыми.
+// Параметры: limit (по умолчанию 50) и before - ID, с которого продолжить
+func (c *WebhookController) ListDeliveries(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+	before, limit, err := parseBeforePage(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	deliveries, err := c.webhookService.ListDeliveries(ctx.UserContext(), uint(id), before, limit)
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+
+	response := fiber.Map{"deliveries": deliveries}
+	if len(deliveries) == limit {
+		response["next_before"] = deliveries[len(deliveries)-1].ID
+	}
+	return ctx.JSON(response)
+}
+
+// Redeliver ставит доставку в очередь на повторную отправку
+func (c *WebhookController) Redeliver(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+	deliveryID, err := strconv.Atoi(ctx.Params("deliveryId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid delivery ID",
+		})
+	}
+
+	delivery, err := c.webhookService.Redeliver(ctx.UserContext(), uint(id), uint(deliveryID))
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusAccepted).JSON(delivery)
+}
+
+// webhookError переводит ошибки сервиса вебхуков в HTTP-ответ
+func webhookError(ctx *fiber.Ctx, err error) error {
+	var validationErr *entity.ValidationError
+	if errors.As(err, &validationErr) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"field": validationErr.Field,
+		})
+	}
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound), errors.Is(err, service.ErrDeliveryNotFound):
+		status = fiber.StatusNotFound
+	}
+	return ctx.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,131 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"multilayer/internal/controller"
+	"multilayer/internal/entity"
+	"multilayer/internal/outbox"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupWebhookApp собирает приложение с подписками на вебхуки на sqlite
+func setupWebhookApp(t *testing.T) (*fiber.App, *service.WebhookService) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.WebhookSubscription{}, &entity.WebhookDelivery{}))
+
+	webhookService := service.NewWebhookService(repository.NewWebhookRepository(db), service.WebhookConfig{})
+	webhookController := controller.NewWebhookController(webhookService)
+
+	app := fiber.New()
+	// Без источников все запросы обслуживаются в тенанте по умолчанию
+	app.Use(controller.ResolveTenant(nil, false))
+	app.Post("/webhooks", webhookController.CreateSubscription)
+	app.Get("/webhooks", webhookController.ListSubscriptions)
+	app.Get("/webhooks/:id", webhookController.GetSubscription)
+	app.Delete("/webhooks/:id", webhookController.DeleteSubscription)
+	app.Get("/webhooks/:id/deliveries", webhookController.ListDeliveries)
+	app.Post("/webhooks/:id/deliveries/:deliveryId/redeliver", webhookController.Redeliver)
+	return app, webhookService
+}
+
+func TestWebhookController_Subscriptions(t *testing.T) {
+	app, _ := setupWebhookApp(t)
+
+	resp, body := tenantRequest(t, app, "POST", "/webhooks", "", `{"url":"https://partner.example.com/hook","event_types":["user.registered"]}`)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode, body)
+	var created struct {
+		ID     uint   `json:"id"`
+		Secret string `json:"secret"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	// Секрет генерируется и возвращается только при создании
+	assert.Len(t, created.Secret, 64)
+	path := fmt.Sprintf("/webhooks/%d", created.ID)
+
+	resp, body = tenantRequest(t, app, "GET", path, "", "")
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"url":"https://partner.example.com/hook"`)
+	assert.NotContains(t, body, "secret")
+
+	resp, body = tenantRequest(t, app, "GET", "/webhooks", "", "")
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"event_types":["user.registered"]`)
+
+	resp, _ = tenantRequest(t, app, "DELETE", path, "", "")
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	resp, _ = tenantRequest(t, app, "GET", path, "", "")
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	resp, _ = tenantRequest(t, app, "GET", "/webhooks/abc", "", "")
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestWebhookController_RejectsInvalidSubscriptions(t *testing.T) {
+	app, _ := setupWebhookApp(t)
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"Metadata address", `{"url":"http://169.254.169.254/latest","event_types":["*"]}`, "url"},
+		{"Loopback", `{"url":"http://127.0.0.1:8080/hook","event_types":["*"]}`, "url"},
+		{"Localhost", `{"url":"http://localhost/hook","event_types":["*"]}`, "url"},
+		{"Unsupported scheme", `{"url":"file:///etc/passwd","event_types":["*"]}`, "url"},
+		{"Unknown event", `{"url":"https://partner.example.com/hook","event_types":["user.exploded"]}`, "event_types"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := tenantRequest(t, app, "POST", "/webhooks", "", tt.body)
+			require.Equal(t, fiber.StatusBadRequest, resp.StatusCode, body)
+			assert.Contains(t, body, `"field":"`+tt.field+`"`)
+		})
+	}
+}
+
+func TestWebhookController_Deliveries(t *testing.T) {
+	app, webhookService := setupWebhookApp(t)
+	subscription, err := webhookService.CreateSubscription(tenancy.WithTenant(context.Background(), entity.DefaultTenantID), "https://partner.example.com/hook", []string{"*"}, "")
+	require.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, webhookService.Publish(context.Background(), outbox.Envelope{
+			ID:       fmt.Sprintf("evt-%d", i),
+			Type:     string(entity.UserRegistered),
+			TenantID: entity.DefaultTenantID,
+		}))
+	}
+	path := fmt.Sprintf("/webhooks/%d/deliveries", subscription.ID)
+
+	resp, body := tenantRequest(t, app, "GET", path+"?limit=2", "", "")
+	require.Equal(t, fiber.StatusOK, resp.StatusCode, body)
+	var page struct {
+		Deliveries []entity.WebhookDelivery `json:"deliveries"`
+		NextBefore uint                     `json:"next_before"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &page))
+	require.Len(t, page.Deliveries, 2)
+	assert.Equal(t, "evt-3", page.Deliveries[0].EventID)
+	assert.Equal(t, page.Deliveries[1].ID, page.NextBefore)
+
+	resp, body = tenantRequest(t, app, "POST", fmt.Sprintf("%s/%d/redeliver", path, page.Deliveries[0].ID), "", "")
+	require.Equal(t, fiber.StatusAccepted, resp.StatusCode, body)
+	assert.Contains(t, body, `"status":"pending"`)
+
+	resp, _ = tenantRequest(t, app, "POST", path+"/999/redeliver", "", "")
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	resp, _ = tenantRequest(t, app, "GET", "/webhooks/999/deliveries", "", "")
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
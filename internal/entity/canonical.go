@@ -0,0 +1,170 @@
+package entity
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// EmailCanonicalization задает, какие адреса считаются одним и тем же ящиком.
+// Домен всегда сравнивается без учета регистра. Политика влияет на уже
+// сохраненные ключи, поэтому меняется на живой базе только вместе с пересчетом
+// users.email_key (migrations.ApplyEmailPolicy)
+type EmailCanonicalization struct {
+	CaseSensitiveLocalPart bool     // локальная часть сравнивается с учетом регистра (RFC 5321)
+	IgnorePlusTag          bool     // user+tag@example.com совпадает с user@example.com
+	IgnoreDotsDomains      []string // домены, где точки в локальной части не значимы (например, gmail.com)
+}
+
+// String описывает политику однозначно: две политики с одинаковой строкой
+// дают одинаковые ключи. По ней обнаруживается смена политики на живой базе
+func (p *EmailCanonicalization) String() string {
+	if p == nil {
+		p = &EmailCanonicalization{}
+	}
+	domains := make([]string, 0, len(p.IgnoreDotsDomains))
+	for _, domain := range p.IgnoreDotsDomains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	slices.Sort(domains)
+	return fmt.Sprintf("case_sensitive_local_part=%t;ignore_plus_tag=%t;ignore_dots_domains=%s",
+		p.CaseSensitiveLocalPart, p.IgnorePlusTag, strings.Join(slices.Compact(domains), ","))
+}
+
+var foldCase = cases.Fold()
+
+// NormalizeUsername приводит username к форме NFKC: совместимые символы
+// (полноширинные, лигатуры) заменяются обычными, регистр сохраняется
+func NormalizeUsername(username string) string {
+	return norm.NFKC.String(strings.TrimSpace(username))
+}
+
+// CanonicalUsername возвращает ключ уникальности username: NFKC, свертка
+// регистра и замена визуально неотличимых символов их латинскими прообразами,
+// так что "Alice", "ALICE" и "аlice" (с кириллической "а") дают один ключ
+func CanonicalUsername(username string) string {
+	folded := norm.NFKC.String(foldCase.String(NormalizeUsername(username)))
+	return strings.Map(func(r rune) rune {
+		if prototype, ok := confusables[r]; ok {
+			return prototype
+		}
+		return r
+	}, folded)
+}
+
+// NormalizeEmail убирает пробелы и приводит домен к нижнему регистру;
+// локальная часть сохраняется как введена
+func NormalizeEmail(email string) string {
+	email = norm.NFKC.String(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return email[:at+1] + strings.ToLower(email[at+1:])
+}
+
+// CanonicalEmail возвращает ключ уникальности email по политике policy;
+// nil - политика по умолчанию (без учета регистра, теги и точки значимы)
+func CanonicalEmail(email string, policy *EmailCanonicalization) string {
+	if policy == nil {
+		policy = &EmailCanonicalization{}
+	}
+	email = NormalizeEmail(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return strings.ToLower(email)
+	}
+	local, domain := email[:at], email[at+1:]
+	// Интернационализированный домен и его punycode-форма - один и тот же домен
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+
+	if !policy.CaseSensitiveLocalPart {
+		local = foldCase.String(local)
+	}
+	if policy.IgnorePlusTag {
+		if plus := strings.IndexByte(local, '+'); plus > 0 {
+			local = local[:plus]
+		}
+	}
+	for _, ignoreDots := range policy.IgnoreDotsDomains {
+		if strings.EqualFold(domain, strings.TrimSpace(ignoreDots)) {
+			local = strings.ReplaceAll(local, ".", "")
+			break
+		}
+	}
+	return local + "@" + domain
+}
+
+// usernameScripts - письменности, смешение которых в одном username запрещено
+var usernameScripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Armenian", unicode.Armenian},
+	{"Hebrew", unicode.Hebrew},
+	{"Arabic", unicode.Arabic},
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+}
+
+// japaneseScripts могут встречаться вместе в одном имени
+var japaneseScripts = map[string]bool{"Han": true, "Hiragana": true, "Katakana": true}
+
+// checkUsernameSpoofing отклоняет username с невидимыми символами и со
+// смешением письменностей - основной способ подделать чужое имя
+func checkUsernameSpoofing(username string) error {
+	scripts := map[string]bool{}
+	for _, r := range username {
+		if unicode.Is(unicode.Cf, r) || unicode.IsControl(r) || (unicode.IsSpace(r) && r != ' ') {
+			return &ValidationError{Field: "username", Message: "username cannot contain invisible or control characters"}
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		script := "Other"
+		for _, candidate := range usernameScripts {
+			if unicode.Is(candidate.table, r) {
+				script = candidate.name
+				break
+			}
+		}
+		scripts[script] = true
+	}
+
+	if len(scripts) <= 1 {
+		return nil
+	}
+	for script := range scripts {
+		if !japaneseScripts[script] {
+			return &ValidationError{Field: "username", Message: "username cannot mix letters from different scripts"}
+		}
+	}
+	return nil
+}
+
+// confusables сопоставляет символы, неотличимые от латинских букв, с их
+// прообразами (подмножество Unicode confusables.txt для строчных букв после
+// свертки регистра)
+var confusables = map[rune]rune{
+	// Кириллица
+	'а': 'a', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'ӏ': 'l', 'о': 'o',
+	'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'ԝ': 'w', 'х': 'x', 'у': 'y', 'ү': 'y',
+	// Греческий
+	'α': 'a', 'ϲ': 'c', 'ι': 'i', 'ϳ': 'j', 'ο': 'o', 'ρ': 'p', 'ν': 'v', 'χ': 'x', 'γ': 'y',
+	// Латиница
+	'ɑ': 'a', 'ɡ': 'g', 'ı': 'i', 'ɩ': 'i', 'ȷ': 'j',
+}
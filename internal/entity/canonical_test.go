@@ -0,0 +1,101 @@
+package entity
+
+import "testing"
+
+func TestCanonicalUsername(t *testing.T) {
+	tests := []struct {
+		name  string
+		left  string
+		right string
+	}{
+		{"Case", "Alice", "alice"},
+		{"Full-width", "ａｌｉｃｅ", "alice"},
+		{"German sharp s", "STRASSE", "straße"},
+		{"Cyrillic homoglyphs", "асе", "ace"},
+		{"Greek homoglyphs", "ορα", "opa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if CanonicalUsername(tt.left) != CanonicalUsername(tt.right) {
+				t.Errorf("CanonicalUsername(%q) = %q, CanonicalUsername(%q) = %q", tt.left, CanonicalUsername(tt.left), tt.right, CanonicalUsername(tt.right))
+			}
+		})
+	}
+
+	if CanonicalUsername("alice") == CanonicalUsername("alicia") {
+		t.Error("different usernames share a canonical key")
+	}
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EmailCanonicalization
+		email  string
+		want   string
+	}{
+		{"Default lowercases", EmailCanonicalization{}, "Bob@Example.COM", "bob@example.com"},
+		{"Case-sensitive local part", EmailCanonicalization{CaseSensitiveLocalPart: true}, "Bob@Example.COM", "Bob@example.com"},
+		{"Plus tag kept by default", EmailCanonicalization{}, "bob+news@example.com", "bob+news@example.com"},
+		{"Plus tag ignored", EmailCanonicalization{IgnorePlusTag: true}, "bob+news@example.com", "bob@example.com"},
+		{"Dots ignored for domain", EmailCanonicalization{IgnoreDotsDomains: []string{"gmail.com"}}, "b.o.b@Gmail.com", "bob@gmail.com"},
+		{"Dots kept for other domains", EmailCanonicalization{IgnoreDotsDomains: []string{"gmail.com"}}, "b.o.b@example.com", "b.o.b@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalEmail(tt.email, &tt.policy); got != tt.want {
+				t.Errorf("CanonicalEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailCanonicalization_String(t *testing.T) {
+	a := &EmailCanonicalization{IgnoreDotsDomains: []string{"gmail.com", " GoogleMail.com"}}
+	b := &EmailCanonicalization{IgnoreDotsDomains: []string{"googlemail.com", "gmail.com", "gmail.com"}}
+	if a.String() != b.String() {
+		t.Errorf("equivalent policies differ: %q and %q", a, b)
+	}
+	if (*EmailCanonicalization)(nil).String() != (&EmailCanonicalization{}).String() {
+		t.Error("nil policy differs from the default one")
+	}
+	if a.String() == (&EmailCanonicalization{IgnorePlusTag: true, IgnoreDotsDomains: a.IgnoreDotsDomains}).String() {
+		t.Error("different policies share a description")
+	}
+}
+
+func TestNewUser_Spoofing(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantErr  bool
+	}{
+		{"Latin", "alice", false},
+		{"Cyrillic", "алиса", false},
+		{"Japanese scripts", "山田たろう", false},
+		{"Mixed Latin and Cyrillic", "pаypal", true},
+		{"Zero-width space", "ali\u200bce", true},
+		{"Right-to-left override", "ali\u202ece", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewUser(tt.username, "user@example.com", DefaultUsernamePolicy())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewUser(%q) error = %v, wantErr %v", tt.username, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewUser_Normalizes(t *testing.T) {
+	user, err := NewUser("ｊｏｈｎ", "John@Example.COM", DefaultUsernamePolicy())
+	if err != nil {
+		t.Fatalf("NewUser() error = %v", err)
+	}
+	if user.Username != "john" || user.Email != "John@example.com" {
+		t.Errorf("NewUser() = %q, %q", user.Username, user.Email)
+	}
+}
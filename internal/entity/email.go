@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Ограничения длины из RFC 5321
+const (
+	maxEmailLength     = 254
+	maxLocalPartLength = 64
+)
+
+// ParseEmail разбирает addr-spec по RFC 5322 с UTF-8 по RFC 6532: локальная
+// часть - dot-atom или строка в кавычках, домен - имя хоста, в том числе
+// интернационализированное. Возвращает домен в ASCII-форме (punycode).
+// Литералы адресов вида user@[192.0.2.1] не принимаются
+func ParseEmail(email string) (local, asciiDomain string, err error) {
+	invalid := &ValidationError{Field: "email", Message: "invalid email format"}
+	if len(email) > maxEmailLength {
+		return "", "", &ValidationError{Field: "email", Message: "email cannot exceed 254 characters"}
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", "", invalid
+	}
+	local, domain := email[:at], email[at+1:]
+
+	if len(local) > maxLocalPartLength || !utf8.ValidString(local) {
+		return "", "", invalid
+	}
+	if strings.HasPrefix(local, `"`) {
+		if !isQuotedString(local) {
+			return "", "", invalid
+		}
+	} else if !isDotAtom(local) {
+		return "", "", invalid
+	}
+
+	asciiDomain, ok := asciiHostname(domain)
+	if !ok {
+		return "", "", invalid
+	}
+	return local, asciiDomain, nil
+}
+
+// asciiHostname переводит домен в punycode и проверяет, что это полное имя
+// хоста: не меньше двух меток, метки из букв, цифр и дефисов, TLD не числовой
+func asciiHostname(domain string) (string, bool) {
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil || len(ascii) > 253 {
+		return "", false
+	}
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", false
+		}
+		for i := 0; i < len(label); i++ {
+			if c := label[i]; !isASCIIAlnum(c) && c != '-' {
+				return "", false
+			}
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", false
+	}
+	return ascii, true
+}
+
+// isDotAtom - atext (включая символы не из ASCII) через одиночные точки
+func isDotAtom(s string) bool {
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			if r < utf8.RuneSelf && !isASCIIAlnum(byte(r)) && !strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isQuotedString - строка в кавычках из печатных символов и пробелов с
+// экранированием через обратную косую черту
+func isQuotedString(s string) bool {
+	if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+		return false
+	}
+	escaped := false
+	for _, r := range s[1 : len(s)-1] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return false
+		case r < ' ' || r == 0x7f:
+			return false
+		}
+	}
+	return !escaped
+}
+
+func isASCIIAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEmail(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		wantDomain string
+		wantErr    bool
+	}{
+		{"Simple", "john@example.com", "example.com", false},
+		{"Plus tag and subdomain", "john+news@mail.example.co.uk", "mail.example.co.uk", false},
+		{"Special atext", "o'brien!#$%&*=?^_`{|}~@example.com", "example.com", false},
+		{"Quoted local part", `"john doe"@example.com`, "example.com", false},
+		{"Quoted with escape", `"john\"doe"@example.com`, "example.com", false},
+		{"IDN domain", "user@пример.рф", "xn--e1afmkfd.xn--p1ai", false},
+		{"UTF-8 local part", "иван@example.com", "example.com", false},
+		{"Uppercase domain", "john@EXAMPLE.com", "example.com", false},
+		{"No at", "invalid-email", "", true},
+		{"No domain", "john@", "", true},
+		{"No local part", "@example.com", "", true},
+		{"Single label domain", "john@localhost", "", true},
+		{"Numeric TLD", "john@example.123", "", true},
+		{"Leading dot", ".john@example.com", "", true},
+		{"Double dot", "john..doe@example.com", "", true},
+		{"Unquoted space", "john doe@example.com", "", true},
+		{"Unterminated quote", `"john@example.com`, "", true},
+		{"Hyphen label edge", "john@-example.com", "", true},
+		{"Address literal", "john@[192.0.2.1]", "", true},
+		{"Local part too long", strings.Repeat("a", 65) + "@example.com", "", true},
+		{"Too long", "john@" + strings.Repeat("a", 250) + ".com", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, domain, err := ParseEmail(tt.email)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEmail(%q) error = %v, wantErr %v", tt.email, err, tt.wantErr)
+			}
+			if domain != tt.wantDomain {
+				t.Errorf("ParseEmail(%q) domain = %q, want %q", tt.email, domain, tt.wantDomain)
+			}
+		})
+	}
+}
+
+func TestCanonicalEmail_IDN(t *testing.T) {
+	if CanonicalEmail("user@пример.рф", nil) != CanonicalEmail("user@xn--e1afmkfd.xn--p1ai", nil) {
+		t.Error("IDN and punycode domains have different canonical keys")
+	}
+}
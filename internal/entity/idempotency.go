@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// IdempotencyRecord - первый ответ на запрос с заголовком Idempotency-Key.
+// Ключ уникален для клиента (Caller) в тенанте; пока запрос выполняется,
+// Completed = false и повтор с тем же ключом получает отказ, а не второй вызов
+type IdempotencyRecord struct {
+	ID             uint   `gorm:"primaryKey"`
+	TenantID       uint   `gorm:"uniqueIndex:idx_idempotency_records_key"`
+	Caller         string `gorm:"uniqueIndex:idx_idempotency_records_key"` // хэш идентификатора клиента
+	IdempotencyKey string `gorm:"uniqueIndex:idx_idempotency_records_key"`
+	RequestHash    string // хэш метода, пути и тела: повтор с другим запросом отклоняется
+	Completed      bool
+
+	ResponseStatus      int
+	ResponseContentType string
+	ResponseBody        []byte
+
+	CreatedAt time.Time
+	// LockedUntil - до какого момента незавершенный запрос считается
+	// выполняющимся; пока он идет, срок продлевается
+	LockedUntil time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+}
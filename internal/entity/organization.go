@@ -0,0 +1,113 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// MembershipRole - роль участника организации или команды
+type MembershipRole string
+
+const (
+	RoleOwner  MembershipRole = "owner"  // управляет составом и может удалить организацию
+	RoleAdmin  MembershipRole = "admin"  // управляет составом
+	RoleMember MembershipRole = "member" // рядовой участник
+)
+
+// ParseMembershipRole проверяет роль; пустая строка означает RoleMember
+func ParseMembershipRole(role string) (MembershipRole, error) {
+	switch parsed := MembershipRole(strings.ToLower(strings.TrimSpace(role))); parsed {
+	case "":
+		return RoleMember, nil
+	case RoleOwner, RoleAdmin, RoleMember:
+		return parsed, nil
+	default:
+		return "", &ValidationError{Field: "role", Message: "role must be one of owner, admin, member"}
+	}
+}
+
+// Organization объединяет пользователей тенанта; slug уникален в пределах тенанта
+type Organization struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TenantID  uint      `gorm:"uniqueIndex:idx_organizations_tenant_slug" json:"-"`
+	Slug      string    `gorm:"uniqueIndex:idx_organizations_tenant_slug;size:63" json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewOrganization создает организацию с валидацией; slug приводится к нижнему регистру
+func NewOrganization(slug, name string) (*Organization, error) {
+	slug, name, err := normalizeSlugAndName(slug, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Organization{Slug: slug, Name: name}, nil
+}
+
+// Team - команда внутри организации; slug уникален в пределах организации.
+// Организацию с командами удалить нельзя (внешний ключ RESTRICT)
+type Team struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	TenantID       uint          `gorm:"index" json:"-"`
+	OrganizationID uint          `gorm:"uniqueIndex:idx_teams_organization_slug" json:"organization_id"`
+	Organization   *Organization `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
+	Slug           string        `gorm:"uniqueIndex:idx_teams_organization_slug;size:63" json:"slug"`
+	Name           string        `json:"name"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// NewTeam создает команду организации с валидацией
+func NewTeam(organizationID uint, slug, name string) (*Team, error) {
+	slug, name, err := normalizeSlugAndName(slug, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Team{OrganizationID: organizationID, Slug: slug, Name: name}, nil
+}
+
+// OrganizationMember - членство пользователя в организации; удаляется вместе
+// с организацией и пользователем (внешние ключи CASCADE)
+type OrganizationMember struct {
+	OrganizationID uint           `gorm:"primaryKey" json:"organization_id"`
+	Organization   *Organization  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID         uint           `gorm:"primaryKey;index" json:"user_id"`
+	User           *User          `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	TenantID       uint           `gorm:"index" json:"-"`
+	Role           MembershipRole `gorm:"size:16" json:"role"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// TeamMember - членство пользователя в команде; пользователь должен состоять
+// в организации команды. Удаляется вместе с командой и пользователем
+type TeamMember struct {
+	TeamID    uint           `gorm:"primaryKey" json:"team_id"`
+	Team      *Team          `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID    uint           `gorm:"primaryKey;index" json:"user_id"`
+	User      *User          `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	TenantID  uint           `gorm:"index" json:"-"`
+	Role      MembershipRole `gorm:"size:16" json:"role"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// UserMembership - членство пользователя в организации (Team == nil) или в
+// команде вместе с описанием организации и команды
+type UserMembership struct {
+	Organization Organization   `json:"organization"`
+	Team         *Team          `json:"team,omitempty"`
+	Role         MembershipRole `json:"role"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+func normalizeSlugAndName(slug, name string) (string, string, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	name = strings.TrimSpace(name)
+	if !slugRegex.MatchString(slug) {
+		return "", "", &ValidationError{Field: "slug", Message: "slug must be a DNS label of lowercase letters, digits and hyphens"}
+	}
+	if name == "" {
+		name = slug
+	}
+	return slug, name, nil
+}
@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMembershipRole(t *testing.T) {
+	tests := []struct {
+		role    string
+		want    MembershipRole
+		wantErr bool
+	}{
+		{"", RoleMember, false},
+		{"owner", RoleOwner, false},
+		{" Admin ", RoleAdmin, false},
+		{"member", RoleMember, false},
+		{"superuser", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			role, err := ParseMembershipRole(tt.role)
+			if tt.wantErr {
+				var validationErr *ValidationError
+				assert.ErrorAs(t, err, &validationErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, role)
+		})
+	}
+}
+
+func TestNewTeam(t *testing.T) {
+	team, err := NewTeam(7, " Platform ", "")
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), team.OrganizationID)
+	assert.Equal(t, "platform", team.Slug)
+	assert.Equal(t, "platform", team.Name)
+
+	_, err = NewOrganization("no spaces", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Тенант, к которому относятся данные, созданные до появления тенантов, и
+// запросы однотенантных развертываний
+const (
+	DefaultTenantID   uint = 1
+	DefaultTenantSlug      = "default"
+)
+
+// slugRegex - метка DNS: slug тенанта используется как поддомен, тот же формат
+// принят для организаций и команд
+var slugRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Tenant - организация-клиент; пользователи и подписки изолированы по тенанту
+type Tenant struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Slug string `gorm:"uniqueIndex;size:63" json:"slug"`
+	Name string `json:"name"`
+	// Домены email пользователей тенанта (например, корпоративный домен клиента):
+	// если AllowedEmailDomains не пуст, принимаются только они; правило действует и на поддомены
+	AllowedEmailDomains []string  `gorm:"serializer:json" json:"allowed_email_domains"`
+	DeniedEmailDomains  []string  `gorm:"serializer:json" json:"denied_email_domains"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// NewTenant создает тенанта с валидацией; slug приводится к нижнему регистру
+func NewTenant(slug, name string) (*Tenant, error) {
+	slug, name, err := normalizeSlugAndName(slug, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Tenant{Slug: slug, Name: name}, nil
+}
+
+// SetEmailDomains задает списки доменов email тенанта; домены приводятся к
+// punycode и нижнему регистру, пустые строки пропускаются
+func (t *Tenant) SetEmailDomains(allowed, denied []string) error {
+	var err error
+	if t.AllowedEmailDomains, err = normalizeEmailDomains("allowed_email_domains", allowed); err != nil {
+		return err
+	}
+	t.DeniedEmailDomains, err = normalizeEmailDomains("denied_email_domains", denied)
+	return err
+}
+
+func normalizeEmailDomains(field string, domains []string) ([]string, error) {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if domain = strings.TrimSpace(domain); domain == "" {
+			continue
+		}
+		_, ascii, err := ParseEmail("x@" + domain)
+		if err != nil {
+			return nil, &ValidationError{Field: field, Message: "invalid domain: " + domain}
+		}
+		normalized = append(normalized, ascii)
+	}
+	return normalized, nil
+}
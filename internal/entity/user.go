@@ -2,67 +2,196 @@
 package entity
 
 import (
-	"errors"
-	"regexp"
+	"net/url"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// Значения профиля по умолчанию для новых пользователей
+const (
+	DefaultLocale   = "en"
+	DefaultTimezone = "UTC"
 )
 
 type User struct {
-	ID       uint   `gorm:"primaryKey" json:"id"`
-	Username string `gorm:"unique" json:"username"`
-	Email    string `gorm:"unique" json:"email"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TenantID    uint      `gorm:"uniqueIndex:idx_users_tenant_username_key;uniqueIndex:idx_users_tenant_email_key" json:"tenant_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	UsernameKey string    `gorm:"uniqueIndex:idx_users_tenant_username_key" json:"-"` // CanonicalUsername(Username), уникален в пределах тенанта
+	EmailKey    string    `gorm:"uniqueIndex:idx_users_tenant_email_key" json:"-"`    // CanonicalEmail(Email), уникален в пределах тенанта
+	DisplayName string    `json:"display_name"`
+	Locale      string    `json:"locale"`
+	Timezone    string    `json:"timezone"`
+	AvatarURL   string    `json:"avatar_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Статус меняется только через TransitionTo
+	Status          UserStatus `gorm:"size:20;not null;default:active;index" json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+}
+
+// UserProfile - необязательные поля профиля; пустое значение означает "не задано"
+type UserProfile struct {
+	DisplayName string
+	Locale      string // тег BCP 47, например "en-US"
+	Timezone    string // имя зоны IANA, например "Europe/Moscow"
+	AvatarURL   string
+}
+
+// ValidationError описывает нарушение правил валидации пользователя
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
 }
 
-// NewUser создает нового пользователя с валидацией
-func NewUser(username, email string) (*User, error) {
+// NewUser создает нового пользователя с валидацией; username проверяется по policy
+func NewUser(username, email string, policy *UsernamePolicy) (*User, error) {
 	user := &User{
-		Username: strings.TrimSpace(username),
-		Email:    strings.TrimSpace(email),
+		Username: NormalizeUsername(username),
+		Email:    NormalizeEmail(email),
+		Locale:   DefaultLocale,
+		Timezone: DefaultTimezone,
+		Status:   UserActive,
 	}
 
-	if err := user.Validate(); err != nil {
+	if err := user.Validate(policy); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
-// Validate проверяет корректность данных пользователя
-func (u *User) Validate() error {
-	if u.Username == "" {
-		return errors.New("username cannot be empty")
+// Validate проверяет корректность данных пользователя. policy == nil пропускает
+// правила выбора username: так проверяются записи, где имя не меняется
+func (u *User) Validate(policy *UsernamePolicy) error {
+	if err := ValidateUsername(u.Username, policy); err != nil {
+		return err
 	}
 
-	if len(u.Username) < 3 {
-		return errors.New("username must be at least 3 characters long")
+	if err := ValidateEmail(u.Email); err != nil {
+		return err
 	}
 
-	if len(u.Username) > 50 {
-		return errors.New("username cannot exceed 50 characters")
+	return u.validateProfile()
+}
+
+// ValidateUsername проверяет нормализованный username по тем же правилам, что и Validate
+func ValidateUsername(username string, policy *UsernamePolicy) error {
+	if username == "" {
+		return &ValidationError{Field: "username", Message: "username cannot be empty"}
 	}
 
-	if u.Email == "" {
-		return errors.New("email cannot be empty")
+	if policy != nil {
+		if err := policy.Check(username); err != nil {
+			return err
+		}
 	}
 
-	// Простая валидация email
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(u.Email) {
-		return errors.New("invalid email format")
+	return checkUsernameSpoofing(username)
+}
+
+// ValidateEmail проверяет синтаксис нормализованного email так же, как Validate
+func ValidateEmail(email string) error {
+	if email == "" {
+		return &ValidationError{Field: "email", Message: "email cannot be empty"}
 	}
 
+	_, _, err := ParseEmail(email)
+	return err
+}
+
+// BeforeSave вычисляет ключи уникальности перед каждой записью, так что
+// уникальные индексы по ним действуют для любого пути сохранения. EmailKey
+// зависит от политики развертывания и заполняется репозиторием; здесь он
+// вычисляется по политике по умолчанию, только если не задан
+func (u *User) BeforeSave(*gorm.DB) error {
+	u.UsernameKey = CanonicalUsername(u.Username)
+	if u.EmailKey == "" {
+		u.EmailKey = CanonicalEmail(u.Email, nil)
+	}
+	u.Status = u.EffectiveStatus()
 	return nil
 }
 
-// Update обновляет данные пользователя
-func (u *User) Update(username, email string) error {
+// validateProfile проверяет поля профиля; пустые поля допустимы
+func (u *User) validateProfile() error {
+	if utf8.RuneCountInString(u.DisplayName) > 100 {
+		return &ValidationError{Field: "display_name", Message: "display name cannot exceed 100 characters"}
+	}
+	if strings.IndexFunc(u.DisplayName, unicode.IsControl) >= 0 {
+		return &ValidationError{Field: "display_name", Message: "display name cannot contain control characters"}
+	}
+
+	if u.Locale != "" {
+		if _, err := language.Parse(u.Locale); err != nil {
+			return &ValidationError{Field: "locale", Message: "locale must be a valid BCP 47 language tag"}
+		}
+	}
+
+	if u.Timezone != "" {
+		if _, err := time.LoadLocation(u.Timezone); err != nil || u.Timezone == "Local" {
+			return &ValidationError{Field: "timezone", Message: "timezone must be a valid IANA time zone name"}
+		}
+	}
+
+	if u.AvatarURL != "" {
+		parsed, err := url.Parse(u.AvatarURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return &ValidationError{Field: "avatar_url", Message: "avatar url must be an absolute http or https URL"}
+		}
+		if len(u.AvatarURL) > 2048 {
+			return &ValidationError{Field: "avatar_url", Message: "avatar url cannot exceed 2048 characters"}
+		}
+	}
+
+	return nil
+}
+
+// UpdateProfile заменяет поля профиля с валидацией; локаль приводится к каноническому виду
+func (u *User) UpdateProfile(profile UserProfile) error {
+	previous := *u
+
+	u.DisplayName = strings.TrimSpace(profile.DisplayName)
+	u.Locale = strings.TrimSpace(profile.Locale)
+	u.Timezone = strings.TrimSpace(profile.Timezone)
+	u.AvatarURL = strings.TrimSpace(profile.AvatarURL)
+
+	if err := u.Validate(nil); err != nil {
+		// Откатываем изменения при ошибке валидации
+		*u = previous
+		return err
+	}
+
+	if u.Locale != "" {
+		u.Locale = language.Make(u.Locale).String()
+	}
+	return nil
+}
+
+// Update обновляет данные пользователя. Policy применяется только при смене
+// username, чтобы имена, созданные по прежним правилам, не мешали менять email
+func (u *User) Update(username, email string, policy *UsernamePolicy) error {
 	oldUsername := u.Username
 	oldEmail := u.Email
 
-	u.Username = strings.TrimSpace(username)
-	u.Email = strings.TrimSpace(email)
+	u.Username = NormalizeUsername(username)
+	u.Email = NormalizeEmail(email)
 
-	if err := u.Validate(); err != nil {
+	if CanonicalUsername(u.Username) == CanonicalUsername(oldUsername) {
+		policy = nil
+	}
+	if err := u.Validate(policy); err != nil {
 		// Откатываем изменения при ошибке валидации
 		u.Username = oldUsername
 		u.Email = oldEmail
@@ -74,12 +203,15 @@ func (u *User) Update(username, email string) error {
 
 // IsValidEmail проверяет корректность email
 func (u *User) IsValidEmail() bool {
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	return emailRegex.MatchString(u.Email)
+	_, _, err := ParseEmail(u.Email)
+	return err == nil
 }
 
 // GetDisplayName возвращает отображаемое имя пользователя
 func (u *User) GetDisplayName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
 	if u.Username != "" {
 		return u.Username
 	}
@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// UserAuditEntry - неизменяемая запись журнала изменений пользователя
+type UserAuditEntry struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	TenantID  uint          `gorm:"index" json:"-"`
+	UserID    uint          `gorm:"index" json:"user_id"`
+	Action    UserEventType `json:"action"`
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id,omitempty"`
+	Changes   []FieldChange `gorm:"serializer:json" json:"changes"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func (UserAuditEntry) TableName() string {
+	return "user_audit_log"
+}
+
+// FieldChange - значение поля до и после изменения; nil означает пустое значение
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// auditIgnoredFields не попадают в diff: они не отражают действий пользователя
+var auditIgnoredFields = map[string]bool{"id": true, "tenant_id": true, "created_at": true, "updated_at": true}
+
+// DiffUsers возвращает изменившиеся поля в порядке имен. before == nil
+// соответствует созданию записи, after == nil - удалению
+func DiffUsers(before, after *User) []FieldChange {
+	beforeFields, afterFields := userFields(before), userFields(after)
+
+	names := make([]string, 0, len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if auditIgnoredFields[name] || reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Before: beforeFields[name], After: afterFields[name]})
+	}
+	return changes
+}
+
+// userFields раскладывает пользователя по JSON-именам полей; пустые значения
+// опускаются, чтобы незаданные поля профиля не попадали в diff создания и удаления
+func userFields(user *User) map[string]any {
+	fields := map[string]any{}
+	if user == nil {
+		return fields
+	}
+	data, _ := json.Marshal(user)
+	json.Unmarshal(data, &fields)
+	for name, value := range fields {
+		if value == nil || value == "" {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffUsers(t *testing.T) {
+	before := &User{ID: 1, Username: "john_doe", Email: "john@example.com"}
+	after := &User{ID: 1, Username: "john_doe", Email: "johnny@example.com"}
+
+	tests := []struct {
+		name   string
+		before *User
+		after  *User
+		want   []FieldChange
+	}{
+		{
+			name:   "Update",
+			before: before,
+			after:  after,
+			want:   []FieldChange{{Field: "email", Before: "john@example.com", After: "johnny@example.com"}},
+		},
+		{
+			name:   "Create",
+			before: nil,
+			after:  before,
+			want: []FieldChange{
+				{Field: "email", Before: nil, After: "john@example.com"},
+				{Field: "username", Before: nil, After: "john_doe"},
+			},
+		},
+		{
+			name:   "No changes",
+			before: before,
+			after:  before,
+			want:   []FieldChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffUsers(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffUsers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
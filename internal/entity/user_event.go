@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// UserEventType - тип доменного события жизненного цикла пользователя
+type UserEventType string
+
+const (
+	UserRegistered UserEventType = "user.registered"
+	UserUpdated    UserEventType = "user.updated"
+	UserDeleted    UserEventType = "user.deleted"
+)
+
+// UserEvent описывает изменение пользователя; User - снимок записи после
+// изменения (для UserDeleted - последнее состояние перед удалением),
+// Previous - состояние до изменения (только для UserUpdated)
+type UserEvent struct {
+	Type       UserEventType `json:"type"`
+	User       User          `json:"user"`
+	Previous   *User         `json:"-"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// NewUserEvent создает событие со снимком пользователя на текущий момент
+func NewUserEvent(eventType UserEventType, user *User) UserEvent {
+	return UserEvent{
+		Type:       eventType,
+		User:       *user,
+		OccurredAt: time.Now().UTC(),
+	}
+}
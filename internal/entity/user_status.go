@@ -0,0 +1,114 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// UserStatus - состояние учетной записи в ее жизненном цикле
+type UserStatus string
+
+const (
+	UserActive      UserStatus = "active"      // обычное состояние
+	UserSuspended   UserStatus = "suspended"   // заблокирована администратором, например за нарушения
+	UserLocked      UserStatus = "locked"      // временно закрыт вход, например после подбора пароля
+	UserDeactivated UserStatus = "deactivated" // закрыта владельцем или администратором
+)
+
+// MaxStatusReasonLength - наибольшая длина причины смены статуса в символах
+const MaxStatusReasonLength = 500
+
+// ErrInvalidStatusTransition - переход между статусами не разрешен машиной состояний
+var ErrInvalidStatusTransition = errors.New("status transition is not allowed")
+
+// userStatusTransitions - разрешенные переходы: из статуса-ключа в любой из значений
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	UserActive:      {UserSuspended, UserLocked, UserDeactivated},
+	UserSuspended:   {UserActive, UserDeactivated},
+	UserLocked:      {UserActive, UserSuspended, UserDeactivated},
+	UserDeactivated: {UserActive},
+}
+
+// ParseUserStatus проверяет название статуса
+func ParseUserStatus(value string) (UserStatus, error) {
+	status := UserStatus(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := userStatusTransitions[status]; !ok {
+		return "", &ValidationError{Field: "status", Message: fmt.Sprintf("unknown status %q", value)}
+	}
+	return status, nil
+}
+
+// CanTransitionTo сообщает, разрешен ли переход из s в next
+func (s UserStatus) CanTransitionTo(next UserStatus) bool {
+	for _, allowed := range userStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresReason сообщает, нужно ли объяснять переход в статус s
+func (s UserStatus) RequiresReason() bool {
+	return s == UserSuspended || s == UserLocked
+}
+
+// CanAuthenticate сообщает, может ли пользователь в статусе s входить в систему
+func (s UserStatus) CanAuthenticate() bool {
+	return s == UserActive
+}
+
+// IsListed сообщает, показывается ли пользователь в статусе s в списках по умолчанию
+func (s UserStatus) IsListed() bool {
+	return s != UserSuspended && s != UserDeactivated
+}
+
+// UnlistedUserStatuses возвращает статусы, скрытые из списков по умолчанию
+func UnlistedUserStatuses() []UserStatus {
+	var statuses []UserStatus
+	for _, status := range []UserStatus{UserActive, UserSuspended, UserLocked, UserDeactivated} {
+		if !status.IsListed() {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// EffectiveStatus возвращает статус пользователя; записи без статуса считаются активными
+func (u *User) EffectiveStatus() UserStatus {
+	if u.Status == "" {
+		return UserActive
+	}
+	return u.Status
+}
+
+// CanAuthenticate сообщает, может ли пользователь входить в систему
+func (u *User) CanAuthenticate() bool {
+	return u.EffectiveStatus().CanAuthenticate()
+}
+
+// TransitionTo переводит пользователя в статус next с причиной reason и
+// запоминает момент перехода. Причина обязательна для suspended и locked;
+// при возврате в active прежняя причина стирается
+func (u *User) TransitionTo(next UserStatus, reason string, now time.Time) error {
+	reason = strings.TrimSpace(reason)
+	if next.RequiresReason() && reason == "" {
+		return &ValidationError{Field: "reason", Message: fmt.Sprintf("reason is required for status %s", next)}
+	}
+	if utf8.RuneCountInString(reason) > MaxStatusReasonLength {
+		return &ValidationError{Field: "reason", Message: fmt.Sprintf("reason cannot exceed %d characters", MaxStatusReasonLength)}
+	}
+	current := u.EffectiveStatus()
+	if !current.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, current, next)
+	}
+
+	changedAt := now.UTC()
+	u.Status = next
+	u.StatusReason = reason
+	u.StatusChangedAt = &changedAt
+	return nil
+}
@@ -0,0 +1,96 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUser_TransitionTo(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		from      UserStatus
+		to        UserStatus
+		reason    string
+		wantErr   error
+		wantField string
+	}{
+		{name: "Suspend active", from: UserActive, to: UserSuspended, reason: "spam"},
+		{name: "Lock active", from: UserActive, to: UserLocked, reason: "too many failed logins"},
+		{name: "Suspend locked", from: UserLocked, to: UserSuspended, reason: "fraud"},
+		{name: "Reactivate deactivated", from: UserDeactivated, to: UserActive},
+		{name: "Legacy user without status", from: "", to: UserDeactivated},
+		{name: "Suspend without reason", from: UserActive, to: UserSuspended, reason: "  ", wantField: "reason"},
+		{name: "Reason too long", from: UserActive, to: UserDeactivated, reason: strings.Repeat("x", MaxStatusReasonLength+1), wantField: "reason"},
+		{name: "Lock deactivated", from: UserDeactivated, to: UserLocked, reason: "x", wantErr: ErrInvalidStatusTransition},
+		{name: "Same status", from: UserActive, to: UserActive, wantErr: ErrInvalidStatusTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Status: tt.from, StatusReason: "previous"}
+			err := user.TransitionTo(tt.to, tt.reason, now)
+
+			if tt.wantField != "" {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+					t.Fatalf("TransitionTo() error = %v, want validation error on %s", err, tt.wantField)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransitionTo() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if user.Status != tt.from || user.StatusReason != "previous" || user.StatusChangedAt != nil {
+					t.Errorf("TransitionTo() changed user on error: %+v", user)
+				}
+				return
+			}
+
+			if user.Status != tt.to {
+				t.Errorf("Status = %v, want %v", user.Status, tt.to)
+			}
+			if user.StatusReason != strings.TrimSpace(tt.reason) {
+				t.Errorf("StatusReason = %q, want %q", user.StatusReason, tt.reason)
+			}
+			if user.StatusChangedAt == nil || !user.StatusChangedAt.Equal(now) {
+				t.Errorf("StatusChangedAt = %v, want %v", user.StatusChangedAt, now)
+			}
+		})
+	}
+}
+
+func TestUserStatus_Rules(t *testing.T) {
+	tests := []struct {
+		status          UserStatus
+		canAuthenticate bool
+		listed          bool
+	}{
+		{UserActive, true, true},
+		{UserLocked, false, true},
+		{UserSuspended, false, false},
+		{UserDeactivated, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := tt.status.CanAuthenticate(); got != tt.canAuthenticate {
+				t.Errorf("CanAuthenticate() = %v, want %v", got, tt.canAuthenticate)
+			}
+			if got := tt.status.IsListed(); got != tt.listed {
+				t.Errorf("IsListed() = %v, want %v", got, tt.listed)
+			}
+		})
+	}
+
+	if _, err := ParseUserStatus(" Suspended "); err != nil {
+		t.Errorf("ParseUserStatus() error = %v", err)
+	}
+	for _, value := range []string{"banned", "pending"} {
+		if _, err := ParseUserStatus(value); err == nil {
+			t.Errorf("ParseUserStatus(%q) accepted unknown status", value)
+		}
+	}
+}
@@ -52,7 +52,7 @@ func TestNewUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := NewUser(tt.username, tt.email)
+			user, err := NewUser(tt.username, tt.email, DefaultUsernamePolicy())
 
 			if tt.wantErr {
 				if err == nil {
@@ -143,7 +143,7 @@ func TestUser_Validate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.user.Validate()
+			err := tt.user.Validate(DefaultUsernamePolicy())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("User.Validate() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -151,6 +151,36 @@ func TestUser_Validate(t *testing.T) {
 	}
 }
 
+func TestValidateUsernameAndEmail(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantField string
+	}{
+		{"Valid username", ValidateUsername("john_doe", DefaultUsernamePolicy()), ""},
+		{"Reserved username", ValidateUsername("admin", DefaultUsernamePolicy()), "username"},
+		{"Policy is optional", ValidateUsername("jo", nil), ""},
+		{"Valid email", ValidateEmail("john@example.com"), ""},
+		{"Empty email", ValidateEmail(""), "email"},
+		{"Invalid email", ValidateEmail("invalid-email"), "email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantField == "" {
+				if tt.err != nil {
+					t.Errorf("unexpected error: %v", tt.err)
+				}
+				return
+			}
+			validationErr, ok := tt.err.(*ValidationError)
+			if !ok || validationErr.Field != tt.wantField {
+				t.Errorf("error = %v, want ValidationError for %s", tt.err, tt.wantField)
+			}
+		})
+	}
+}
+
 func TestUser_Update(t *testing.T) {
 	user := &User{
 		ID:       1,
@@ -189,7 +219,7 @@ func TestUser_Update(t *testing.T) {
 			originalUsername := user.Username
 			originalEmail := user.Email
 
-			err := user.Update(tt.username, tt.email)
+			err := user.Update(tt.username, tt.email, DefaultUsernamePolicy())
 
 			if tt.wantErr {
 				if err == nil {
@@ -311,3 +341,50 @@ func TestUser_GetDisplayName(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_UpdateProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   UserProfile
+		wantField string
+	}{
+		{"Valid profile", UserProfile{DisplayName: "John Doe", Locale: "en-US", Timezone: "Europe/Moscow", AvatarURL: "https://cdn.example.com/a.png"}, ""},
+		{"Empty profile", UserProfile{}, ""},
+		{"Long display name", UserProfile{DisplayName: strings.Repeat("я", 101)}, "display_name"},
+		{"Control characters", UserProfile{DisplayName: "John\nDoe"}, "display_name"},
+		{"Invalid locale", UserProfile{Locale: "not a locale"}, "locale"},
+		{"Invalid timezone", UserProfile{Timezone: "Mars/Olympus"}, "timezone"},
+		{"Relative avatar", UserProfile{AvatarURL: "/a.png"}, "avatar_url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, _ := NewUser("john_doe", "john@example.com", DefaultUsernamePolicy())
+			err := user.UpdateProfile(tt.profile)
+
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("UpdateProfile() unexpected error = %v", err)
+				}
+				return
+			}
+			validationErr, ok := err.(*ValidationError)
+			if !ok || validationErr.Field != tt.wantField {
+				t.Fatalf("UpdateProfile() error = %v, want validation error for %s", err, tt.wantField)
+			}
+			if user.Locale != DefaultLocale || user.DisplayName != "" {
+				t.Errorf("UpdateProfile() did not roll back profile: %+v", user)
+			}
+		})
+	}
+}
+
+func TestUser_UpdateProfile_CanonicalLocale(t *testing.T) {
+	user, _ := NewUser("john_doe", "john@example.com", DefaultUsernamePolicy())
+	if err := user.UpdateProfile(UserProfile{Locale: "pt-br"}); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if user.Locale != "pt-BR" {
+		t.Errorf("Locale = %q, want %q", user.Locale, "pt-BR")
+	}
+}
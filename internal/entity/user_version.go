@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// UserVersion - снимок пользователя, действовавший в период [ValidFrom, ValidTo).
+// ValidTo == nil у текущей версии; у удаленного пользователя открытой версии нет
+type UserVersion struct {
+	ID        uint       `gorm:"primaryKey"`
+	TenantID  uint       `gorm:"index"`
+	UserID    uint       `gorm:"index:idx_user_version_period"`
+	Data      User       `gorm:"serializer:json"`
+	ValidFrom time.Time  `gorm:"index:idx_user_version_period"`
+	ValidTo   *time.Time `gorm:"index"`
+}
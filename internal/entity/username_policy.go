@@ -0,0 +1,254 @@
+package entity
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CharacterClass - группа символов, разрешаемая в username
+type CharacterClass uint8
+
+const (
+	Letters    CharacterClass = 1 << iota // буквы любой письменности
+	Digits                                // десятичные цифры
+	Underscore                            // _
+	Dot                                   // .
+	Hyphen                                // -
+)
+
+// characterClassNames - имена классов для сообщений и конфигурации
+var characterClassNames = []struct {
+	class CharacterClass
+	name  string
+}{
+	{Letters, "letters"},
+	{Digits, "digits"},
+	{Underscore, "underscores"},
+	{Dot, "dots"},
+	{Hyphen, "hyphens"},
+}
+
+// ParseCharacterClasses разбирает список классов через запятую:
+// "letters,digits,underscores,dots,hyphens"
+func ParseCharacterClasses(value string) (CharacterClass, bool) {
+	var classes CharacterClass
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, known := range characterClassNames {
+			if known.name == name {
+				classes |= known.class
+				found = true
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return classes, true
+}
+
+func (c CharacterClass) allows(r rune) bool {
+	switch {
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return c&Letters != 0
+	case unicode.IsDigit(r):
+		return c&Digits != 0
+	case r == '_':
+		return c&Underscore != 0
+	case r == '.':
+		return c&Dot != 0
+	case r == '-':
+		return c&Hyphen != 0
+	}
+	return false
+}
+
+func (c CharacterClass) String() string {
+	var names []string
+	for _, known := range characterClassNames {
+		if c&known.class != 0 {
+			names = append(names, known.name)
+		}
+	}
+	if len(names) <= 1 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// DefaultReservedUsernames - имена, которые могут ввести в заблуждение как служебные
+var DefaultReservedUsernames = []string{
+	"abuse", "admin", "administrator", "anonymous", "api", "help", "hostmaster", "info",
+	"moderator", "noreply", "no-reply", "null", "official", "owner", "postmaster", "root",
+	"security", "staff", "superuser", "support", "sysadmin", "system", "undefined", "webmaster", "www",
+}
+
+// UsernamePolicy - правила выбора username для развертывания. Зарезервированные
+// имена сравниваются по каноническому ключу ("Admin" и "аdmin" совпадают с
+// "admin"). Слово блок-листа запрещает username, если совпадает с его словом или
+// несколькими словами подряд с учетом замен вида 4→a, так что "b.4.d" не обходит
+// запрет "bad", а "badminton" и "Scunthorpe" им не затрагиваются
+type UsernamePolicy struct {
+	MinLength      int // в символах
+	MaxLength      int
+	AllowedClasses CharacterClass
+
+	reserved  map[string]bool
+	blocklist []blockedWord
+}
+
+// blockedWord - ключ слова блок-листа; substring - искать как подстроку
+type blockedWord struct {
+	key       string
+	substring bool
+}
+
+// NewUsernamePolicy создает политику без зарезервированных и запрещенных слов
+func NewUsernamePolicy(minLength, maxLength int, allowed CharacterClass) *UsernamePolicy {
+	return &UsernamePolicy{
+		MinLength:      minLength,
+		MaxLength:      maxLength,
+		AllowedClasses: allowed,
+		reserved:       map[string]bool{},
+	}
+}
+
+// DefaultUsernamePolicy - 3-50 символов из букв, цифр, "_", "." и "-" и стандартный список резерва
+func DefaultUsernamePolicy() *UsernamePolicy {
+	return NewUsernamePolicy(3, 50, Letters|Digits|Underscore|Dot|Hyphen).Reserve(DefaultReservedUsernames...)
+}
+
+// Reserve добавляет зарезервированные имена
+func (p *UsernamePolicy) Reserve(words ...string) *UsernamePolicy {
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			p.reserved[CanonicalUsername(word)] = true
+		}
+	}
+	return p
+}
+
+// Block добавляет слова блок-листа. Слово с префиксом "*" ищется как подстрока
+// в любом месте username ("*badword" запрещает и "xbadwordx") - только для слов,
+// которые не встречаются внутри обычных имен
+func (p *UsernamePolicy) Block(words ...string) *UsernamePolicy {
+	for _, word := range words {
+		word, substring := strings.CutPrefix(strings.TrimSpace(word), "*")
+		if key := blocklistKey(word); key != "" {
+			p.blocklist = append(p.blocklist, blockedWord{key: key, substring: substring})
+		}
+	}
+	return p
+}
+
+// Check проверяет username, уже приведенный NormalizeUsername
+func (p *UsernamePolicy) Check(username string) error {
+	length := utf8.RuneCountInString(username)
+	if length < p.MinLength {
+		return &ValidationError{Field: "username", Message: "username must be at least " + strconv.Itoa(p.MinLength) + " characters long"}
+	}
+	if length > p.MaxLength {
+		return &ValidationError{Field: "username", Message: "username cannot exceed " + strconv.Itoa(p.MaxLength) + " characters"}
+	}
+
+	for _, r := range username {
+		if !p.AllowedClasses.allows(r) {
+			return &ValidationError{Field: "username", Message: "username can only contain " + p.AllowedClasses.String()}
+		}
+	}
+
+	if p.reserved[CanonicalUsername(username)] {
+		return &ValidationError{Field: "username", Message: "username is reserved"}
+	}
+
+	if len(p.blocklist) == 0 {
+		return nil
+	}
+	key := blocklistKey(username)
+	words := usernameWords(username)
+	for _, blocked := range p.blocklist {
+		if blocked.substring && strings.Contains(key, blocked.key) || !blocked.substring && containsWordRun(words, blocked.key) {
+			return &ValidationError{Field: "username", Message: "username contains a blocked word"}
+		}
+	}
+	return nil
+}
+
+// containsWordRun сообщает, образуют ли несколько слов подряд ключ key:
+// "b.a.d" и "b4d" дают "bad", а "badminton" - нет
+func containsWordRun(words []string, key string) bool {
+	for i := range words {
+		run := ""
+		for _, word := range words[i:] {
+			run += word
+			if run == key {
+				return true
+			}
+			if len(run) >= len(key) || !strings.HasPrefix(key, run) {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// usernameWords делит username на слова - по разделителям, смене строчной буквы
+// на заглавную ("BadGuy") и границе букв и цифр ("bad42") - и приводит каждое
+// к ключу blocklistKey
+func usernameWords(username string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if key := blocklistKey(string(word)); key != "" {
+			words = append(words, key)
+		}
+		word = word[:0]
+	}
+	var prev rune
+	for _, r := range NormalizeUsername(username) {
+		switch {
+		case r == '_' || r == '.' || r == '-' || unicode.IsSpace(r):
+			flush()
+		case len(word) > 0 && (unicode.IsLower(prev) && unicode.IsUpper(r) || unicode.IsDigit(prev) != unicode.IsDigit(r)):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+		prev = r
+	}
+	flush()
+	return words
+}
+
+// ReadWordList читает список слов: по одному в строке, "#" начинает комментарий
+func ReadWordList(r io.Reader) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			words = append(words, line)
+		}
+	}
+	return words, scanner.Err()
+}
+
+// leetReplacer сводит типичные замены букв цифрами и символами
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// blocklistKey - канонический ключ без разделителей и с обратными leet-заменами
+func blocklistKey(value string) string {
+	key := leetReplacer.Replace(CanonicalUsername(value))
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '.' || r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, key)
+}
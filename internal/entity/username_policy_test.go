@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUsernamePolicy_Check(t *testing.T) {
+	policy := DefaultUsernamePolicy().Block("badword", "cunt", "ass", "*slur")
+
+	tests := []struct {
+		name     string
+		username string
+		wantErr  string
+	}{
+		{"Valid", "john.doe-42", ""},
+		{"Non-Latin letters", "иван_петров", ""},
+		{"Too short by runes", "яя", "at least 3"},
+		{"Space not allowed", "john doe", "can only contain"},
+		{"Symbol not allowed", "john!", "can only contain"},
+		{"Reserved", "admin", "reserved"},
+		{"Reserved case-insensitive", "Support", "reserved"},
+		{"Reserved homoglyph", "аdmin", "reserved"},
+		{"Blocked word", "the_badword_guy", "blocked"},
+		{"Blocked camel case word", "TheBadwordGuy", "blocked"},
+		{"Blocked with digits", "badword42", "blocked"},
+		{"Word inside a longer word", "Scunthorpe", ""},
+		{"Word inside a longer word 2", "classic_bassist", ""},
+		{"Substring entry", "xxslurxx", "blocked"},
+		{"Blocked with separators", "b.a.d-w_o_r_d", "blocked"},
+		{"Blocked leetspeak", "B4DW0RD", "blocked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.username)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Check(%q) unexpected error: %v", tt.username, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Check(%q) error = %v, want %q", tt.username, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUsernamePolicy_Configured(t *testing.T) {
+	policy := NewUsernamePolicy(5, 8, Letters|Digits).Reserve("acme")
+
+	for username, ok := range map[string]bool{"alice": true, "bob": false, "alice_1": false, "averylongname": false, "ACME": false, "admin": true} {
+		if err := policy.Check(username); (err == nil) != ok {
+			t.Errorf("Check(%q) error = %v, want ok %v", username, err, ok)
+		}
+	}
+
+	if err := policy.Check("alice_1"); err == nil || err.Error() != "username can only contain letters and digits" {
+		t.Errorf("unexpected message: %v", err)
+	}
+}
+
+func TestParseCharacterClasses(t *testing.T) {
+	classes, ok := ParseCharacterClasses("letters, digits,underscores")
+	if !ok || classes != Letters|Digits|Underscore {
+		t.Errorf("ParseCharacterClasses() = %v, %v", classes, ok)
+	}
+	if _, ok := ParseCharacterClasses("letters,emoji"); ok {
+		t.Error("unknown class accepted")
+	}
+}
+
+func TestReadWordList(t *testing.T) {
+	words, err := ReadWordList(strings.NewReader("# запрещенные слова\nfoo\n\n  bar  # комментарий\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(words, ",") != "foo,bar" {
+		t.Errorf("ReadWordList() = %q", words)
+	}
+}
+
+func TestUser_UpdateKeepsLegacyUsername(t *testing.T) {
+	// Имя, созданное до появления резерва, не мешает смене email
+	user := &User{Username: "admin", Email: "old@example.com"}
+	if err := user.Update("admin", "new@example.com", DefaultUsernamePolicy()); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if err := user.Update("root", "new@example.com", DefaultUsernamePolicy()); err == nil {
+		t.Error("Update() accepted a reserved username")
+	}
+}
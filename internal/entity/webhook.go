@@ -0,0 +1,158 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// WebhookEventAll подписывает на все события пользователей
+const WebhookEventAll = "*"
+
+// WebhookSubscription - подписка партнера на события пользователей
+type WebhookSubscription struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TenantID   uint      `gorm:"index" json:"-"`
+	URL        string    `json:"url"`
+	EventTypes []string  `gorm:"serializer:json" json:"event_types"`
+	Secret     string    `json:"-"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NewWebhookSubscription создает активную подписку с валидацией
+func NewWebhookSubscription(rawURL string, eventTypes []string, secret string) (*WebhookSubscription, error) {
+	subscription := &WebhookSubscription{
+		URL:        rawURL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		Active:     true,
+	}
+	if err := subscription.Validate(); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// Validate проверяет URL, типы событий и длину секрета
+func (s *WebhookSubscription) Validate() error {
+	parsed, err := url.Parse(s.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return &ValidationError{Field: "url", Message: "url must be an absolute http or https URL"}
+	}
+
+	if len(s.EventTypes) == 0 {
+		return &ValidationError{Field: "event_types", Message: "at least one event type is required"}
+	}
+	for _, eventType := range s.EventTypes {
+		switch UserEventType(eventType) {
+		case UserRegistered, UserUpdated, UserDeleted, WebhookEventAll:
+		default:
+			return &ValidationError{Field: "event_types", Message: "unknown event type: " + eventType}
+		}
+	}
+
+	if len(s.Secret) < 16 {
+		return &ValidationError{Field: "secret", Message: "secret must be at least 16 characters long"}
+	}
+	return nil
+}
+
+// Matches сообщает, подписана ли подписка на событие данного типа
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	if !s.Active {
+		return false
+	}
+	for _, subscribed := range s.EventTypes {
+		if subscribed == WebhookEventAll || subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus - состояние доставки вебхука
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"   // ожидает первой или повторной попытки
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded" // получатель ответил 2xx
+	DeliveryDead      WebhookDeliveryStatus = "dead"      // попытки исчерпаны, нужна ручная переотправка
+)
+
+// WebhookDelivery - доставка одного события одной подписке
+type WebhookDelivery struct {
+	ID             uint                  `gorm:"primaryKey" json:"id"`
+	TenantID       uint                  `json:"-"` // тенант подписки
+	SubscriptionID uint                  `gorm:"uniqueIndex:idx_delivery_subscription_event;index" json:"subscription_id"`
+	EventID        string                `gorm:"uniqueIndex:idx_delivery_subscription_event;size:36" json:"event_id"`
+	EventType      string                `json:"event_type"`
+	Payload        []byte                `json:"-"`
+	Status         WebhookDeliveryStatus `gorm:"index" json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `gorm:"index" json:"next_attempt_at"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// MarkSucceeded фиксирует успешную доставку
+func (d *WebhookDelivery) MarkSucceeded(statusCode int, at time.Time) {
+	d.Attempts++
+	d.Status = DeliverySucceeded
+	d.LastStatusCode = statusCode
+	d.LastError = ""
+	d.DeliveredAt = &at
+}
+
+// MarkFailed фиксирует неудачную попытку: планирует повтор через retryDelay
+// или, если попыток стало maxAttempts, переводит доставку в DeliveryDead
+func (d *WebhookDelivery) MarkFailed(statusCode int, reason string, at time.Time, maxAttempts int, retryDelay time.Duration) {
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	d.LastError = reason
+	if d.Attempts >= maxAttempts {
+		d.Status = DeliveryDead
+		return
+	}
+	d.Status = DeliveryPending
+	d.NextAttemptAt = at.Add(retryDelay)
+}
+
+// MarkDead переводит доставку в DeliveryDead без попытки отправки,
+// например когда подписка уже удалена
+func (d *WebhookDelivery) MarkDead(reason string, at time.Time) {
+	d.Status = DeliveryDead
+	d.LastError = reason
+	d.NextAttemptAt = at
+}
+
+// Redeliver возвращает доставку в очередь с полным запасом попыток
+func (d *WebhookDelivery) Redeliver(at time.Time) {
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = at
+	d.DeliveredAt = nil
+}
+
+// SignWebhookPayload вычисляет подпись HMAC-SHA256 от "<timestamp>.<payload>".
+// Метка времени в подписи не дает переиграть старый запрос
+func SignWebhookPayload(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature проверяет подпись, полученную получателем вебхука
+func VerifyWebhookSignature(secret string, timestamp time.Time, payload []byte, signature string) bool {
+	expected := SignWebhookPayload(secret, timestamp, payload)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWebhookSubscription(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		eventTypes []string
+		secret     string
+		wantErr    bool
+	}{
+		{"Valid subscription", "https://partner.example.com/hook", []string{"user.registered"}, "0123456789abcdef", false},
+		{"Wildcard event", "http://localhost:9000/hook", []string{WebhookEventAll}, "0123456789abcdef", false},
+		{"Relative URL", "/hook", []string{"user.registered"}, "0123456789abcdef", true},
+		{"Unsupported scheme", "ftp://partner.example.com", []string{"user.registered"}, "0123456789abcdef", true},
+		{"No event types", "https://partner.example.com/hook", nil, "0123456789abcdef", true},
+		{"Unknown event type", "https://partner.example.com/hook", []string{"user.exploded"}, "0123456789abcdef", true},
+		{"Short secret", "https://partner.example.com/hook", []string{"user.registered"}, "short", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWebhookSubscription(tt.url, tt.eventTypes, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewWebhookSubscription() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookDelivery_MarkFailed(t *testing.T) {
+	now := time.Now()
+	delivery := &WebhookDelivery{Status: DeliveryPending}
+
+	delivery.MarkFailed(500, "boom", now, 2, time.Minute)
+	if delivery.Status != DeliveryPending || !delivery.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("first failure: status = %s, next attempt = %v", delivery.Status, delivery.NextAttemptAt)
+	}
+
+	delivery.MarkFailed(500, "boom", now, 2, time.Minute)
+	if delivery.Status != DeliveryDead || delivery.Attempts != 2 {
+		t.Errorf("second failure: status = %s, attempts = %d", delivery.Status, delivery.Attempts)
+	}
+
+	delivery.Redeliver(now)
+	if delivery.Status != DeliveryPending || delivery.Attempts != 0 {
+		t.Errorf("redeliver: status = %s, attempts = %d", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	payload := []byte(`{"id":"1"}`)
+	signature := SignWebhookPayload("0123456789abcdef", ts, payload)
+
+	if !VerifyWebhookSignature("0123456789abcdef", ts, payload, signature) {
+		t.Error("valid signature rejected")
+	}
+	if VerifyWebhookSignature("another-secret-value", ts, payload, signature) {
+		t.Error("signature accepted with wrong secret")
+	}
+	if VerifyWebhookSignature("0123456789abcdef", ts.Add(time.Second), payload, signature) {
+		t.Error("signature accepted with wrong timestamp")
+	}
+}
@@ -0,0 +1,126 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// findOperation возвращает операцию документа, которую выполнит запрос с operationName
+func findOperation(doc *ast.Document, operationName string) (*ast.OperationDefinition, error) {
+	var operation *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		def, ok := definition.(*ast.OperationDefinition)
+		if ok && (operationName == "" || (def.Name != nil && def.Name.Value == operationName)) {
+			operation = def
+		}
+	}
+	if operation == nil {
+		return nil, fmt.Errorf("unknown operation %q", operationName)
+	}
+	return operation, nil
+}
+
+// queryComplexity оценивает стоимость операции: каждое поле стоит 1,
+// а стоимость вложенных полей списка умножается на размер страницы (first)
+func queryComplexity(doc *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) int {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range doc.Definitions {
+		if def, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[def.Name.Value] = def
+		}
+	}
+
+	c := complexityCalculator{fragments: fragments, variables: variables}
+	return c.selectionSet(operation.SelectionSet)
+}
+
+// rootFields возвращает имена полей верхнего уровня операции, включая поля из
+// фрагментов; поле под несколькими псевдонимами встречается несколько раз
+func rootFields(doc *ast.Document, operation *ast.OperationDefinition) []string {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range doc.Definitions {
+		if def, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[def.Name.Value] = def
+		}
+	}
+
+	var fields []string
+	var collect func(set *ast.SelectionSet)
+	collect = func(set *ast.SelectionSet) {
+		if set == nil {
+			return
+		}
+		for _, selection := range set.Selections {
+			switch sel := selection.(type) {
+			case *ast.Field:
+				fields = append(fields, sel.Name.Value)
+			case *ast.InlineFragment:
+				collect(sel.SelectionSet)
+			case *ast.FragmentSpread:
+				if fragment, ok := fragments[sel.Name.Value]; ok {
+					collect(fragment.SelectionSet)
+				}
+			}
+		}
+	}
+	collect(operation.SelectionSet)
+	return fields
+}
+
+type complexityCalculator struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func (c complexityCalculator) selectionSet(set *ast.SelectionSet) int {
+	if set == nil {
+		return 0
+	}
+	total := 0
+	for _, selection := range set.Selections {
+		switch sel := selection.(type) {
+		case *ast.Field:
+			total += 1 + c.selectionSet(sel.SelectionSet)*c.multiplier(sel)
+		case *ast.InlineFragment:
+			total += c.selectionSet(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			if fragment, ok := c.fragments[sel.Name.Value]; ok {
+				total += c.selectionSet(fragment.SelectionSet)
+			}
+		}
+	}
+	return total
+}
+
+// multiplier возвращает ожидаемое число элементов, которые вернет поле
+func (c complexityCalculator) multiplier(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			// JSON-числа декодируются как float64
+			switch n := c.variables[value.Name.Value].(type) {
+			case float64:
+				if n > 0 {
+					return int(n)
+				}
+			case int:
+				if n > 0 {
+					return n
+				}
+			}
+		}
+	}
+	if field.Name.Value == "users" {
+		return defaultPageSize
+	}
+	return 1
+}
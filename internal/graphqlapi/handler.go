@@ -0,0 +1,126 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// DefaultMaxComplexity - допустимая по умолчанию стоимость запроса
+const DefaultMaxComplexity = 1000
+
+type Handler struct {
+	schema        graphql.Schema
+	userService   service.UserServiceInterface
+	maxComplexity int
+
+	// FieldLimits - ограничения частоты по именам полей верхнего уровня
+	// операции, например registerUser; проверяются для каждого вхождения поля
+	FieldLimits map[string]FieldLimit
+}
+
+// FieldLimit забирает токен лимита для запроса; false - лимит исчерпан и
+// запрос отклоняется с 429 без выполнения (см. controller.RateLimiter.Allow)
+type FieldLimit func(ctx *fiber.Ctx) bool
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// NewHandler создает обработчик /graphql; maxComplexity <= 0 означает DefaultMaxComplexity
+func NewHandler(userService service.UserServiceInterface, maxComplexity int) (*Handler, error) {
+	schema, err := NewSchema(userService)
+	if err != nil {
+		return nil, err
+	}
+	if maxComplexity <= 0 {
+		maxComplexity = DefaultMaxComplexity
+	}
+	return &Handler{schema: schema, userService: userService, maxComplexity: maxComplexity}, nil
+}
+
+// Serve обрабатывает GET (query в параметрах) и POST (JSON-тело) запросы.
+// Мутации выполняются только через POST: GET может отправить чужая страница (CSRF)
+func (h *Handler) Serve(ctx *fiber.Ctx) error {
+	var req graphQLRequest
+	if ctx.Method() == fiber.MethodGet {
+		req.Query = ctx.Query("query")
+		req.OperationName = ctx.Query("operationName")
+		if variables := ctx.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return requestError(ctx, "invalid variables: "+err.Error())
+			}
+		}
+	} else if err := ctx.BodyParser(&req); err != nil {
+		return requestError(ctx, err.Error())
+	}
+	if req.Query == "" {
+		return requestError(ctx, "query is required")
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+	}
+
+	validation := graphql.ValidateDocument(&h.schema, doc, nil)
+	if !validation.IsValid {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&graphql.Result{Errors: validation.Errors})
+	}
+
+	operation, err := findOperation(doc, req.OperationName)
+	if err != nil {
+		return requestError(ctx, err.Error())
+	}
+	if ctx.Method() == fiber.MethodGet && operation.Operation != ast.OperationTypeQuery {
+		ctx.Set(fiber.HeaderAllow, fiber.MethodPost)
+		return ctx.Status(fiber.StatusMethodNotAllowed).JSON(&graphql.Result{
+			Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(operation.Operation + " operations require POST")},
+		})
+	}
+
+	complexity := queryComplexity(doc, operation, req.Variables)
+	if complexity > h.maxComplexity {
+		return requestError(ctx, fmt.Sprintf("query complexity %d exceeds limit %d", complexity, h.maxComplexity))
+	}
+	for _, field := range rootFields(doc, operation) {
+		if limit, ok := h.FieldLimits[field]; ok && !limit(ctx) {
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(&graphql.Result{
+				Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError("rate limit exceeded")},
+			})
+		}
+	}
+
+	// Загрузчик живет в рамках одного запроса, чтобы кеш не устаревал между запросами
+	userCtx := ctx.UserContext()
+	loader := newUserLoader(func(ids []uint) ([]entity.User, error) {
+		return h.userService.GetUsersByIDs(userCtx, ids)
+	})
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoader(userCtx, loader),
+	})
+	return ctx.JSON(result)
+}
+
+func requestError(ctx *fiber.Ctx, message string) error {
+	return ctx.Status(fiber.StatusBadRequest).JSON(&graphql.Result{
+		Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(message)},
+	})
+}
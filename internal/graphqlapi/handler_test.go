@@ -0,0 +1,230 @@
+package graphqlapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"multilayer/internal/entity"
+	"multilayer/internal/graphqlapi"
+	"multilayer/internal/repository"
+	"multilayer/internal/service/mocks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func setupApp(t *testing.T, mockService *mocks.UserService, maxComplexity int) *fiber.App {
+	handler, err := graphqlapi.NewHandler(mockService, maxComplexity)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Get("/graphql", handler.Serve)
+	app.Post("/graphql", handler.Serve)
+	return app
+}
+
+func doQuery(t *testing.T, app *fiber.App, query string, variables map[string]interface{}) (int, graphQLResponse) {
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result graphQLResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return resp.StatusCode, result
+}
+
+func TestHandler_UserLookupsAreBatched(t *testing.T) {
+	mockService := new(mocks.UserService)
+	app := setupApp(t, mockService, 0)
+
+	// Три поля user (одно повторяется) должны привести к одному вызову GetUsersByIDs
+	mockService.On("GetUsersByIDs", []uint{1, 2, 3}).Return([]entity.User{
+		{ID: 1, Username: "alice", Email: "alice@example.com"},
+		{ID: 2, Username: "bob", Email: "bob@example.com"},
+	}, nil).Once()
+
+	status, result := doQuery(t, app, `{
+		a: user(id: "1") { id username }
+		b: user(id: "2") { email }
+		c: user(id: "1") { username }
+		missing: user(id: "3") { id }
+	}`, nil)
+
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, "alice", result.Data["a"].(map[string]interface{})["username"])
+	assert.Equal(t, "bob@example.com", result.Data["b"].(map[string]interface{})["email"])
+	assert.Equal(t, "alice", result.Data["c"].(map[string]interface{})["username"])
+	assert.Nil(t, result.Data["missing"])
+	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "GetUser", mock.Anything)
+}
+
+func TestHandler_UsersConnection(t *testing.T) {
+	mockService := new(mocks.UserService)
+	app := setupApp(t, mockService, 0)
+
+	filter := repository.UserFilter{Username: "user"}
+	mockService.On("ListUsers", filter, uint(0), 3).Return([]entity.User{
+		{ID: 1, Username: "user1", Email: "user1@example.com"},
+		{ID: 2, Username: "user2", Email: "user2@example.com"},
+		{ID: 3, Username: "user3", Email: "user3@example.com"},
+	}, nil)
+
+	status, result := doQuery(t, app, `query($first: Int) {
+		users(filter: {username: "user"}, first: $first) {
+			nodes { username }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`, map[string]interface{}{"first": 2})
+
+	require.Equal(t, fiber.StatusOK, status)
+	require.Empty(t, result.Errors)
+	users := result.Data["users"].(map[string]interface{})
+	assert.Len(t, users["nodes"], 2)
+	pageInfo := users["pageInfo"].(map[string]interface{})
+	assert.Equal(t, true, pageInfo["hasNextPage"])
+	assert.NotEmpty(t, pageInfo["endCursor"])
+
+	// Курсор endCursor продолжает выборку после последнего пользователя страницы
+	mockService.On("ListUsers", repository.UserFilter{}, uint(2), 3).Return([]entity.User{
+		{ID: 3, Username: "user3", Email: "user3@example.com"},
+	}, nil)
+
+	_, result = doQuery(t, app, `query($after: String) {
+		users(first: 2, after: $after) { nodes { id } pageInfo { hasNextPage } }
+	}`, map[string]interface{}{"after": pageInfo["endCursor"]})
+
+	require.Empty(t, result.Errors)
+	users = result.Data["users"].(map[string]interface{})
+	assert.Len(t, users["nodes"], 1)
+	assert.Equal(t, false, users["pageInfo"].(map[string]interface{})["hasNextPage"])
+}
+
+func TestHandler_UsersStatusFilter(t *testing.T) {
+	mockService := new(mocks.UserService)
+	app := setupApp(t, mockService, 0)
+
+	filter := repository.UserFilter{Statuses: []entity.UserStatus{entity.UserSuspended}}
+	mockService.On("ListUsers", filter, uint(0), 21).Return([]entity.User{
+		{ID: 1, Username: "spammer", Email: "spammer@example.com", Status: entity.UserSuspended},
+	}, nil)
+
+	_, result := doQuery(t, app, `query {
+		users(filter: {statuses: ["suspended"]}) { nodes { username status } }
+	}`, nil)
+
+	require.Empty(t, result.Errors)
+	nodes := result.Data["users"].(map[string]interface{})["nodes"].([]interface{})
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "suspended", nodes[0].(map[string]interface{})["status"])
+
+	_, result = doQuery(t, app, `query {
+		users(filter: {statuses: ["banned"]}) { nodes { id } }
+	}`, nil)
+
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "BAD_USER_INPUT", result.Errors[0].Extensions["code"])
+	mockService.AssertNumberOfCalls(t, "ListUsers", 1)
+}
+
+func TestHandler_RegisterUserValidationError(t *testing.T) {
+	mockService := new(mocks.UserService)
+	app := setupApp(t, mockService, 0)
+
+	mockService.On("RegisterUser", "ab", "ab@example.com").
+		Return(nil, &entity.ValidationError{Field: "username", Message: "username must be at least 3 characters long"})
+
+	_, result := doQuery(t, app, `mutation {
+		registerUser(username: "ab", email: "ab@example.com") { id }
+	}`, nil)
+
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "username must be at least 3 characters long", result.Errors[0].Message)
+	assert.Equal(t, "BAD_USER_INPUT", result.Errors[0].Extensions["code"])
+}
+
+func TestHandler_ComplexityLimit(t *testing.T) {
+	mockService := new(mocks.UserService)
+	app := setupApp(t, mockService, 50)
+
+	// 1 (users) + 100 * (1 (nodes) + 3 поля) превышает лимит 50
+	status, result := doQuery(t, app, `{
+		users(first: 100) { nodes { id username email } }
+	}`, nil)
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, "exceeds limit")
+	mockService.AssertNotCalled(t, "ListUsers", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandler_GetAllowsOnlyQueries(t *testing.T) {
+	mockService := new(mocks.UserService)
+	app := setupApp(t, mockService, 0)
+	mockService.On("GetUsersByIDs", []uint{1}).Return([]entity.User{
+		{ID: 1, Username: "alice", Email: "alice@example.com"},
+	}, nil)
+
+	get := func(query string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil))
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := get(`{ user(id: "1") { username } }`)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp = get(`mutation { registerUser(username: "mallory", email: "mallory@example.com") { id } }`)
+	assert.Equal(t, fiber.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, fiber.MethodPost, resp.Header.Get(fiber.HeaderAllow))
+	mockService.AssertNotCalled(t, "RegisterUser", mock.Anything, mock.Anything)
+}
+
+func TestHandler_FieldLimits(t *testing.T) {
+	mockService := new(mocks.UserService)
+	handler, err := graphqlapi.NewHandler(mockService, 0)
+	require.NoError(t, err)
+	// Лимит пропускает две регистрации
+	tokens := 2
+	handler.FieldLimits = map[string]graphqlapi.FieldLimit{
+		"registerUser": func(*fiber.Ctx) bool {
+			tokens--
+			return tokens >= 0
+		},
+	}
+	app := fiber.New()
+	app.Post("/graphql", handler.Serve)
+	mockService.On("RegisterUser", "alice", "alice@example.com").
+		Return(&entity.User{ID: 1, Username: "alice", Email: "alice@example.com"}, nil)
+
+	status, _ := doQuery(t, app, `mutation { registerUser(username: "alice", email: "alice@example.com") { id } }`, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Каждый псевдоним и поле из фрагмента расходуют свой токен
+	status, result := doQuery(t, app, `mutation {
+		a: registerUser(username: "alice", email: "alice@example.com") { id }
+		... on Mutation { b: registerUser(username: "alice", email: "alice@example.com") { id } }
+	}`, nil)
+	assert.Equal(t, fiber.StatusTooManyRequests, status)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "rate limit exceeded", result.Errors[0].Message)
+	mockService.AssertNumberOfCalls(t, "RegisterUser", 1)
+}
@@ -0,0 +1,94 @@
+package graphqlapi
+
+import (
+	"context"
+	"multilayer/internal/entity"
+	"slices"
+	"sync"
+)
+
+type loaderKey struct{}
+
+// userLoader собирает ID, запрошенные резолверами одного уровня запроса,
+// и загружает их одним вызовом GetUsersByIDs вместо N вызовов GetUser
+type userLoader struct {
+	fetch func(ids []uint) ([]entity.User, error)
+
+	mu      sync.Mutex
+	pending []uint
+	cache   map[uint]*entity.User
+	errs    map[uint]error
+}
+
+func newUserLoader(fetch func(ids []uint) ([]entity.User, error)) *userLoader {
+	return &userLoader{
+		fetch: fetch,
+		cache: make(map[uint]*entity.User),
+		errs:  make(map[uint]error),
+	}
+}
+
+// withLoader кладет загрузчик в контекст запроса
+func withLoader(ctx context.Context, loader *userLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+func loaderFrom(ctx context.Context) *userLoader {
+	loader, _ := ctx.Value(loaderKey{}).(*userLoader)
+	return loader
+}
+
+// Load ставит ID в очередь и возвращает thunk; graphql-go вызывает thunk-и
+// после обхода всех полей уровня, поэтому первый из них загружает весь пакет
+func (l *userLoader) Load(id uint) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, loaded := l.cache[id]; !loaded && !l.isPending(id) {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.dispatch()
+		if err := l.errs[id]; err != nil {
+			return nil, err
+		}
+		// nil означает, что пользователь не найден
+		if user := l.cache[id]; user != nil {
+			return user, nil
+		}
+		return nil, nil
+	}
+}
+
+// dispatch загружает все ожидающие ID; вызывается под l.mu
+func (l *userLoader) dispatch() {
+	if len(l.pending) == 0 {
+		return
+	}
+	// Порядок полей при обходе graphql-go не фиксирован; сортируем для стабильного запроса
+	ids := l.pending
+	l.pending = nil
+	slices.Sort(ids)
+
+	users, err := l.fetch(ids)
+	for _, id := range ids {
+		l.cache[id] = nil
+		if err != nil {
+			l.errs[id] = err
+		}
+	}
+	for i := range users {
+		l.cache[users[i].ID] = &users[i]
+	}
+}
+
+func (l *userLoader) isPending(id uint) bool {
+	for _, pendingID := range l.pending {
+		if pendingID == id {
+			return true
+		}
+	}
+	return false
+}
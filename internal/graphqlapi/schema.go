@@ -0,0 +1,302 @@
+// (GraphQL транспорт поверх сервисного слоя)
+package graphqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	cursorPrefix    = "user:"
+)
+
+// apiError - ошибка резолвера с кодом в extensions ответа
+type apiError struct {
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func (e *apiError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// NewSchema строит GraphQL-схему, резолверы которой работают через UserServiceInterface
+func NewSchema(userService service.UserServiceInterface) (graphql.Schema, error) {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return strconv.FormatUint(uint64(p.Source.(*entity.User).ID), 10), nil
+				},
+			},
+			"username": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*entity.User).Username, nil
+				},
+			},
+			"email": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*entity.User).Email, nil
+				},
+			},
+			"displayName": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*entity.User).GetDisplayName(), nil
+				},
+			},
+			"status": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return string(p.Source.(*entity.User).EffectiveStatus()), nil
+				},
+			},
+		},
+	})
+
+	userEdgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(userType)},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	userConnectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserConnection",
+		Fields: graphql.Fields{
+			"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userEdgeType)))},
+			"nodes":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType)))},
+			"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	userFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UserFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"username": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Подстрока username без учета регистра",
+			},
+			"email": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Подстрока email без учета регистра",
+			},
+			"statuses": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "Статусы пользователей; по умолчанию suspended и deactivated скрыты",
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := parseID(p.Args["id"])
+					if err != nil {
+						return nil, err
+					}
+					if loader := loaderFrom(p.Context); loader != nil {
+						return loader.Load(id), nil
+					}
+					user, err := userService.GetUser(p.Context, id)
+					if errors.Is(err, service.ErrUserNotFound) {
+						return nil, nil
+					}
+					return user, toAPIError(err)
+				},
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(userConnectionType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: userFilterType},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveUsers(p.Context, userService, p.Args)
+				},
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"registerUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"username": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"email":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					user, err := userService.RegisterUser(p.Context, p.Args["username"].(string), p.Args["email"].(string))
+					if err != nil {
+						return nil, toAPIError(err)
+					}
+					return user, nil
+				},
+			},
+			"updateUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"username": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"email":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := parseID(p.Args["id"])
+					if err != nil {
+						return nil, err
+					}
+					user, err := userService.UpdateUser(p.Context, id, p.Args["username"].(string), p.Args["email"].(string))
+					if err != nil {
+						return nil, toAPIError(err)
+					}
+					return user, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+func resolveUsers(ctx context.Context, userService service.UserServiceInterface, args map[string]interface{}) (interface{}, error) {
+	first, _ := args["first"].(int)
+	if first <= 0 || first > maxPageSize {
+		return nil, &apiError{code: "BAD_USER_INPUT", message: fmt.Sprintf("first must be between 1 and %d", maxPageSize)}
+	}
+
+	var afterID uint
+	if after, ok := args["after"].(string); ok && after != "" {
+		id, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		afterID = id
+	}
+
+	var filter repository.UserFilter
+	if input, ok := args["filter"].(map[string]interface{}); ok {
+		filter.Username, _ = input["username"].(string)
+		filter.Email, _ = input["email"].(string)
+		statuses, _ := input["statuses"].([]interface{})
+		for _, value := range statuses {
+			name, _ := value.(string)
+			status, err := entity.ParseUserStatus(name)
+			if err != nil {
+				return nil, toAPIError(err)
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	users, err := userService.ListUsers(ctx, filter, afterID, first+1)
+	if err != nil {
+		return nil, toAPIError(err)
+	}
+	hasNextPage := len(users) > first
+	if hasNextPage {
+		users = users[:first]
+	}
+
+	edges := make([]map[string]interface{}, 0, len(users))
+	nodes := make([]*entity.User, 0, len(users))
+	var endCursor interface{}
+	for i := range users {
+		cursor := encodeCursor(users[i].ID)
+		edges = append(edges, map[string]interface{}{"cursor": cursor, "node": &users[i]})
+		nodes = append(nodes, &users[i])
+		endCursor = cursor
+	}
+
+	return map[string]interface{}{
+		"edges": edges,
+		"nodes": nodes,
+		"pageInfo": map[string]interface{}{
+			"hasNextPage": hasNextPage,
+			"endCursor":   endCursor,
+		},
+	}, nil
+}
+
+func parseID(value interface{}) (uint, error) {
+	raw, _ := value.(string)
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, &apiError{code: "BAD_USER_INPUT", message: "invalid user id"}
+	}
+	return uint(id), nil
+}
+
+func encodeCursor(id uint) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	invalid := &apiError{code: "BAD_USER_INPUT", message: "invalid cursor"}
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, invalid
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(string(raw), cursorPrefix), 10, 64)
+	if err != nil {
+		return 0, invalid
+	}
+	return uint(id), nil
+}
+
+// toAPIError сопоставляет доменные ошибки с кодами GraphQL-ошибок
+func toAPIError(err error) error {
+	var validationErr *entity.ValidationError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &validationErr):
+		return &apiError{code: "BAD_USER_INPUT", message: validationErr.Error()}
+	case errors.Is(err, service.ErrUserNotFound):
+		return &apiError{code: "NOT_FOUND", message: err.Error()}
+	case errors.Is(err, service.ErrUserAlreadyExists):
+		return &apiError{code: "CONFLICT", message: err.Error()}
+	default:
+		return &apiError{code: "INTERNAL", message: err.Error()}
+	}
+}
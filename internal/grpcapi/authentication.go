@@ -0,0 +1,113 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/audit"
+	"multilayer/internal/grpcapi/userv1"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthInterceptor проверяет пользователя из claim sub токена в authorization:
+// Bearer по тем же правилам, что и controller.Authenticate. Ставится в цепочке
+// после TenantInterceptor; проверенный sub становится инициатором в журнале аудита
+func AuthInterceptor(userService service.UserServiceInterface, verifier *tenancy.TokenVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(authorizationMetadataKey)
+		if len(values) == 0 {
+			return handler(ctx, req)
+		}
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok {
+			return handler(ctx, req)
+		}
+		subject, err := verifier.Subject(strings.TrimSpace(token))
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if subject == "" {
+			return handler(ctx, req)
+		}
+		id, err := strconv.ParseUint(subject, 10, 0)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token subject")
+		}
+
+		_, err = userService.AuthenticateUser(ctx, uint(id))
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return nil, status.Error(codes.Unauthenticated, "unknown user")
+		case errors.Is(err, service.ErrUserInactive):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case err != nil:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return handler(audit.WithActor(ctx, subject), req)
+	}
+}
+
+// RequireTokenInterceptor отклоняет вызовы без токена Bearer с действительной
+// подписью, как controller.RequireToken
+func RequireTokenInterceptor(verifier *tenancy.TokenVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(authorizationMetadataKey)
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "token is required")
+		}
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "token is required")
+		}
+		if _, err := verifier.Subject(strings.TrimSpace(token)); err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return handler(ctx, req)
+	}
+}
+
+// adminMethods - вызовы, доступные только с ролью администратора, как маршруты
+// /admin в HTTP
+var adminMethods = map[string]bool{
+	userv1.UserService_DeleteUser_FullMethodName: true,
+}
+
+// AdminInterceptor пропускает административные вызовы только с токеном, в
+// claim roles которого есть tenancy.AdminRole, как controller.RequireAdmin.
+// Без verifier (токены не настроены) они закрыты
+func AdminInterceptor(verifier *tenancy.TokenVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !adminMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		if verifier == nil {
+			return nil, status.Error(codes.PermissionDenied, "admin access is not configured")
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(authorizationMetadataKey)
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "token is required")
+		}
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "token is required")
+		}
+		admin, err := verifier.HasRole(strings.TrimSpace(token), tenancy.AdminRole)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if !admin {
+			return nil, status.Error(codes.PermissionDenied, "admin role is required")
+		}
+		return handler(ctx, req)
+	}
+}
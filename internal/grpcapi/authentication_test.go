@@ -0,0 +1,97 @@
+package grpcapi_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"multilayer/internal/audit"
+	"multilayer/internal/entity"
+	"multilayer/internal/grpcapi"
+	"multilayer/internal/grpcapi/userv1"
+	"multilayer/internal/service/mocks"
+	"multilayer/internal/tenancy"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testTokenSecret = []byte("tenant-token-secret")
+
+func signToken(claims map[string]any) string {
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, testTokenSecret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthInterceptor_ActorFromToken(t *testing.T) {
+	mockService := new(mocks.UserService)
+	mockService.On("AuthenticateUser", uint(7)).Return(&entity.User{ID: 7, Status: entity.UserActive}, nil)
+	interceptor := grpcapi.AuthInterceptor(mockService, &tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"})
+
+	var actor string
+	handler := func(ctx context.Context, _ any) (any, error) {
+		actor = audit.FromContext(ctx).Actor
+		return nil, nil
+	}
+
+	// Инициатор из метаданных вызова игнорируется, берется claim sub
+	ctx := metadata.NewIncomingContext(audit.WithMetadata(context.Background(), audit.Metadata{}), metadata.Pairs(
+		"authorization", "Bearer "+signToken(map[string]any{"sub": "7"}),
+		"x-actor", "admin",
+	))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "user:7", actor)
+
+	ctx = metadata.NewIncomingContext(audit.WithMetadata(context.Background(), audit.Metadata{}), metadata.Pairs("x-actor", "admin"))
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, audit.AnonymousActor, actor)
+}
+
+func TestRequireTokenInterceptor(t *testing.T) {
+	interceptor := grpcapi.RequireTokenInterceptor(&tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"})
+	handler := func(context.Context, any) (any, error) { return nil, nil }
+	call := func(pairs ...string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		return err
+	}
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(call()))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("authorization", "Bearer "+signToken(map[string]any{"sub": "7"})+"x")))
+	assert.NoError(t, call("authorization", "Bearer "+signToken(map[string]any{"tenant": "acme"})))
+}
+
+func TestAdminInterceptor(t *testing.T) {
+	verifier := &tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"}
+	handler := func(context.Context, any) (any, error) { return nil, nil }
+	call := func(interceptor grpc.UnaryServerInterceptor, method string, pairs ...string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+	deleteUser := userv1.UserService_DeleteUser_FullMethodName
+	adminToken := "Bearer " + signToken(map[string]any{"tenant": "acme", "roles": []string{"admin"}})
+
+	// Остальные вызовы не требуют роли
+	assert.NoError(t, call(grpcapi.AdminInterceptor(verifier), userv1.UserService_GetUser_FullMethodName))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(grpcapi.AdminInterceptor(verifier), deleteUser)))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(grpcapi.AdminInterceptor(verifier), deleteUser,
+		"authorization", "Bearer "+signToken(map[string]any{"tenant": "acme", "sub": "7"}))))
+	assert.NoError(t, call(grpcapi.AdminInterceptor(verifier), deleteUser, "authorization", adminToken))
+	// Без токенов административные вызовы закрыты
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(grpcapi.AdminInterceptor(nil), deleteUser, "authorization", adminToken)))
+}
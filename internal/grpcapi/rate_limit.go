@@ -0,0 +1,45 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// MethodLimit забирает токен лимита для клиента с адресом addr; false - лимит
+// исчерпан (см. controller.RateLimiter.AllowAddr)
+type MethodLimit func(ctx context.Context, addr string) bool
+
+// RateLimitInterceptor ограничивает вызовы методов из limits (полное имя
+// метода - лимит) по адресу клиента из соединения; при превышении отвечает
+// ResourceExhausted. Балансировщик перед gRPC-сервером должен сохранять адрес
+// клиента, иначе все вызовы попадут в одну корзину
+func RateLimitInterceptor(limits map[string]MethodLimit) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		limit, ok := limits[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		if !limit(ctx, peerAddr(ctx)) {
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// peerAddr возвращает IP клиента без порта или пустую строку, если адрес неизвестен
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
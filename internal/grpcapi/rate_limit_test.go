@@ -0,0 +1,37 @@
+package grpcapi_test
+
+import (
+	"context"
+	"multilayer/internal/grpcapi"
+	"multilayer/internal/grpcapi/userv1"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimitInterceptor(t *testing.T) {
+	var clients []string
+	interceptor := grpcapi.RateLimitInterceptor(map[string]grpcapi.MethodLimit{
+		userv1.UserService_RegisterUser_FullMethodName: func(_ context.Context, addr string) bool {
+			clients = append(clients, addr)
+			return len(clients) <= 1
+		},
+	})
+	handler := func(context.Context, any) (any, error) { return nil, nil }
+	call := func(method string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 50000}})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	assert.NoError(t, call(userv1.UserService_RegisterUser_FullMethodName))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call(userv1.UserService_RegisterUser_FullMethodName)))
+	// Методы без лимита не расходуют токены
+	assert.NoError(t, call(userv1.UserService_GetUser_FullMethodName))
+	assert.Equal(t, []string{"203.0.113.7", "203.0.113.7"}, clients)
+}
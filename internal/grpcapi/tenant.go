@@ -0,0 +1,73 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Ключи метаданных, из которых определяется тенант вызова
+const (
+	tenantMetadataKey        = "x-tenant"
+	authorizationMetadataKey = "authorization"
+)
+
+// TenantInterceptor определяет тенанта вызова по claim токена из
+// authorization: Bearer, если задан verifier, и иначе по метаданным x-tenant,
+// которые клиент может подставить любыми. Правила те же, что у
+// controller.ResolveTenant: без тенанта вызов отклоняется (required) или
+// выполняется в entity.DefaultTenantID
+func TenantInterceptor(tenantService service.TenantServiceInterface, required bool, verifier *tenancy.TokenVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		var candidates []string
+		if values := md.Get(tenantMetadataKey); verifier == nil && len(values) > 0 {
+			candidates = append(candidates, strings.TrimSpace(values[0]))
+		}
+		if values := md.Get(authorizationMetadataKey); verifier != nil && len(values) > 0 {
+			if token, ok := strings.CutPrefix(values[0], "Bearer "); ok {
+				slug, err := verifier.Tenant(strings.TrimSpace(token))
+				if err != nil {
+					return nil, status.Error(codes.Unauthenticated, err.Error())
+				}
+				candidates = append(candidates, slug)
+			}
+		}
+
+		var slug string
+		for _, candidate := range candidates {
+			if candidate == "" {
+				continue
+			}
+			if slug != "" && !strings.EqualFold(slug, candidate) {
+				return nil, status.Error(codes.PermissionDenied, "conflicting tenant in request")
+			}
+			slug = candidate
+		}
+
+		if slug == "" {
+			if required {
+				return nil, status.Error(codes.InvalidArgument, "tenant is required")
+			}
+			return handler(tenancy.WithTenant(ctx, entity.DefaultTenantID), req)
+		}
+
+		tenant, err := tenantService.GetTenant(slug)
+		if errors.Is(err, service.ErrTenantNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return handler(tenancy.WithTenant(ctx, tenant.ID), req)
+	}
+}
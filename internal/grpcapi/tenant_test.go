@@ -0,0 +1,69 @@
+package grpcapi_test
+
+import (
+	"context"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/grpcapi"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTenantInterceptor(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.Tenant{}))
+	tenantService := service.NewTenantService(repository.NewTenantRepository(db))
+	acme, err := tenantService.CreateTenant("acme", "")
+	require.NoError(t, err)
+	_, err = tenantService.CreateTenant("globex", "")
+	require.NoError(t, err)
+
+	call := func(interceptor grpc.UnaryServerInterceptor, pairs ...string) (uint, error) {
+		var tenantID uint
+		handler := func(ctx context.Context, _ any) (any, error) {
+			id, ok := tenancy.FromContext(ctx)
+			require.True(t, ok)
+			tenantID = id
+			return nil, nil
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		return tenantID, err
+	}
+
+	t.Run("Metadata without tokens", func(t *testing.T) {
+		interceptor := grpcapi.TenantInterceptor(tenantService, false, nil)
+		tenantID, err := call(interceptor, "x-tenant", "acme")
+		require.NoError(t, err)
+		assert.Equal(t, acme.ID, tenantID)
+
+		tenantID, err = call(interceptor)
+		require.NoError(t, err)
+		assert.Equal(t, entity.DefaultTenantID, tenantID)
+	})
+
+	t.Run("Tokens ignore metadata", func(t *testing.T) {
+		verifier := &tenancy.TokenVerifier{Secret: testTokenSecret, Claim: "tenant"}
+		tenantID, err := call(grpcapi.TenantInterceptor(tenantService, false, verifier),
+			"x-tenant", "globex", "authorization", "Bearer "+signToken(map[string]any{"tenant": "acme"}))
+		require.NoError(t, err)
+		assert.Equal(t, acme.ID, tenantID)
+
+		_, err = call(grpcapi.TenantInterceptor(tenantService, true, verifier), "x-tenant", "acme")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
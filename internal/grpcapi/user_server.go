@@ -0,0 +1,164 @@
+// (gRPC транспорт поверх сервисного слоя)
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/audit"
+	"multilayer/internal/entity"
+	"multilayer/internal/grpcapi/userv1"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"multilayer/internal/tenancy"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+
+	// Ключ метаданных с ID запроса для журнала аудита
+	requestIDMetadataKey = "x-request-id"
+)
+
+// UserServer реализует userv1.UserServiceServer через service.UserServiceInterface
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+	userService service.UserServiceInterface
+}
+
+func NewUserServer(userService service.UserServiceInterface) *UserServer {
+	return &UserServer{userService: userService}
+}
+
+// NewServer создает gRPC сервер с UserService, health-протоколом и reflection
+func NewServer(userService service.UserServiceInterface, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.ChainUnaryInterceptor(auditMetadataInterceptor)}, opts...)
+	server := grpc.NewServer(opts...)
+	userv1.RegisterUserServiceServer(server, NewUserServer(userService))
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(userv1.UserService_ServiceDesc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
+
+func (s *UserServer) RegisterUser(ctx context.Context, req *userv1.RegisterUserRequest) (*userv1.RegisterUserResponse, error) {
+	user, err := s.userService.RegisterUser(ctx, req.GetUsername(), req.GetEmail())
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &userv1.RegisterUserResponse{User: toProtoUser(user)}, nil
+}
+
+func (s *UserServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	user, err := s.userService.GetUser(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &userv1.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+func (s *UserServer) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.UpdateUserResponse, error) {
+	user, err := s.userService.UpdateUser(ctx, uint(req.GetId()), req.GetUsername(), req.GetEmail())
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &userv1.UpdateUserResponse{User: toProtoUser(user)}, nil
+}
+
+func (s *UserServer) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size cannot be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	var afterID uint64
+	if token := req.GetPageToken(); token != "" {
+		var err error
+		afterID, err = strconv.ParseUint(token, 10, 64)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+	}
+
+	// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	users, err := s.userService.ListUsers(ctx, repository.UserFilter{}, uint(afterID), pageSize+1)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	resp := &userv1.ListUsersResponse{}
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		resp.NextPageToken = strconv.FormatUint(uint64(users[len(users)-1].ID), 10)
+	}
+	for i := range users {
+		resp.Users = append(resp.Users, toProtoUser(&users[i]))
+	}
+	return resp, nil
+}
+
+func (s *UserServer) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error) {
+	if err := s.userService.DeleteUser(ctx, uint(req.GetId())); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &userv1.DeleteUserResponse{}, nil
+}
+
+func toProtoUser(user *entity.User) *userv1.User {
+	return &userv1.User{
+		Id:       uint64(user.ID),
+		Username: user.Username,
+		Email:    user.Email,
+	}
+}
+
+// toStatusError сопоставляет доменные ошибки с кодами gRPC
+func toStatusError(err error) error {
+	var validationErr *entity.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return status.Error(codes.InvalidArgument, validationErr.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrUserAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, entity.ErrInvalidStatusTransition):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrUserInactive):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, tenancy.ErrNoTenant), errors.Is(err, service.ErrLookupTooLarge), errors.Is(err, service.ErrBatchTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// auditMetadataInterceptor переносит ID запроса из метаданных вызова в контекст,
+// откуда его читает журнал аудита. Инициатора задает AuthInterceptor по токену
+func auditMetadataInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var auditMetadata audit.Metadata
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadataKey); len(values) > 0 {
+			auditMetadata.RequestID = values[0]
+		}
+	}
+	return handler(audit.WithMetadata(ctx, auditMetadata), req)
+}
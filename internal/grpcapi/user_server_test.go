@@ -0,0 +1,176 @@
+package grpcapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/grpcapi"
+	"multilayer/internal/grpcapi/userv1"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"multilayer/internal/service/mocks"
+	"multilayer/internal/tenancy"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// setupClient поднимает gRPC сервер поверх bufconn и возвращает подключение к нему
+func setupClient(t *testing.T, userService service.UserServiceInterface) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpcapi.NewServer(userService)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestUserServer_RegisterUser(t *testing.T) {
+	mockService := new(mocks.UserService)
+	client := userv1.NewUserServiceClient(setupClient(t, mockService))
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("RegisterUser", "john_doe", "john@example.com").
+			Return(&entity.User{ID: 1, Username: "john_doe", Email: "john@example.com"}, nil)
+
+		resp, err := client.RegisterUser(context.Background(), &userv1.RegisterUserRequest{
+			Username: "john_doe",
+			Email:    "john@example.com",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), resp.GetUser().GetId())
+		assert.Equal(t, "john_doe", resp.GetUser().GetUsername())
+	})
+
+	t.Run("Validation error", func(t *testing.T) {
+		mockService.On("RegisterUser", "ab", "john@example.com").
+			Return(nil, &entity.ValidationError{Field: "username", Message: "username must be at least 3 characters long"})
+
+		_, err := client.RegisterUser(context.Background(), &userv1.RegisterUserRequest{
+			Username: "ab",
+			Email:    "john@example.com",
+		})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		mockService.On("RegisterUser", "taken", "taken@example.com").
+			Return(nil, service.ErrUserAlreadyExists)
+
+		_, err := client.RegisterUser(context.Background(), &userv1.RegisterUserRequest{
+			Username: "taken",
+			Email:    "taken@example.com",
+		})
+
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+}
+
+func TestUserServer_GetUser_NotFound(t *testing.T) {
+	mockService := new(mocks.UserService)
+	client := userv1.NewUserServiceClient(setupClient(t, mockService))
+
+	mockService.On("GetUser", uint(42)).Return(nil, service.ErrUserNotFound)
+
+	_, err := client.GetUser(context.Background(), &userv1.GetUserRequest{Id: 42})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	mockService.AssertExpectations(t)
+}
+
+func TestUserServer_ErrorCodes(t *testing.T) {
+	mockService := new(mocks.UserService)
+	client := userv1.NewUserServiceClient(setupClient(t, mockService))
+
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{fmt.Errorf("%w: suspended", service.ErrUserInactive), codes.PermissionDenied},
+		{fmt.Errorf("%w: deactivated -> locked", entity.ErrInvalidStatusTransition), codes.FailedPrecondition},
+		{tenancy.ErrNoTenant, codes.InvalidArgument},
+		{service.ErrLookupTooLarge, codes.InvalidArgument},
+		{errors.New("connection refused"), codes.Internal},
+	}
+	for i, tt := range tests {
+		id := uint(i + 1)
+		mockService.On("GetUser", id).Return(nil, tt.err)
+		_, err := client.GetUser(context.Background(), &userv1.GetUserRequest{Id: uint64(id)})
+		assert.Equal(t, tt.code, status.Code(err), tt.err.Error())
+	}
+}
+
+func TestUserServer_ListUsers_Pagination(t *testing.T) {
+	mockService := new(mocks.UserService)
+	client := userv1.NewUserServiceClient(setupClient(t, mockService))
+
+	// Сервер запрашивает page_size+1 записей, чтобы определить наличие следующей страницы
+	mockService.On("ListUsers", repository.UserFilter{}, uint(0), 3).Return([]entity.User{
+		{ID: 1, Username: "user1", Email: "user1@example.com"},
+		{ID: 2, Username: "user2", Email: "user2@example.com"},
+		{ID: 3, Username: "user3", Email: "user3@example.com"},
+	}, nil)
+	mockService.On("ListUsers", repository.UserFilter{}, uint(2), 3).Return([]entity.User{
+		{ID: 3, Username: "user3", Email: "user3@example.com"},
+	}, nil)
+
+	first, err := client.ListUsers(context.Background(), &userv1.ListUsersRequest{PageSize: 2})
+	require.NoError(t, err)
+	assert.Len(t, first.GetUsers(), 2)
+	assert.Equal(t, "2", first.GetNextPageToken())
+
+	second, err := client.ListUsers(context.Background(), &userv1.ListUsersRequest{
+		PageSize:  2,
+		PageToken: first.GetNextPageToken(),
+	})
+	require.NoError(t, err)
+	assert.Len(t, second.GetUsers(), 1)
+	assert.Empty(t, second.GetNextPageToken())
+
+	_, err = client.ListUsers(context.Background(), &userv1.ListUsersRequest{PageToken: "not-a-token"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUserServer_DeleteUser(t *testing.T) {
+	mockService := new(mocks.UserService)
+	client := userv1.NewUserServiceClient(setupClient(t, mockService))
+
+	mockService.On("DeleteUser", uint(1)).Return(nil)
+	mockService.On("DeleteUser", uint(2)).Return(service.ErrUserNotFound)
+
+	_, err := client.DeleteUser(context.Background(), &userv1.DeleteUserRequest{Id: 1})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteUser(context.Background(), &userv1.DeleteUserRequest{Id: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_HealthCheck(t *testing.T) {
+	client := grpc_health_v1.NewHealthClient(setupClient(t, new(mocks.UserService)))
+
+	resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{
+		Service: userv1.UserService_ServiceDesc.ServiceName,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+}
@@ -0,0 +1,104 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"multilayer/internal/entity"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// emailPolicySetting - ключ настройки с политикой, по которой вычислены users.email_key
+const emailPolicySetting = "email_canonicalization"
+
+// Setting - параметр, от которого зависят сохраненные данные
+type Setting struct {
+	Key   string `gorm:"primaryKey;size:255"`
+	Value string
+}
+
+// ErrEmailPolicyChanged - политика развертывания отличается от примененной к базе
+var ErrEmailPolicyChanged = errors.New("email canonicalization policy differs from the one applied to the database")
+
+// ApplyEmailPolicy сверяет политику сравнения email с той, по которой вычислены
+// сохраненные users.email_key. При первом запуске ключи пересчитываются и
+// политика запоминается. Смена политики на живой базе без rekey возвращает
+// ErrEmailPolicyChanged: иначе поиск и проверка уникальности молча перестанут
+// находить существующие адреса. С rekey ключи пересчитываются в одной транзакции;
+// если по новой политике совпадают адреса разных пользователей тенанта, ничего
+// не меняется и возвращается список конфликтов
+func ApplyEmailPolicy(db *gorm.DB, policy *entity.EmailCanonicalization, rekey bool) error {
+	if err := db.AutoMigrate(&Setting{}); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var applied Setting
+		err := tx.Where("key = ?", emailPolicySetting).Take(&applied).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case err != nil:
+			return err
+		case applied.Value == policy.String():
+			return nil
+		case !rekey:
+			return fmt.Errorf("%w: applied %q, configured %q", ErrEmailPolicyChanged, applied.Value, policy.String())
+		}
+
+		if err := rekeyEmails(tx, policy); err != nil {
+			return err
+		}
+		return tx.Save(&Setting{Key: emailPolicySetting, Value: policy.String()}).Error
+	})
+}
+
+// rekeyEmails пересчитывает users.email_key по policy
+func rekeyEmails(tx *gorm.DB, policy *entity.EmailCanonicalization) error {
+	type rekeyed struct {
+		id  uint
+		key string
+	}
+	var (
+		changed   []rekeyed
+		conflicts []string
+		owners    = map[string]uint{}
+		users     []entity.User
+	)
+	// Сначала только читаем: конфликты нужно собрать до первой записи
+	err := tx.Select("id", "tenant_id", "email", "email_key").FindInBatches(&users, 500, func(*gorm.DB, int) error {
+		for _, user := range users {
+			key := entity.CanonicalEmail(user.Email, policy)
+			owner := fmt.Sprintf("%d/%s", user.TenantID, key)
+			if id, ok := owners[owner]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("users %d and %d (%q)", id, user.ID, key))
+			}
+			owners[owner] = user.ID
+			if key != user.EmailKey {
+				changed = append(changed, rekeyed{user.ID, key})
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("email key conflicts under the new policy, resolve them before rekeying: %s", strings.Join(conflicts, "; "))
+	}
+
+	// Два прохода: новый ключ одной строки может совпадать со старым ключом
+	// другой, а уникальный индекс проверяется после каждого UPDATE
+	for _, row := range changed {
+		err := tx.Model(&entity.User{}).Where("id = ?", row.id).UpdateColumn("email_key", fmt.Sprintf("#rekey:%d", row.id)).Error
+		if err != nil {
+			return err
+		}
+	}
+	for _, row := range changed {
+		if err := tx.Model(&entity.User{}).Where("id = ?", row.id).UpdateColumn("email_key", row.key).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// (Версионные миграции схемы и данных)
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration - шаг изменения схемы или данных, применяемый ровно один раз
+type Migration struct {
+	ID      string // уникальный и сортируемый идентификатор, например "20261018_user_profile"
+	Migrate func(tx *gorm.DB) error
+}
+
+// SchemaMigration - запись о примененной миграции
+type SchemaMigration struct {
+	ID        string `gorm:"primaryKey;size:255"`
+	AppliedAt time.Time
+}
+
+// All - миграции в порядке применения; новые добавляются в конец
+var All = []Migration{
+	userProfileMigration,
+	userCanonicalKeysMigration,
+	tenantsMigration,
+	// 20261021_row_level_security заменена ApplyRowLevelSecurity, которая
+	// применяется при каждом запуске по DB_ROW_LEVEL_SECURITY
+	userSearchMigration,
+	userStatusMigration,
+	userVersionsBackfillMigration,
+}
+
+// Run применяет непримененные миграции, каждую в своей транзакции.
+// Вызывается после AutoMigrate, поэтому таблицы моделей уже существуют
+func Run(db *gorm.DB, migrations []Migration) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		var count int64
+		if err := db.Model(&SchemaMigration{}).Where("id = ?", migration.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Migrate(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{ID: migration.ID, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package migrations_test
+
+import (
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/migrations"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	return db
+}
+
+func TestRun_BackfillsUserProfile(t *testing.T) {
+	db := setupTestDB(t)
+	// Схема до добавления профиля
+	require.NoError(t, db.Exec("CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, username text UNIQUE, email text UNIQUE)").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (username, email) VALUES ('legacy', 'legacy@example.com')").Error)
+
+	require.NoError(t, migrations.Run(db, migrations.All))
+
+	var user entity.User
+	require.NoError(t, db.First(&user, "username = ?", "legacy").Error)
+	assert.Equal(t, entity.DefaultLocale, user.Locale)
+	assert.Equal(t, entity.DefaultTimezone, user.Timezone)
+	assert.False(t, user.CreatedAt.IsZero())
+	assert.Equal(t, user.CreatedAt, user.UpdatedAt)
+}
+
+func TestRun_AppliesEachMigrationOnce(t *testing.T) {
+	db := setupTestDB(t)
+	calls := 0
+	steps := []migrations.Migration{{ID: "test_step", Migrate: func(tx *gorm.DB) error {
+		calls++
+		return nil
+	}}}
+
+	require.NoError(t, migrations.Run(db, steps))
+	require.NoError(t, migrations.Run(db, steps))
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestRun_FailedMigrationIsRetried(t *testing.T) {
+	db := setupTestDB(t)
+	failing := []migrations.Migration{{ID: "test_step", Migrate: func(tx *gorm.DB) error {
+		return fmt.Errorf("boom")
+	}}}
+
+	assert.Error(t, migrations.Run(db, failing))
+
+	var count int64
+	db.Model(&migrations.SchemaMigration{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestRun_BackfillsCanonicalKeys(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Exec("CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, username text UNIQUE, email text UNIQUE)").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (username, email) VALUES ('Legacy', 'Legacy@Example.com')").Error)
+
+	require.NoError(t, migrations.Run(db, migrations.All))
+
+	var user entity.User
+	require.NoError(t, db.First(&user).Error)
+	assert.Equal(t, "legacy", user.UsernameKey)
+	assert.Equal(t, "legacy@example.com", user.EmailKey)
+
+	err := db.Create(&entity.User{TenantID: user.TenantID, Username: "LEGACY", Email: "other@example.com"}).Error
+	assert.Error(t, err)
+}
+
+func TestRun_CanonicalKeyConflicts(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Exec("CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, username text UNIQUE, email text UNIQUE)").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (username, email) VALUES ('alice', 'a1@example.com'), ('Alice', 'a2@example.com')").Error)
+
+	err := migrations.Run(db, migrations.All)
+
+	assert.ErrorContains(t, err, "username of users 1 and 2")
+}
+
+func TestRun_MovesExistingUsersToDefaultTenant(t *testing.T) {
+	db := setupTestDB(t)
+	// Схема с глобальной уникальностью до появления тенантов
+	require.NoError(t, db.Exec("CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, username text, email text, CONSTRAINT uni_users_username UNIQUE (username), CONSTRAINT uni_users_email UNIQUE (email))").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (username, email) VALUES ('alice', 'alice@example.com')").Error)
+
+	require.NoError(t, migrations.Run(db, migrations.All))
+
+	var tenant entity.Tenant
+	require.NoError(t, db.First(&tenant, entity.DefaultTenantID).Error)
+	assert.Equal(t, entity.DefaultTenantSlug, tenant.Slug)
+
+	var user entity.User
+	require.NoError(t, db.First(&user).Error)
+	assert.Equal(t, entity.DefaultTenantID, user.TenantID)
+
+	// Те же username и email свободны в другом тенанте, но заняты в своем
+	assert.NoError(t, db.Create(&entity.User{TenantID: 2, Username: "alice", Email: "alice@example.com"}).Error)
+	assert.Error(t, db.Create(&entity.User{TenantID: entity.DefaultTenantID, Username: "Alice", Email: "other@example.com"}).Error)
+}
+
+func TestRun_BackfillsUserStatus(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Exec("CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, username text UNIQUE, email text UNIQUE)").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (username, email) VALUES ('legacy', 'legacy@example.com')").Error)
+
+	require.NoError(t, migrations.Run(db, migrations.All))
+
+	var user entity.User
+	require.NoError(t, db.First(&user).Error)
+	assert.Equal(t, entity.UserActive, user.Status)
+	assert.Nil(t, user.StatusChangedAt)
+	assert.True(t, db.Migrator().HasIndex(&entity.User{}, "Status"))
+}
+
+func TestRun_BackfillsUserVersions(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Exec("CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, username text UNIQUE, email text UNIQUE)").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (username, email) VALUES ('legacy', 'legacy@example.com'), ('other', 'other@example.com')").Error)
+
+	require.NoError(t, migrations.Run(db, migrations.All))
+
+	var versions []entity.UserVersion
+	require.NoError(t, db.Order("user_id").Find(&versions).Error)
+	require.Len(t, versions, 2)
+	var user entity.User
+	require.NoError(t, db.First(&user, "username = ?", "legacy").Error)
+	assert.Equal(t, user.ID, versions[0].UserID)
+	assert.Equal(t, "legacy", versions[0].Data.Username)
+	assert.Equal(t, user.CreatedAt.Unix(), versions[0].ValidFrom.Unix())
+	assert.Nil(t, versions[0].ValidTo)
+}
+
+func TestApplyEmailPolicy(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&entity.User{}))
+	require.NoError(t, db.Create(&entity.User{TenantID: 1, Username: "bob", Email: "bob+news@example.com"}).Error)
+	require.NoError(t, db.Create(&entity.User{TenantID: 1, Username: "alice", Email: "alice@example.com"}).Error)
+	emailKey := func(username string) string {
+		var user entity.User
+		require.NoError(t, db.First(&user, "username = ?", username).Error)
+		return user.EmailKey
+	}
+
+	// Первый запуск запоминает политику по умолчанию
+	require.NoError(t, migrations.ApplyEmailPolicy(db, nil, false))
+	assert.Equal(t, "bob+news@example.com", emailKey("bob"))
+
+	// Смена политики без пересчета ключей отклоняется
+	ignorePlus := &entity.EmailCanonicalization{IgnorePlusTag: true}
+	assert.ErrorIs(t, migrations.ApplyEmailPolicy(db, ignorePlus, false), migrations.ErrEmailPolicyChanged)
+	assert.Equal(t, "bob+news@example.com", emailKey("bob"))
+
+	require.NoError(t, migrations.ApplyEmailPolicy(db, ignorePlus, true))
+	assert.Equal(t, "bob@example.com", emailKey("bob"))
+	require.NoError(t, migrations.ApplyEmailPolicy(db, ignorePlus, false))
+
+	// Конфликт по новой политике оставляет ключи и политику прежними
+	require.NoError(t, db.Create(&entity.User{TenantID: 1, Username: "bob2", Email: "B.o.b@example.com", EmailKey: "b.o.b@example.com"}).Error)
+	ignoreDots := &entity.EmailCanonicalization{IgnorePlusTag: true, IgnoreDotsDomains: []string{"example.com"}}
+	assert.ErrorContains(t, migrations.ApplyEmailPolicy(db, ignoreDots, true), "conflicts")
+	assert.Equal(t, "b.o.b@example.com", emailKey("bob2"))
+	require.NoError(t, migrations.ApplyEmailPolicy(db, ignorePlus, false))
+}
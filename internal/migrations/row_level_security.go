@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"fmt"
+	"multilayer/internal/tenancy"
+
+	"gorm.io/gorm"
+)
+
+// rowLevelSecurityTables - таблицы с колонкой tenant_id, которые репозитории
+// читают и изменяют в контексте тенанта
+var rowLevelSecurityTables = []string{
+	"users", "user_versions", "user_audit_entries",
+	"organizations", "teams", "organization_members", "team_members",
+	"webhook_subscriptions", "webhook_deliveries",
+	"outbox_messages", "idempotency_records",
+}
+
+// ApplyRowLevelSecurity включает или выключает в Postgres политики row-level
+// security (DB_ROW_LEVEL_SECURITY). С политиками роль видит и изменяет только
+// строки тенанта из переменной tenancy.SessionSetting, строки всех тенантов -
+// с tenancy.AllTenantsSetting = on (фоновые задачи), а без переменных не видит
+// ничего. Владелец таблиц и суперпользователи политикам не подчиняются, поэтому
+// в режиме RLS приложение подключается отдельной ролью, а вызывается функция
+// владельцем. Выполняется при каждом запуске: выключение режима снимает
+// политики, которые иначе оставили бы приложение без данных. В остальных СУБД
+// ничего не делает
+func ApplyRowLevelSecurity(db *gorm.DB, enabled bool) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	// current_setting(..., true) дает NULL для незаданной переменной и пустую
+	// строку после завершения транзакции, в которой она была задана
+	condition := fmt.Sprintf("tenant_id = NULLIF(current_setting('%s', true), '')::bigint OR current_setting('%s', true) = 'on'",
+		tenancy.SessionSetting, tenancy.AllTenantsSetting)
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range rowLevelSecurityTables {
+			if !tx.Migrator().HasTable(table) {
+				continue
+			}
+			statements := []string{
+				fmt.Sprintf("ALTER TABLE %s DISABLE ROW LEVEL SECURITY", table),
+				fmt.Sprintf("DROP POLICY IF EXISTS tenant_isolation ON %s", table),
+			}
+			if enabled {
+				statements = []string{
+					fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", table),
+					fmt.Sprintf("DROP POLICY IF EXISTS tenant_isolation ON %s", table),
+					fmt.Sprintf("CREATE POLICY tenant_isolation ON %s USING (%s) WITH CHECK (%s)", table, condition, condition),
+				}
+			}
+			if err := execAll(tx, statements...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
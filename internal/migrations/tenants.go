@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"multilayer/internal/entity"
+	"multilayer/internal/outbox"
+
+	"gorm.io/gorm"
+)
+
+// tenantsMigration вводит тенантов: создает тенанта по умолчанию, относит к
+// нему все существующие данные и заменяет глобальную уникальность username и
+// email уникальностью в пределах тенанта
+var tenantsMigration = Migration{
+	ID: "20261020_tenants",
+	Migrate: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		if !migrator.HasTable(&entity.Tenant{}) {
+			if err := migrator.CreateTable(&entity.Tenant{}); err != nil {
+				return err
+			}
+		}
+		defaultTenant := entity.Tenant{ID: entity.DefaultTenantID, Slug: entity.DefaultTenantSlug, Name: "Default"}
+		if err := tx.Where("id = ?", defaultTenant.ID).FirstOrCreate(&defaultTenant).Error; err != nil {
+			return err
+		}
+		if tx.Dialector.Name() == "postgres" {
+			// ID вставлен явно, последовательность нужно сдвинуть вручную
+			err := tx.Exec("SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT MAX(id) FROM tenants))").Error
+			if err != nil {
+				return err
+			}
+		}
+
+		models := []any{&entity.User{}, &entity.UserVersion{}, &entity.UserAuditEntry{}, &outbox.Message{}, &entity.WebhookSubscription{}, &entity.WebhookDelivery{}}
+		for _, model := range models {
+			if !migrator.HasTable(model) {
+				continue
+			}
+			if !migrator.HasColumn(model, "TenantID") {
+				if err := migrator.AddColumn(model, "TenantID"); err != nil {
+					return err
+				}
+			}
+			err := tx.Model(model).Where("tenant_id IS NULL OR tenant_id = 0").UpdateColumn("tenant_id", entity.DefaultTenantID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// Глобальные ограничения уникальности прежней схемы
+		for _, name := range []string{"uni_users_username", "uni_users_email"} {
+			if migrator.HasConstraint(&entity.User{}, name) {
+				if err := migrator.DropConstraint(&entity.User{}, name); err != nil {
+					return err
+				}
+			}
+		}
+		for _, name := range []string{"idx_users_username_key", "idx_users_email_key"} {
+			if migrator.HasIndex(&entity.User{}, name) {
+				if err := migrator.DropIndex(&entity.User{}, name); err != nil {
+					return err
+				}
+			}
+		}
+		for _, name := range []string{"idx_users_tenant_username_key", "idx_users_tenant_email_key"} {
+			if !migrator.HasIndex(&entity.User{}, name) {
+				if err := migrator.CreateIndex(&entity.User{}, name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
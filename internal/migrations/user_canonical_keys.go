@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"fmt"
+	"multilayer/internal/entity"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// userCanonicalKeysMigration заполняет users.username_key и users.email_key и
+// включает уникальные индексы по ним. Если существующие записи совпадают по
+// каноническому ключу, миграция прерывается со списком конфликтов: такие
+// аккаунты нужно объединить или переименовать вручную. Ключи email вычисляются
+// по политике по умолчанию; политику развертывания применяет ApplyEmailPolicy
+var userCanonicalKeysMigration = Migration{
+	ID: "20261019_user_canonical_keys",
+	Migrate: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, field := range []string{"UsernameKey", "EmailKey"} {
+			if migrator.HasColumn(&entity.User{}, field) {
+				continue
+			}
+			if err := migrator.AddColumn(&entity.User{}, field); err != nil {
+				return err
+			}
+		}
+
+		type canonicalKeys struct {
+			id                    uint
+			usernameKey, emailKey string
+		}
+		var (
+			keys           []canonicalKeys
+			conflicts      []string
+			usernameOwners = map[string]uint{}
+			emailOwners    = map[string]uint{}
+			users          []entity.User
+		)
+		// Сначала только читаем: конфликты нужно собрать до первой записи
+		err := tx.Select("id", "username", "email").FindInBatches(&users, 500, func(*gorm.DB, int) error {
+			for _, user := range users {
+				key := canonicalKeys{user.ID, entity.CanonicalUsername(user.Username), entity.CanonicalEmail(user.Email, nil)}
+				if owner, ok := usernameOwners[key.usernameKey]; ok {
+					conflicts = append(conflicts, fmt.Sprintf("username of users %d and %d (%q)", owner, user.ID, key.usernameKey))
+				}
+				if owner, ok := emailOwners[key.emailKey]; ok {
+					conflicts = append(conflicts, fmt.Sprintf("email of users %d and %d (%q)", owner, user.ID, key.emailKey))
+				}
+				usernameOwners[key.usernameKey] = user.ID
+				emailOwners[key.emailKey] = user.ID
+				keys = append(keys, key)
+			}
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("canonical key conflicts, resolve them before migrating: %s", strings.Join(conflicts, "; "))
+		}
+
+		for _, key := range keys {
+			err := tx.Model(&entity.User{}).Where("id = ?", key.id).UpdateColumns(map[string]any{
+				"username_key": key.usernameKey,
+				"email_key":    key.emailKey,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// Индексы в том виде, в каком они были до тенантов; позже их заменяет
+		// tenantsMigration, поэтому теги модели здесь не используются
+		for _, column := range []string{"username_key", "email_key"} {
+			err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_%s ON users (%s)", column, column)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"multilayer/internal/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// userProfileMigration добавляет пользователю метки времени и поля профиля и
+// заполняет их у существующих записей. Дата создания берется из первой версии
+// пользователя, если история уже ведется, иначе - момент миграции
+var userProfileMigration = Migration{
+	ID: "20261018_user_profile",
+	Migrate: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, field := range []string{"DisplayName", "Locale", "Timezone", "AvatarURL", "CreatedAt", "UpdatedAt"} {
+			if migrator.HasColumn(&entity.User{}, field) {
+				continue
+			}
+			if err := migrator.AddColumn(&entity.User{}, field); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC()
+		createdAt := gorm.Expr("?", now)
+		if migrator.HasTable(&entity.UserVersion{}) {
+			createdAt = gorm.Expr("COALESCE((SELECT MIN(valid_from) FROM user_versions WHERE user_versions.user_id = users.id), ?)", now)
+		}
+
+		backfills := []struct {
+			where  string
+			column string
+			value  any
+		}{
+			{"created_at IS NULL", "created_at", createdAt},
+			{"updated_at IS NULL", "updated_at", gorm.Expr("created_at")},
+			{"locale IS NULL OR locale = ''", "locale", entity.DefaultLocale},
+			{"timezone IS NULL OR timezone = ''", "timezone", entity.DefaultTimezone},
+			{"display_name IS NULL", "display_name", ""},
+			{"avatar_url IS NULL", "avatar_url", ""},
+		}
+		for _, backfill := range backfills {
+			err := tx.Model(&entity.User{}).Where(backfill.where).UpdateColumn(backfill.column, backfill.value).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
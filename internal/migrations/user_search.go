@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"fmt"
+	"multilayer/internal/repository"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// userSearchMigration строит индексы поиска пользователей (repository.UserRepository.Search).
+// В Postgres - триграммный индекс pg_trgm и tsvector по repository.UserSearchDocument.
+// В SQLite - таблица FTS5 с триггерами синхронизации, если драйвер собран с FTS5
+// (тег sqlite_fts5); иначе поиск работает перебором и миграция ничего не делает
+var userSearchMigration = Migration{
+	ID: "20261022_user_search",
+	Migrate: func(tx *gorm.DB) error {
+		switch tx.Dialector.Name() {
+		case "postgres":
+			return execAll(tx,
+				"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_users_search_trgm ON users USING gin ((%s) gin_trgm_ops)", repository.UserSearchDocument),
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_users_search_tsv ON users USING gin (to_tsvector('simple', %s))", repository.UserSearchDocument),
+			)
+		case "sqlite":
+			return createSQLiteUserSearch(tx)
+		}
+		return nil
+	},
+}
+
+// createSQLiteUserSearch создает индекс FTS5 над таблицей users. Триггеры
+// привязаны к таблице: если AutoMigrate пересоздаст users (в SQLite так
+// меняются ограничения колонок), миграция, вызвавшая это, должна вызвать
+// createSQLiteUserSearch повторно
+func createSQLiteUserSearch(tx *gorm.DB) error {
+	table := repository.UserSearchFTSTable
+	err := tx.Exec(fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(username, display_name, email, content='users', content_rowid='id', tokenize='trigram')", table)).Error
+	if err != nil && strings.Contains(err.Error(), "no such module") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	columns := "username, display_name, email"
+	values := func(row string) string {
+		return fmt.Sprintf("%[1]s.username, %[1]s.display_name, %[1]s.email", row)
+	}
+	return execAll(tx,
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %[1]s_insert AFTER INSERT ON users BEGIN INSERT INTO %[1]s(rowid, %[2]s) VALUES (new.id, %[3]s); END",
+			table, columns, values("new")),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %[1]s_delete AFTER DELETE ON users BEGIN INSERT INTO %[1]s(%[1]s, rowid, %[2]s) VALUES ('delete', old.id, %[3]s); END",
+			table, columns, values("old")),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %[1]s_update AFTER UPDATE ON users BEGIN INSERT INTO %[1]s(%[1]s, rowid, %[2]s) VALUES ('delete', old.id, %[3]s); INSERT INTO %[1]s(rowid, %[2]s) VALUES (new.id, %[4]s); END",
+			table, columns, values("old"), values("new")),
+		fmt.Sprintf("INSERT INTO %[1]s(%[1]s) VALUES ('rebuild')", table),
+	)
+}
+
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"multilayer/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+// userStatusMigration добавляет пользователю статус жизненного цикла; все
+// существующие пользователи становятся активными
+var userStatusMigration = Migration{
+	ID: "20261018_user_status",
+	Migrate: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, field := range []string{"Status", "StatusReason", "StatusChangedAt"} {
+			if migrator.HasColumn(&entity.User{}, field) {
+				continue
+			}
+			if err := migrator.AddColumn(&entity.User{}, field); err != nil {
+				return err
+			}
+		}
+		if !migrator.HasIndex(&entity.User{}, "Status") {
+			if err := migrator.CreateIndex(&entity.User{}, "Status"); err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&entity.User{}).Where("status IS NULL OR status = ''").UpdateColumn("status", entity.UserActive).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&entity.User{}).Where("status_reason IS NULL").UpdateColumn("status_reason", "").Error
+	},
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"multilayer/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+// userVersionsBackfillMigration открывает версию для каждого пользователя, у
+// которого истории еще нет (созданных до появления user_versions), - иначе
+// запрос состояния на момент времени для них возвращает 404. Версия действует
+// с даты создания пользователя
+var userVersionsBackfillMigration = Migration{
+	ID: "20261018_user_versions_backfill",
+	Migrate: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&entity.UserVersion{}); err != nil {
+			return err
+		}
+
+		var users []entity.User
+		return tx.Where("NOT EXISTS (SELECT 1 FROM user_versions WHERE user_versions.user_id = users.id)").
+			FindInBatches(&users, 500, func(_ *gorm.DB, _ int) error {
+				versions := make([]entity.UserVersion, len(users))
+				for i, user := range users {
+					versions[i] = entity.UserVersion{
+						TenantID:  user.TenantID,
+						UserID:    user.ID,
+						Data:      user,
+						ValidFrom: user.CreatedAt,
+					}
+				}
+				return tx.Create(&versions).Error
+			}).Error
+	},
+}
@@ -0,0 +1,79 @@
+// (Транзакционный outbox доменных событий)
+package outbox
+
+import (
+	"encoding/json"
+	"multilayer/internal/entity"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Message - событие, сохраненное в одной транзакции с изменением пользователя
+// и ожидающее публикации релеем
+type Message struct {
+	ID            uint   `gorm:"primaryKey"`
+	EventID       string `gorm:"uniqueIndex;size:36"`
+	EventType     string `gorm:"index"`
+	TenantID      uint   `gorm:"index"`
+	AggregateID   uint   `gorm:"index"`
+	Payload       []byte
+	CreatedAt     time.Time
+	Attempts      int
+	NextAttemptAt time.Time  `gorm:"index"`
+	PublishedAt   *time.Time `gorm:"index"`
+	LastError     string
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Envelope - формат, в котором события уходят во внешние системы. ID события
+// стабилен между повторными отправками, что позволяет получателю отсеивать дубли
+type Envelope struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	TenantID    uint            `json:"tenant_id"`
+	AggregateID uint            `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// Envelope возвращает сообщение в формате для публикации
+func (m *Message) Envelope() Envelope {
+	return Envelope{
+		ID:          m.EventID,
+		Type:        m.EventType,
+		TenantID:    m.TenantID,
+		AggregateID: m.AggregateID,
+		OccurredAt:  m.CreatedAt,
+		Data:        m.Payload,
+	}
+}
+
+// Store записывает события в таблицу outbox
+type Store struct{}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Record сохраняет событие через переданную транзакцию, поэтому оно
+// фиксируется или откатывается вместе с изменением пользователя
+func (s *Store) Record(tx *gorm.DB, event entity.UserEvent) error {
+	payload, err := json.Marshal(event.User)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&Message{
+		EventID:       uuid.NewString(),
+		EventType:     string(event.Type),
+		TenantID:      event.User.TenantID,
+		AggregateID:   event.User.ID,
+		Payload:       payload,
+		CreatedAt:     event.OccurredAt,
+		NextAttemptAt: event.OccurredAt,
+	}).Error
+}
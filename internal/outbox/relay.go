@@ -0,0 +1,166 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"multilayer/internal/tenancy"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RelayConfig задает параметры фонового релея; нулевые значения заменяются значениями по умолчанию
+type RelayConfig struct {
+	PollInterval time.Duration // пауза между опросами таблицы, по умолчанию 1s
+	BatchSize    int           // сообщений за один опрос, по умолчанию 100
+	BaseBackoff  time.Duration // задержка после первой неудачи, по умолчанию 1s
+	MaxBackoff   time.Duration // верхняя граница задержки, по умолчанию 5m
+	// ClaimTimeout - на сколько забранные сообщения скрываются от других
+	// релеев, пока публикуются; по умолчанию 1m
+	ClaimTimeout time.Duration
+	// RowLevelSecurity - на outbox_messages действуют политики RLS: релей
+	// обрабатывает сообщения всех тенантов и открывает их tenancy.AllTenantsSetting
+	RowLevelSecurity bool
+}
+
+// Relay публикует неотправленные сообщения outbox в Sink с доставкой
+// at-least-once: сообщение помечается отправленным только после успешной
+// публикации, а при ошибке повторяется с экспоненциальной задержкой
+type Relay struct {
+	db     *gorm.DB
+	sink   Sink
+	config RelayConfig
+	now    func() time.Time
+}
+
+func NewRelay(db *gorm.DB, sink Sink, config RelayConfig) *Relay {
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Minute
+	}
+	if config.ClaimTimeout <= 0 {
+		config.ClaimTimeout = time.Minute
+	}
+	return &Relay{db: db, sink: sink, config: config, now: time.Now}
+}
+
+// Run опрашивает outbox до отмены ctx
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Пока есть полные пачки, обрабатываем их без паузы
+		for {
+			processed, err := r.ProcessBatch(ctx)
+			if err != nil {
+				log.Printf("outbox relay: %v", err)
+			}
+			if err != nil || processed < r.config.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch публикует одну пачку готовых к отправке сообщений и возвращает их количество.
+// Публикация идет вне транзакции: строки только помечаются забранными (см. claim)
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	messages, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i := range messages {
+		if err := r.deliver(ctx, &messages[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(messages), nil
+}
+
+// claim отбирает готовые сообщения и в той же короткой транзакции переносит их
+// следующую попытку на ClaimTimeout вперед, так что другие релеи их не берут.
+// Если релей остановится, не отметив результат, сообщения снова станут готовыми
+// по истечении ClaimTimeout
+func (r *Relay) claim(ctx context.Context) ([]Message, error) {
+	var messages []Message
+	err := r.transaction(ctx, func(tx *gorm.DB) error {
+		now := r.now()
+		query := tx.Where("published_at IS NULL AND next_attempt_at <= ?", now).
+			Order("id").
+			Limit(r.config.BatchSize)
+		// Несколько экземпляров сервиса на Postgres не должны забирать одни и те же строки
+		if tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+		}
+		return tx.Model(&Message{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(r.config.ClaimTimeout)).Error
+	})
+	return messages, err
+}
+
+func (r *Relay) deliver(ctx context.Context, message *Message) error {
+	publishErr := r.sink.Publish(ctx, message.Envelope())
+	now := r.now()
+	// Результат публикации фиксируется и при остановке релея, иначе сообщение уйдет повторно
+	return r.transaction(context.WithoutCancel(ctx), func(tx *gorm.DB) error {
+		if publishErr == nil {
+			return tx.Model(message).Updates(map[string]interface{}{
+				"published_at": now,
+				"last_error":   "",
+			}).Error
+		}
+
+		attempts := message.Attempts + 1
+		return tx.Model(message).Updates(map[string]interface{}{
+			"attempts":        attempts,
+			"next_attempt_at": now.Add(r.backoff(attempts)),
+			"last_error":      publishErr.Error(),
+		}).Error
+	})
+}
+
+// transaction выполняет fn в транзакции, которой при RowLevelSecurity открыты
+// сообщения всех тенантов
+func (r *Relay) transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if r.config.RowLevelSecurity {
+			if err := tx.Exec("SELECT set_config(?, 'on', true)", tenancy.AllTenantsSetting).Error; err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// backoff возвращает задержку перед попыткой attempts+1: BaseBackoff * 2^(attempts-1), не больше MaxBackoff
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.BaseBackoff
+	for i := 1; i < attempts && delay < r.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.config.MaxBackoff)
+}
@@ -0,0 +1,142 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/outbox"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	// Отдельная in-memory база на каждый запуск теста
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&outbox.Message{}))
+	return db
+}
+
+// flakySink отклоняет первые failures публикаций, затем принимает все
+type flakySink struct {
+	mu        sync.Mutex
+	failures  int
+	published []outbox.Envelope
+}
+
+func (s *flakySink) Publish(_ context.Context, envelope outbox.Envelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	s.published = append(s.published, envelope)
+	return nil
+}
+
+func recordEvent(t *testing.T, db *gorm.DB, eventType entity.UserEventType, user entity.User) {
+	require.NoError(t, outbox.NewStore().Record(db, entity.NewUserEvent(eventType, &user)))
+}
+
+func TestRelay_PublishesPendingMessages(t *testing.T) {
+	db := setupTestDB(t)
+	sink := &flakySink{}
+	relay := outbox.NewRelay(db, sink, outbox.RelayConfig{})
+
+	recordEvent(t, db, entity.UserRegistered, entity.User{ID: 1, Username: "alice", Email: "alice@example.com"})
+	recordEvent(t, db, entity.UserUpdated, entity.User{ID: 1, Username: "alice2", Email: "alice@example.com"})
+
+	processed, err := relay.ProcessBatch(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, processed)
+	require.Len(t, sink.published, 2)
+	assert.Equal(t, "user.registered", sink.published[0].Type)
+	assert.Equal(t, uint(1), sink.published[0].AggregateID)
+	var published entity.User
+	require.NoError(t, json.Unmarshal(sink.published[1].Data, &published))
+	assert.Equal(t, "alice2", published.Username)
+	assert.Equal(t, "alice@example.com", published.Email)
+
+	// Опубликованные сообщения повторно не отправляются
+	processed, err = relay.ProcessBatch(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, processed)
+}
+
+// reentrantSink во время публикации запускает второй релей на той же базе
+type reentrantSink struct {
+	other     *outbox.Relay
+	processed []int
+	errs      []error
+}
+
+func (s *reentrantSink) Publish(ctx context.Context, _ outbox.Envelope) error {
+	processed, err := s.other.ProcessBatch(ctx)
+	s.processed = append(s.processed, processed)
+	s.errs = append(s.errs, err)
+	return nil
+}
+
+func TestRelay_PublishesOutsideClaimTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	recordEvent(t, db, entity.UserRegistered, entity.User{ID: 1, Username: "alice", Email: "alice@example.com"})
+
+	sink := &reentrantSink{other: outbox.NewRelay(db, &flakySink{}, outbox.RelayConfig{})}
+	relay := outbox.NewRelay(db, sink, outbox.RelayConfig{})
+
+	processed, err := relay.ProcessBatch(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	// Второй релей получает доступ к базе, но забранное сообщение не видит
+	require.Len(t, sink.processed, 1)
+	assert.NoError(t, sink.errs[0])
+	assert.Zero(t, sink.processed[0])
+
+	var message outbox.Message
+	require.NoError(t, db.First(&message).Error)
+	assert.NotNil(t, message.PublishedAt)
+}
+
+func TestRelay_RetriesWithBackoff(t *testing.T) {
+	db := setupTestDB(t)
+	sink := &flakySink{failures: 1}
+	relay := outbox.NewRelay(db, sink, outbox.RelayConfig{BaseBackoff: 50 * time.Millisecond})
+
+	recordEvent(t, db, entity.UserDeleted, entity.User{ID: 7, Username: "bob", Email: "bob@example.com"})
+
+	_, err := relay.ProcessBatch(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, sink.published)
+
+	var message outbox.Message
+	require.NoError(t, db.First(&message).Error)
+	assert.Equal(t, 1, message.Attempts)
+	assert.Equal(t, "sink unavailable", message.LastError)
+	assert.Nil(t, message.PublishedAt)
+
+	// До истечения задержки сообщение не берется повторно
+	processed, err := relay.ProcessBatch(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, processed)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = relay.ProcessBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, sink.published, 1)
+	assert.Equal(t, message.EventID, sink.published[0].ID)
+
+	require.NoError(t, db.First(&message).Error)
+	assert.NotNil(t, message.PublishedAt)
+}
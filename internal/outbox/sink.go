@@ -0,0 +1,198 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// Sink доставляет событие во внешнюю систему. Ошибка означает, что
+// доставка не подтверждена и сообщение будет отправлено повторно
+type Sink interface {
+	Publish(ctx context.Context, envelope Envelope) error
+}
+
+// MultiSink передает событие всем получателям по очереди; ошибка любого из
+// них возвращает сообщение в очередь, поэтому получатели должны быть идемпотентны
+type MultiSink []Sink
+
+func (s MultiSink) Publish(ctx context.Context, envelope Envelope) error {
+	for _, sink := range s {
+		if err := sink.Publish(ctx, envelope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close закрывает получателей, которые держат соединения (io.Closer)
+func (s MultiSink) Close() error {
+	var errs []error
+	for _, sink := range s {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogSink пишет события в стандартный лог; подходит для разработки
+type LogSink struct {
+	Logger *log.Logger
+}
+
+func NewLogSink() *LogSink {
+	return &LogSink{Logger: log.Default()}
+}
+
+func (s *LogSink) Publish(_ context.Context, envelope Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	s.Logger.Printf("outbox event: %s", data)
+	return nil
+}
+
+// FileSink дописывает события в файл в формате NDJSON
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Publish(_ context.Context, envelope Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WebhookSink отправляет события POST-запросом; успехом считается ответ 2xx
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, envelope Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", envelope.ID)
+	req.Header.Set("X-Event-Type", envelope.Type)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NATSSink публикует события в JetStream; ID события передается как
+// Nats-Msg-Id, чтобы сервер отбрасывал повторы в окне дедупликации
+type NATSSink struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject string
+}
+
+// NewNATSSink создает получателя поверх conn; Close закрывает соединение
+func NewNATSSink(conn *nats.Conn, subject string) (*NATSSink, error) {
+	js, err := conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	return &NATSSink{conn: conn, js: js, subject: subject}, nil
+}
+
+func (s *NATSSink) Publish(ctx context.Context, envelope Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	_, err = s.js.Publish(s.subject+"."+envelope.Type, data, nats.MsgId(envelope.ID), nats.Context(ctx))
+	return err
+}
+
+// KafkaSink публикует события в топик Kafka с подтверждением от всех реплик;
+// ключ сообщения - ID пользователя, чтобы его события попадали в одну партицию
+type KafkaSink struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaSink(brokers []string, topic string) *KafkaSink {
+	return &KafkaSink{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}
+}
+
+func (s *KafkaSink) Publish(ctx context.Context, envelope Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.FormatUint(uint64(envelope.AggregateID), 10)),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "event-id", Value: []byte(envelope.ID)},
+			{Key: "event-type", Value: []byte(envelope.Type)},
+		},
+	})
+}
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
+
+// Close дожидается отправки буферизованных сообщений и закрывает соединение
+func (s *NATSSink) Close() error {
+	return s.conn.Drain()
+}
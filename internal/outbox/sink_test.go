@@ -0,0 +1,83 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"multilayer/internal/outbox"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEnvelope() outbox.Envelope {
+	return outbox.Envelope{
+		ID:          "6f1c2a8e-0000-4000-8000-000000000001",
+		Type:        "user.registered",
+		AggregateID: 1,
+		OccurredAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Data:        json.RawMessage(`{"id":1,"username":"alice","email":"alice@example.com"}`),
+	}
+}
+
+func TestFileSink_AppendsNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink := outbox.NewFileSink(path)
+
+	require.NoError(t, sink.Publish(context.Background(), testEnvelope()))
+	require.NoError(t, sink.Publish(context.Background(), testEnvelope()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+
+	var envelope outbox.Envelope
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &envelope))
+	assert.Equal(t, testEnvelope().ID, envelope.ID)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received outbox.Envelope
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "user.registered", r.Header.Get("X-Event-Type"))
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := outbox.NewWebhookSink(server.URL)
+
+	require.NoError(t, sink.Publish(context.Background(), testEnvelope()))
+	assert.Equal(t, testEnvelope().ID, received.ID)
+
+	// Ответ не из диапазона 2xx означает неподтвержденную доставку
+	status = http.StatusServiceUnavailable
+	assert.Error(t, sink.Publish(context.Background(), testEnvelope()))
+}
+
+// closingSink считает вызовы Close
+type closingSink struct {
+	outbox.LogSink
+	closed int
+}
+
+func (s *closingSink) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestMultiSink_ClosesClosers(t *testing.T) {
+	closer := &closingSink{}
+	sinks := outbox.MultiSink{&outbox.LogSink{}, closer}
+
+	require.NoError(t, sinks.Close())
+	assert.Equal(t, 1, closer.closed)
+}
@@ -0,0 +1,152 @@
+// (Ограничение частоты запросов алгоритмом token bucket)
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit - параметры корзины: Requests запросов за Period в среднем и не больше
+// Burst подряд. Нулевой Limit ничего не ограничивает
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int // емкость корзины; 0 - равна Requests
+}
+
+// Enabled сообщает, задано ли ограничение
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// rate - сколько токенов добавляется за секунду
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// ParseLimit разбирает лимит вида "<запросов>/<период>", например "100/1m"
+// или "5/h"; период без числа означает одну единицу
+func ParseLimit(value string) (Limit, error) {
+	requests, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q must look like 100/1m", value)
+	}
+	count, err := strconv.Atoi(requests)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: requests must be a positive integer", value)
+	}
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid period", value)
+	}
+	return Limit{Requests: count, Period: duration}, nil
+}
+
+// Result - решение по запросу и состояние корзины после него
+type Result struct {
+	Allowed   bool
+	Remaining int           // сколько запросов еще пройдет подряд
+	Reset     time.Duration // через сколько корзина наполнится полностью
+	// RetryAfter - через сколько появится токен; 0, если запрос разрешен
+	RetryAfter time.Duration
+}
+
+// Store хранит корзины по ключу. Take атомарно пополняет корзину за прошедшее
+// время и забирает n токенов, если они есть; иначе не забирает ни одного.
+// Запрос дороже емкости корзины (Burst) не пройдет никогда. Распределенная реализация (например,
+// скрипт Redis) делает то же на стороне хранилища, чтобы лимит был общим для
+// всех экземпляров сервиса
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, n int, now time.Time) (Result, error)
+}
+
+// bucket - состояние корзины: токены на момент updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // когда корзина наполнится, если не брать токены
+}
+
+// take применяет алгоритм token bucket; общий для реализаций Store
+func (b *bucket) take(limit Limit, n int, now time.Time) Result {
+	capacity, rate := limit.capacity(), limit.rate()
+	if b.updated.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	}
+	b.updated = now
+
+	result := Result{}
+	if cost := float64(n); b.tokens >= cost {
+		b.tokens -= cost
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((cost - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+	return result
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
+
+// MemoryStore хранит корзины в памяти процесса; у каждого экземпляра сервиса
+// свои лимиты. Полные корзины периодически удаляются: они неотличимы от новых
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// sweepInterval - как часто MemoryStore удаляет наполнившиеся корзины
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, n int, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for key, b := range s.buckets {
+			if !b.full.After(now) {
+				delete(s.buckets, key)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{}
+		s.buckets[key] = b
+	}
+	return b.take(limit, n, now), nil
+}
+
+// Len возвращает число хранимых корзин
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
@@ -0,0 +1,107 @@
+package ratelimit_test
+
+import (
+	"context"
+	"multilayer/internal/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected ratelimit.Limit
+		wantErr  bool
+	}{
+		{"100/1m", ratelimit.Limit{Requests: 100, Period: time.Minute}, false},
+		{"5/h", ratelimit.Limit{Requests: 5, Period: time.Hour}, false},
+		{" 10/30s ", ratelimit.Limit{Requests: 10, Period: 30 * time.Second}, false},
+		{"100", ratelimit.Limit{}, true},
+		{"0/1m", ratelimit.Limit{}, true},
+		{"ten/1m", ratelimit.Limit{}, true},
+		{"10/soon", ratelimit.Limit{}, true},
+		{"10/-1m", ratelimit.Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			limit, err := ratelimit.ParseLimit(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, limit)
+		})
+	}
+}
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 2, Period: time.Minute, Burst: 3}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Полная корзина пропускает Burst запросов подряд
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := store.Take(ctx, "client", limit, 1, now)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+
+	result, err := store.Take(ctx, "client", limit, 1, now)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 30*time.Second, result.RetryAfter)
+	assert.Equal(t, 90*time.Second, result.Reset)
+
+	// Другой клиент не затронут
+	result, _ = store.Take(ctx, "other", limit, 1, now)
+	assert.True(t, result.Allowed)
+
+	// Токен добавляется раз в Period/Requests
+	result, _ = store.Take(ctx, "client", limit, 1, now.Add(29*time.Second))
+	assert.False(t, result.Allowed)
+	result, _ = store.Take(ctx, "client", limit, 1, now.Add(30*time.Second))
+	assert.True(t, result.Allowed)
+	assert.Zero(t, result.RetryAfter)
+}
+
+func TestMemoryStore_TakeSeveralTokens(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 5, Period: time.Minute}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	result, err := store.Take(ctx, "client", limit, 3, now)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+
+	// Недостающие токены не забираются частично
+	result, _ = store.Take(ctx, "client", limit, 3, now)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+	assert.Equal(t, 12*time.Second, result.RetryAfter)
+	result, _ = store.Take(ctx, "client", limit, 2, now)
+	assert.True(t, result.Allowed)
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 10, Period: 10 * time.Minute}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	_, _ = store.Take(ctx, "a", limit, 1, now)
+	_, _ = store.Take(ctx, "b", limit, 1, now.Add(30*time.Second))
+	assert.Equal(t, 2, store.Len())
+
+	// Токен возвращается за минуту: при очистке a полна и удаляется, b еще нет
+	_, _ = store.Take(ctx, "c", limit, 1, now.Add(65*time.Second))
+	assert.Equal(t, 2, store.Len())
+}
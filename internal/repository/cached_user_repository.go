@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"log"
+	"multilayer/internal/cache"
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheStats - счетчики обращений к кэшу пользователей
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Errors - сбои хранилища кэша; при сбое чтение идет в базу
+	Errors uint64 `json:"errors"`
+}
+
+// CachedUserRepository кэширует пользователей по ID поверх другого
+// репозитория. Create записывает новое состояние в кэш, Update и Delete
+// удаляют его; одновременные промахи по одному ключу дают один запрос к базе.
+// Внутри TxManager.Transaction чтение идет мимо кэша (транзакция видит свои
+// незафиксированные изменения), а запись в кэш откладывается до фиксации;
+// вне транзакции кэш обходится через BypassCache.
+// Изменения в обход репозитория (другие сервисы, ручные правки) видны только
+// по истечении TTL. Методы, кроме FindByID, FindByIDs и изменяющих, вызываются
+// напрямую
+type CachedUserRepository struct {
+	UserRepositoryInterface
+
+	store cache.Store
+	ttl   time.Duration
+	// KeyPrefix отделяет ключи пользователей в общем хранилище; по умолчанию "user:"
+	KeyPrefix string
+
+	group                singleflight.Group
+	hits, misses, errors atomic.Uint64
+}
+
+// NewCachedUserRepository - конструктор для CachedUserRepository; ttl <= 0 -
+// записи живут до вытеснения или изменения
+func NewCachedUserRepository(repo UserRepositoryInterface, store cache.Store, ttl time.Duration) *CachedUserRepository {
+	return &CachedUserRepository{UserRepositoryInterface: repo, store: store, ttl: ttl, KeyPrefix: "user:"}
+}
+
+// Stats возвращает счетчики с момента создания
+func (r *CachedUserRepository) Stats() CacheStats {
+	return CacheStats{Hits: r.hits.Load(), Misses: r.misses.Load(), Errors: r.errors.Load()}
+}
+
+// bypassCacheKey - ключ контекста, отключающего чтение из кэша
+type bypassCacheKey struct{}
+
+// BypassCache возвращает ctx, в котором FindByID и FindByIDs читают базу мимо
+// кэша. Нужен там, где устаревшее состояние недопустимо: локальный кэш одного
+// экземпляра не видит изменений, сделанных через другие
+func BypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func bypassesCache(ctx context.Context) bool {
+	return InTransaction(ctx) || ctx.Value(bypassCacheKey{}) != nil
+}
+
+func (r *CachedUserRepository) FindByID(ctx context.Context, id uint) (*entity.User, error) {
+	if bypassesCache(ctx) {
+		return r.UserRepositoryInterface.FindByID(ctx, id)
+	}
+	key := r.key(ctx, id)
+	if user, ok := r.get(ctx, key); ok {
+		return user, nil
+	}
+
+	// Запрос к базе не должен прерываться отменой ctx первого из ожидающих:
+	// его результат получат все
+	data, err, _ := r.group.Do(key, func() (any, error) {
+		loadCtx := context.WithoutCancel(ctx)
+		user, err := r.UserRepositoryInterface.FindByID(loadCtx, id)
+		if err != nil {
+			return nil, err
+		}
+		data, err := encodeUser(user)
+		if err != nil {
+			return nil, err
+		}
+		r.set(loadCtx, key, data)
+		return data, nil
+	})
+	if err != nil {
+		return &entity.User{}, err
+	}
+	// Каждый вызывающий получает свою копию
+	return decodeUser(data.([]byte))
+}
+
+// FindByIDs берет из кэша найденных пользователей и загружает остальных одним запросом
+func (r *CachedUserRepository) FindByIDs(ctx context.Context, ids []uint) ([]entity.User, error) {
+	if bypassesCache(ctx) {
+		return r.UserRepositoryInterface.FindByIDs(ctx, ids)
+	}
+	users := make([]entity.User, 0, len(ids))
+	var missing []uint
+	for _, id := range ids {
+		if user, ok := r.get(ctx, r.key(ctx, id)); ok {
+			users = append(users, *user)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	loaded, err := r.UserRepositoryInterface.FindByIDs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i := range loaded {
+		r.write(ctx, &loaded[i])
+	}
+	return append(users, loaded...), nil
+}
+
+func (r *CachedUserRepository) Create(ctx context.Context, user *entity.User) error {
+	if err := r.UserRepositoryInterface.Create(ctx, user); err != nil {
+		return err
+	}
+	r.write(ctx, user)
+	return nil
+}
+
+func (r *CachedUserRepository) CreateBatch(ctx context.Context, users []*entity.User) ([]error, error) {
+	errs, err := r.UserRepositoryInterface.CreateBatch(ctx, users)
+	if err != nil {
+		return errs, err
+	}
+	for i, user := range users {
+		if i < len(errs) && errs[i] != nil {
+			continue
+		}
+		r.write(ctx, user)
+	}
+	return errs, nil
+}
+
+func (r *CachedUserRepository) CreateInBatches(ctx context.Context, users []*entity.User, batchSize int) error {
+	if err := r.UserRepositoryInterface.CreateInBatches(ctx, users, batchSize); err != nil {
+		return err
+	}
+	for _, user := range users {
+		r.write(ctx, user)
+	}
+	return nil
+}
+
+func (r *CachedUserRepository) UpdateBatch(ctx context.Context, users []*entity.User) error {
+	if err := r.UserRepositoryInterface.UpdateBatch(ctx, users); err != nil {
+		for _, user := range users {
+			r.invalidate(ctx, user.ID)
+		}
+		return err
+	}
+	for _, user := range users {
+		r.write(ctx, user)
+	}
+	return nil
+}
+
+func (r *CachedUserRepository) DeleteBatch(ctx context.Context, ids []uint) error {
+	err := r.UserRepositoryInterface.DeleteBatch(ctx, ids)
+	for _, id := range ids {
+		r.invalidate(ctx, id)
+	}
+	if err == nil && InTransaction(ctx) {
+		AfterCommit(ctx, func() {
+			for _, id := range ids {
+				r.invalidate(context.WithoutCancel(ctx), id)
+			}
+		})
+	}
+	return err
+}
+
+func (r *CachedUserRepository) UpsertByEmail(ctx context.Context, user *entity.User) (bool, error) {
+	created, err := r.UserRepositoryInterface.UpsertByEmail(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	r.write(ctx, user)
+	return created, nil
+}
+
+// Update удаляет запись из кэша, а не записывает новое состояние: при
+// одновременных обновлениях запись в кэш последнего завершившегося запроса
+// могла бы оставить состояние, проигравшее в базе. Следующее чтение загрузит
+// зафиксированную строку
+func (r *CachedUserRepository) Update(ctx context.Context, user *entity.User) error {
+	err := r.UserRepositoryInterface.Update(ctx, user)
+	r.invalidate(ctx, user.ID)
+	if err == nil && InTransaction(ctx) {
+		// До фиксации параллельное чтение могло вернуть запись в кэш
+		AfterCommit(ctx, func() { r.invalidate(context.WithoutCancel(ctx), user.ID) })
+	}
+	return err
+}
+
+func (r *CachedUserRepository) Delete(ctx context.Context, id uint) error {
+	err := r.UserRepositoryInterface.Delete(ctx, id)
+	r.invalidate(ctx, id)
+	if err == nil && InTransaction(ctx) {
+		// До фиксации параллельное чтение могло вернуть запись в кэш
+		AfterCommit(ctx, func() { r.invalidate(context.WithoutCancel(ctx), id) })
+	}
+	return err
+}
+
+// key включает тенант: одинаковый ID в другом тенанте - другой ключ, а
+// пользователь чужого тенанта не попадет в ответ из кэша. Без тенанта
+// репозиторий вернет tenancy.ErrNoTenant, и ключ с нулевым тенантом не заполняется
+func (r *CachedUserRepository) key(ctx context.Context, id uint) string {
+	tenantID, _ := tenancy.FromContext(ctx)
+	return r.KeyPrefix + strconv.FormatUint(uint64(tenantID), 10) + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (r *CachedUserRepository) get(ctx context.Context, key string) (*entity.User, bool) {
+	data, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		r.storeFailed("get", key, err)
+	}
+	if ok {
+		if user, err := decodeUser(data); err == nil {
+			r.hits.Add(1)
+			return user, true
+		}
+	}
+	r.misses.Add(1)
+	return nil, false
+}
+
+func (r *CachedUserRepository) set(ctx context.Context, key string, data []byte) {
+	if err := r.store.Set(ctx, key, data, r.ttl); err != nil {
+		r.storeFailed("set", key, err)
+	}
+}
+
+// write кэширует состояние пользователя на момент вызова после фиксации транзакции
+func (r *CachedUserRepository) write(ctx context.Context, user *entity.User) {
+	id := user.ID
+	data, err := encodeUser(user)
+	if err != nil {
+		r.invalidate(ctx, id)
+		return
+	}
+	if InTransaction(ctx) {
+		// Прежнее значение уже неактуально, а новое еще не зафиксировано
+		r.invalidate(ctx, id)
+	}
+	AfterCommit(ctx, func() { r.set(context.WithoutCancel(ctx), r.key(ctx, id), data) })
+}
+
+func (r *CachedUserRepository) invalidate(ctx context.Context, id uint) {
+	key := r.key(ctx, id)
+	if err := r.store.Delete(ctx, key); err != nil {
+		r.storeFailed("delete", key, err)
+	}
+}
+
+// storeFailed учитывает сбой хранилища; запросы продолжают работать через базу
+func (r *CachedUserRepository) storeFailed(op, key string, err error) {
+	r.errors.Add(1)
+	log.Printf("user cache %s %s: %v", op, key, err)
+}
+
+// encodeUser сериализует пользователя целиком, включая поля, скрытые из JSON
+func encodeUser(user *entity.User) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(user); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeUser(data []byte) (*entity.User, error) {
+	var user entity.User
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
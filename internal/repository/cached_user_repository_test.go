@@ -0,0 +1,262 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multilayer/internal/cache"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"multilayer/internal/tenancy"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// countingUserRepository считает обращения к базе за пользователем по ID
+type countingUserRepository struct {
+	repository.UserRepositoryInterface
+	finds atomic.Int32
+	delay time.Duration
+}
+
+func (r *countingUserRepository) FindByID(ctx context.Context, id uint) (*entity.User, error) {
+	r.finds.Add(1)
+	time.Sleep(r.delay)
+	return r.UserRepositoryInterface.FindByID(ctx, id)
+}
+
+// fakeRemoteStore заменяет Redis в тестах; failing имитирует недоступность
+type fakeRemoteStore struct {
+	mu      sync.Mutex
+	values  map[string][]byte
+	failing bool
+}
+
+var errStoreUnavailable = errors.New("connection refused")
+
+func (s *fakeRemoteStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return nil, false, errStoreUnavailable
+	}
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+func (s *fakeRemoteStore) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return errStoreUnavailable
+	}
+	s.values[key] = value
+	return nil
+}
+
+func (s *fakeRemoteStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return errStoreUnavailable
+	}
+	for _, key := range keys {
+		delete(s.values, key)
+	}
+	return nil
+}
+
+func setupCachedRepository(t *testing.T, store cache.Store) (*repository.CachedUserRepository, *countingUserRepository) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}))
+
+	counting := &countingUserRepository{UserRepositoryInterface: repository.NewUserRepository(db)}
+	return repository.NewCachedUserRepository(counting, store, time.Minute), counting
+}
+
+func TestCachedUserRepository_FindByID(t *testing.T) {
+	repo, counting := setupCachedRepository(t, cache.NewLRU(100))
+	ctx := tenantContext()
+	user := &entity.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, repo.Create(ctx, user))
+
+	// Create записал пользователя в кэш: база не нужна
+	found, err := repo.FindByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", found.Username)
+	assert.Equal(t, "alice", found.UsernameKey)
+	assert.Equal(t, int32(0), counting.finds.Load())
+
+	// Изменение полученной копии не портит кэш
+	found.Username = "mallory"
+	again, err := repo.FindByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", again.Username)
+
+	// Отсутствующий пользователь не кэшируется
+	_, err = repo.FindByID(ctx, 999)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = repo.FindByID(ctx, 999)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, int32(2), counting.finds.Load())
+
+	assert.Equal(t, repository.CacheStats{Hits: 2, Misses: 2}, repo.Stats())
+}
+
+func TestCachedUserRepository_Invalidation(t *testing.T) {
+	lru := cache.NewLRU(100)
+	repo, counting := setupCachedRepository(t, lru)
+	ctx := tenantContext()
+	user := &entity.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, repo.Create(ctx, user))
+
+	// Обновление удаляет запись, следующее чтение идет в базу
+	user.DisplayName = "Alice"
+	require.NoError(t, repo.Update(ctx, user))
+	_, ok, _ := lru.Get(ctx, fmt.Sprintf("user:1:%d", user.ID))
+	assert.False(t, ok)
+	found, err := repo.FindByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.DisplayName)
+
+	// Неудачное обновление удаляет запись из кэша
+	missing := &entity.User{ID: 999, Username: "ghost", Email: "ghost@example.com"}
+	require.NoError(t, lru.Set(ctx, "user:1:999", []byte("stale"), 0))
+	assert.Error(t, repo.Update(ctx, missing))
+	_, ok, _ = lru.Get(ctx, "user:1:999")
+	assert.False(t, ok)
+
+	require.NoError(t, repo.Delete(ctx, user.ID))
+	_, err = repo.FindByID(ctx, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, int32(2), counting.finds.Load())
+}
+
+func TestCachedUserRepository_BypassCache(t *testing.T) {
+	repo, counting := setupCachedRepository(t, cache.NewLRU(100))
+	ctx := tenantContext()
+	user := &entity.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, repo.Create(ctx, user))
+
+	// Другой экземпляр сервиса блокирует пользователя: локальный кэш об этом не знает
+	user.Status = entity.UserSuspended
+	require.NoError(t, counting.UserRepositoryInterface.Update(ctx, user))
+
+	cached, err := repo.FindByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserActive, cached.Status)
+
+	found, err := repo.FindByID(repository.BypassCache(ctx), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserSuspended, found.Status)
+	users, err := repo.FindByIDs(repository.BypassCache(ctx), []uint{user.ID})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, entity.UserSuspended, users[0].Status)
+	assert.Equal(t, int32(1), counting.finds.Load())
+}
+
+func TestCachedUserRepository_FindByIDs(t *testing.T) {
+	repo, _ := setupCachedRepository(t, cache.NewLRU(100))
+	ctx := tenantContext()
+	users := []*entity.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+	}
+	errs, err := repo.CreateBatch(ctx, users)
+	require.NoError(t, err)
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	found, err := repo.FindByIDs(ctx, []uint{users[0].ID, users[1].ID, 999})
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+	assert.Equal(t, repository.CacheStats{Hits: 2, Misses: 1}, repo.Stats())
+}
+
+func TestCachedUserRepository_TenantKeys(t *testing.T) {
+	store := &fakeRemoteStore{values: map[string][]byte{}}
+	repo, _ := setupCachedRepository(t, store)
+	tenantA := tenancy.WithTenant(tenantContext(), 1)
+	tenantB := tenancy.WithTenant(tenantContext(), 2)
+
+	user := &entity.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, repo.Create(tenantA, user))
+	assert.Contains(t, store.values, fmt.Sprintf("user:1:%d", user.ID))
+
+	// Пользователь тенанта A из кэша не виден тенанту B
+	_, err := repo.FindByID(tenantB, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestCachedUserRepository_SingleFlight(t *testing.T) {
+	store := &fakeRemoteStore{values: map[string][]byte{}}
+	repo, counting := setupCachedRepository(t, store)
+	counting.delay = 50 * time.Millisecond
+	ctx := tenantContext()
+	user := &entity.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, repo.Create(ctx, user))
+	require.NoError(t, store.Delete(ctx, fmt.Sprintf("user:1:%d", user.ID)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found, err := repo.FindByID(ctx, user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "alice", found.Username)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), counting.finds.Load())
+	assert.Equal(t, uint64(10), repo.Stats().Misses)
+}
+
+func TestCachedUserRepository_StoreUnavailable(t *testing.T) {
+	store := &fakeRemoteStore{values: map[string][]byte{}, failing: true}
+	repo, counting := setupCachedRepository(t, store)
+	ctx := tenantContext()
+	user := &entity.User{Username: "alice", Email: "alice@example.com"}
+
+	// Сбой хранилища не мешает работе: чтение идет в базу
+	require.NoError(t, repo.Create(ctx, user))
+	found, err := repo.FindByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", found.Username)
+	assert.Equal(t, int32(1), counting.finds.Load())
+	assert.Equal(t, repository.CacheStats{Misses: 1, Errors: 3}, repo.Stats())
+}
+
+func TestCachedUserRepository_Batches(t *testing.T) {
+	lru := cache.NewLRU(100)
+	repo, counting := setupCachedRepository(t, lru)
+	ctx := tenantContext()
+	users := []*entity.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+	}
+	require.NoError(t, repo.CreateInBatches(ctx, users, 10))
+	assert.Equal(t, 2, lru.Len())
+
+	users[0].DisplayName = "Alice"
+	require.NoError(t, repo.UpdateBatch(ctx, users))
+	found, err := repo.FindByID(ctx, users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.DisplayName)
+
+	require.NoError(t, repo.DeleteBatch(ctx, []uint{users[0].ID, users[1].ID}))
+	assert.Equal(t, 0, lru.Len())
+	_, err = repo.FindByID(ctx, users[1].ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, int32(1), counting.finds.Load())
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IdempotencyRepositoryInterface определяет контракт хранилища ключей
+// идемпотентности. Записи принадлежат тенанту из ctx
+type IdempotencyRepositoryInterface interface {
+	// Create возвращает gorm.ErrDuplicatedKey, если ключ клиента уже занят
+	Create(ctx context.Context, record *entity.IdempotencyRecord) error
+	Find(ctx context.Context, caller, key string) (*entity.IdempotencyRecord, error)
+	// Complete и Extend возвращают gorm.ErrRecordNotFound, если запись уже
+	// завершена или удалена: ключ заняли заново после истечения блокировки
+	Complete(ctx context.Context, record *entity.IdempotencyRecord) error
+	Extend(ctx context.Context, record *entity.IdempotencyRecord, lockedUntil time.Time) error
+	// Delete удаляет запись, только если она не изменилась с момента чтения
+	// (тот же ID и Completed), и сообщает, была ли она удалена
+	Delete(ctx context.Context, record *entity.IdempotencyRecord) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type IdempotencyRepository struct {
+	DB *gorm.DB
+	// RowLevelSecurity - см. UserRepository.RowLevelSecurity
+	RowLevelSecurity bool
+}
+
+// NewIdempotencyRepository - конструктор для IdempotencyRepository
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+func (r *IdempotencyRepository) Create(ctx context.Context, record *entity.IdempotencyRecord) error {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	record.TenantID = tenantID
+	return translateError(r.DB, tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Create(record).Error
+	}))
+}
+
+func (r *IdempotencyRepository) Find(ctx context.Context, caller, key string) (*entity.IdempotencyRecord, error) {
+	var record entity.IdempotencyRecord
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).
+			Where("caller = ? AND idempotency_key = ?", caller, key).
+			First(&record).Error
+	})
+	return &record, err
+}
+
+// Complete сохраняет ответ и отмечает запрос выполненным
+func (r *IdempotencyRepository) Complete(ctx context.Context, record *entity.IdempotencyRecord) error {
+	record.Completed = true
+	return tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		result := db.Model(record).Scopes(forTenant(ctx)).
+			Where("completed = ?", false).
+			Select("completed", "response_status", "response_content_type", "response_body", "expires_at").
+			Updates(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// Extend продлевает блокировку выполняющегося запроса
+func (r *IdempotencyRepository) Extend(ctx context.Context, record *entity.IdempotencyRecord, lockedUntil time.Time) error {
+	return tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		result := db.Model(&entity.IdempotencyRecord{}).Scopes(forTenant(ctx)).
+			Where("id = ? AND completed = ?", record.ID, false).
+			Update("locked_until", lockedUntil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		record.LockedUntil = lockedUntil
+		return nil
+	})
+}
+
+func (r *IdempotencyRepository) Delete(ctx context.Context, record *entity.IdempotencyRecord) (bool, error) {
+	var deleted bool
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		result := db.Scopes(forTenant(ctx)).
+			Where("completed = ?", record.Completed).
+			Delete(&entity.IdempotencyRecord{}, record.ID)
+		deleted = result.RowsAffected > 0
+		return result.Error
+	})
+	return deleted, err
+}
+
+// DeleteExpired удаляет записи всех тенантов с истекшим сроком хранения
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+	err := allTenantsTransaction(ctx, r.DB, r.RowLevelSecurity, func(tx *gorm.DB) error {
+		result := tx.Where("expires_at <= ?", now).Delete(&entity.IdempotencyRecord{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+
+	"gorm.io/gorm"
+)
+
+// ErrInUse - запись нельзя удалить, пока на нее ссылаются другие записи
+var ErrInUse = errors.New("record is still referenced")
+
+// OrganizationRepositoryInterface определяет контракт хранилища организаций и
+// их участников. Все методы работают только с данными тенанта из ctx
+type OrganizationRepositoryInterface interface {
+	Create(ctx context.Context, organization *entity.Organization) error
+	FindByID(ctx context.Context, id uint) (*entity.Organization, error)
+	List(ctx context.Context) ([]entity.Organization, error)
+	Delete(ctx context.Context, id uint) error
+	AddMember(ctx context.Context, member *entity.OrganizationMember) error
+	FindMember(ctx context.Context, organizationID, userID uint) (*entity.OrganizationMember, error)
+	RemoveMember(ctx context.Context, organizationID, userID uint) error
+	ListMembers(ctx context.Context, organizationID uint) ([]entity.OrganizationMember, error)
+	ListUserMemberships(ctx context.Context, userID uint) ([]entity.UserMembership, error)
+}
+
+type OrganizationRepository struct {
+	DB *gorm.DB
+	// RowLevelSecurity - см. UserRepository.RowLevelSecurity
+	RowLevelSecurity bool
+}
+
+// NewOrganizationRepository - конструктор для OrganizationRepository
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{DB: db}
+}
+
+func (r *OrganizationRepository) Create(ctx context.Context, organization *entity.Organization) error {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	organization.TenantID = tenantID
+	return translateError(r.DB, tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Create(organization).Error
+	}))
+}
+
+func (r *OrganizationRepository) FindByID(ctx context.Context, id uint) (*entity.Organization, error) {
+	var organization entity.Organization
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).First(&organization, id).Error
+	})
+	return &organization, err
+}
+
+func (r *OrganizationRepository) List(ctx context.Context) ([]entity.Organization, error) {
+	var organizations []entity.Organization
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Order("id").Find(&organizations).Error
+	})
+	return organizations, err
+}
+
+// Delete удаляет организацию; участники удаляются внешним ключом. Организацию
+// с командами удалить нельзя (ErrInUse): команды удаляются явно
+func (r *OrganizationRepository) Delete(ctx context.Context, id uint) error {
+	return tenantTransaction(ctx, r.DB, r.RowLevelSecurity, func(tx *gorm.DB) error {
+		var organization entity.Organization
+		if err := tx.Scopes(forTenant(ctx)).First(&organization, id).Error; err != nil {
+			return err
+		}
+		var teams int64
+		if err := tx.Model(&entity.Team{}).Where("organization_id = ?", id).Count(&teams).Error; err != nil {
+			return err
+		}
+		if teams > 0 {
+			return ErrInUse
+		}
+		return tx.Delete(&organization).Error
+	})
+}
+
+// AddMember добавляет участника в тенанте из ctx; повторное добавление
+// возвращает gorm.ErrDuplicatedKey
+func (r *OrganizationRepository) AddMember(ctx context.Context, member *entity.OrganizationMember) error {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	member.TenantID = tenantID
+	return translateError(r.DB, tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Create(member).Error
+	}))
+}
+
+func (r *OrganizationRepository) FindMember(ctx context.Context, organizationID, userID uint) (*entity.OrganizationMember, error) {
+	var member entity.OrganizationMember
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).
+			Where("organization_id = ? AND user_id = ?", organizationID, userID).
+			First(&member).Error
+	})
+	return &member, err
+}
+
+// RemoveMember исключает пользователя из организации и из всех ее команд
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, organizationID, userID uint) error {
+	return tenantTransaction(ctx, r.DB, r.RowLevelSecurity, func(tx *gorm.DB) error {
+		result := tx.Scopes(forTenant(ctx)).
+			Where("organization_id = ? AND user_id = ?", organizationID, userID).
+			Delete(&entity.OrganizationMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		teams := tx.Model(&entity.Team{}).Select("id").Where("organization_id = ?", organizationID)
+		return tx.Where("user_id = ? AND team_id IN (?)", userID, teams).Delete(&entity.TeamMember{}).Error
+	})
+}
+
+func (r *OrganizationRepository) ListMembers(ctx context.Context, organizationID uint) ([]entity.OrganizationMember, error) {
+	var members []entity.OrganizationMember
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).
+			Where("organization_id = ?", organizationID).
+			Order("user_id").
+			Find(&members).Error
+	})
+	return members, err
+}
+
+// ListUserMemberships возвращает членства пользователя в организациях и
+// командах: сначала организации, затем команды, каждые по возрастанию ID
+func (r *OrganizationRepository) ListUserMemberships(ctx context.Context, userID uint) ([]entity.UserMembership, error) {
+	var (
+		organizationMembers []entity.OrganizationMember
+		teamMembers         []entity.TeamMember
+		teams               = make(map[uint]entity.Team)
+		organizations       = make(map[uint]entity.Organization)
+	)
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		err := db.Scopes(forTenant(ctx)).Where("user_id = ?", userID).Order("organization_id").Find(&organizationMembers).Error
+		if err != nil {
+			return err
+		}
+		if err := db.Scopes(forTenant(ctx)).Where("user_id = ?", userID).Order("team_id").Find(&teamMembers).Error; err != nil {
+			return err
+		}
+
+		if len(teamMembers) > 0 {
+			teamIDs := make([]uint, len(teamMembers))
+			for i, member := range teamMembers {
+				teamIDs[i] = member.TeamID
+			}
+			var found []entity.Team
+			if err := db.Where("id IN ?", teamIDs).Find(&found).Error; err != nil {
+				return err
+			}
+			for _, team := range found {
+				teams[team.ID] = team
+			}
+		}
+
+		organizationIDs := make([]uint, 0, len(organizationMembers)+len(teams))
+		for _, member := range organizationMembers {
+			organizationIDs = append(organizationIDs, member.OrganizationID)
+		}
+		for _, team := range teams {
+			organizationIDs = append(organizationIDs, team.OrganizationID)
+		}
+		if len(organizationIDs) > 0 {
+			var found []entity.Organization
+			if err := db.Where("id IN ?", organizationIDs).Find(&found).Error; err != nil {
+				return err
+			}
+			for _, organization := range found {
+				organizations[organization.ID] = organization
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	memberships := make([]entity.UserMembership, 0, len(organizationMembers)+len(teamMembers))
+	for _, member := range organizationMembers {
+		memberships = append(memberships, entity.UserMembership{
+			Organization: organizations[member.OrganizationID],
+			Role:         member.Role,
+			CreatedAt:    member.CreatedAt,
+		})
+	}
+	for _, member := range teamMembers {
+		team := teams[member.TeamID]
+		memberships = append(memberships, entity.UserMembership{
+			Organization: organizations[team.OrganizationID],
+			Team:         &team,
+			Role:         member.Role,
+			CreatedAt:    member.CreatedAt,
+		})
+	}
+	return memberships, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"multilayer/internal/tenancy"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// tenantTransaction выполняет fn в транзакции; с rowLevelSecurity транзакция
+// начинается с установки тенанта из ctx в переменную сессии, которая действует
+// до ее завершения. Без тенанта в ctx транзакция не начинается
+func tenantTransaction(ctx context.Context, db *gorm.DB, rowLevelSecurity bool, fn func(tx *gorm.DB) error) error {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	return conn(ctx, db).Transaction(func(tx *gorm.DB) error {
+		if rowLevelSecurity {
+			setting := strconv.FormatUint(uint64(tenantID), 10)
+			if err := tx.Exec("SELECT set_config(?, ?, true)", tenancy.SessionSetting, setting).Error; err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// tenantRead выполняет операцию с данными тенанта из ctx; без rowLevelSecurity
+// транзакция для нее не нужна
+func tenantRead(ctx context.Context, db *gorm.DB, rowLevelSecurity bool, fn func(db *gorm.DB) error) error {
+	if !rowLevelSecurity {
+		return fn(conn(ctx, db))
+	}
+	return tenantTransaction(ctx, db, rowLevelSecurity, fn)
+}
+
+// allTenantsTransaction выполняет fn в транзакции, которой политики открывают
+// строки всех тенантов (tenancy.AllTenantsSetting). Только для фоновых задач,
+// обрабатывающих очереди всех тенантов
+func allTenantsTransaction(ctx context.Context, db *gorm.DB, rowLevelSecurity bool, fn func(tx *gorm.DB) error) error {
+	return conn(ctx, db).Transaction(func(tx *gorm.DB) error {
+		if rowLevelSecurity {
+			if err := tx.Exec("SELECT set_config(?, 'on', true)", tenancy.AllTenantsSetting).Error; err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+
+	"gorm.io/gorm"
+)
+
+// TeamRepositoryInterface определяет контракт хранилища команд и их участников.
+// Все методы работают только с данными тенанта из ctx
+type TeamRepositoryInterface interface {
+	Create(ctx context.Context, team *entity.Team) error
+	FindByID(ctx context.Context, organizationID, id uint) (*entity.Team, error)
+	List(ctx context.Context, organizationID uint) ([]entity.Team, error)
+	Delete(ctx context.Context, organizationID, id uint) error
+	AddMember(ctx context.Context, member *entity.TeamMember) error
+	RemoveMember(ctx context.Context, teamID, userID uint) error
+	ListMembers(ctx context.Context, teamID uint) ([]entity.TeamMember, error)
+}
+
+type TeamRepository struct {
+	DB *gorm.DB
+	// RowLevelSecurity - см. UserRepository.RowLevelSecurity
+	RowLevelSecurity bool
+}
+
+// NewTeamRepository - конструктор для TeamRepository
+func NewTeamRepository(db *gorm.DB) *TeamRepository {
+	return &TeamRepository{DB: db}
+}
+
+func (r *TeamRepository) Create(ctx context.Context, team *entity.Team) error {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	team.TenantID = tenantID
+	return translateError(r.DB, tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Create(team).Error
+	}))
+}
+
+// FindByID ищет команду только среди команд организации organizationID
+func (r *TeamRepository) FindByID(ctx context.Context, organizationID, id uint) (*entity.Team, error) {
+	var team entity.Team
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where("organization_id = ?", organizationID).First(&team, id).Error
+	})
+	return &team, err
+}
+
+func (r *TeamRepository) List(ctx context.Context, organizationID uint) ([]entity.Team, error) {
+	var teams []entity.Team
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where("organization_id = ?", organizationID).Order("id").Find(&teams).Error
+	})
+	return teams, err
+}
+
+// Delete удаляет команду; участники удаляются внешним ключом
+func (r *TeamRepository) Delete(ctx context.Context, organizationID, id uint) error {
+	return tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		result := db.Scopes(forTenant(ctx)).Where("organization_id = ?", organizationID).Delete(&entity.Team{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// AddMember добавляет участника в тенанте из ctx; повторное добавление
+// возвращает gorm.ErrDuplicatedKey
+func (r *TeamRepository) AddMember(ctx context.Context, member *entity.TeamMember) error {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	member.TenantID = tenantID
+	return translateError(r.DB, tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Create(member).Error
+	}))
+}
+
+func (r *TeamRepository) RemoveMember(ctx context.Context, teamID, userID uint) error {
+	return tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		result := db.Scopes(forTenant(ctx)).
+			Where("team_id = ? AND user_id = ?", teamID, userID).
+			Delete(&entity.TeamMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *TeamRepository) ListMembers(ctx context.Context, teamID uint) ([]entity.TeamMember, error) {
+	var members []entity.TeamMember
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where("team_id = ?", teamID).Order("user_id").Find(&members).Error
+	})
+	return members, err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"multilayer/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+// TenantRepositoryInterface определяет контракт хранилища тенантов
+type TenantRepositoryInterface interface {
+	Create(tenant *entity.Tenant) error
+	FindByID(id uint) (*entity.Tenant, error)
+	FindBySlug(slug string) (*entity.Tenant, error)
+	List() ([]entity.Tenant, error)
+	Update(tenant *entity.Tenant) error
+}
+
+type TenantRepository struct {
+	DB *gorm.DB
+}
+
+// NewTenantRepository - конструктор для TenantRepository
+func NewTenantRepository(db *gorm.DB) *TenantRepository {
+	return &TenantRepository{DB: db}
+}
+
+func (r *TenantRepository) Create(tenant *entity.Tenant) error {
+	return translateError(r.DB, r.DB.Create(tenant).Error)
+}
+
+func (r *TenantRepository) FindByID(id uint) (*entity.Tenant, error) {
+	var tenant entity.Tenant
+	err := r.DB.First(&tenant, id).Error
+	return &tenant, err
+}
+
+func (r *TenantRepository) FindBySlug(slug string) (*entity.Tenant, error) {
+	var tenant entity.Tenant
+	err := r.DB.Where("slug = ?", slug).First(&tenant).Error
+	return &tenant, err
+}
+
+func (r *TenantRepository) List() ([]entity.Tenant, error) {
+	var tenants []entity.Tenant
+	err := r.DB.Order("id").Find(&tenants).Error
+	return tenants, err
+}
+
+func (r *TenantRepository) Update(tenant *entity.Tenant) error {
+	return r.DB.Save(tenant).Error
+}
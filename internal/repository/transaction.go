@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Transactor выполняет fn в транзакции. Репозитории, вызванные с ctx,
+// переданным в fn, работают в этой транзакции
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TxManager - единица работы над несколькими репозиториями. Вложенный вызов
+// Transaction работает в точке сохранения внешней транзакции: его ошибка
+// откатывает только его изменения. Внешняя транзакция, прерванная конфликтом
+// сериализации или взаимоблокировкой Postgres, повторяется целиком, поэтому fn
+// не должна иметь побочных эффектов вне базы - их откладывают через AfterCommit
+type TxManager struct {
+	DB *gorm.DB
+	// Options - уровень изоляции внешних транзакций; nil - уровень СУБД по умолчанию
+	Options *sql.TxOptions
+	// MaxRetries - сколько раз повторить транзакцию после конфликта, по умолчанию 3
+	MaxRetries int
+}
+
+// NewTxManager - конструктор для TxManager
+func NewTxManager(db *gorm.DB) *TxManager {
+	return &TxManager{DB: db, MaxRetries: 3}
+}
+
+type txKey struct{}
+
+// txState - транзакция (или точка сохранения) из ctx и действия после фиксации
+type txState struct {
+	tx          *gorm.DB
+	afterCommit []func()
+}
+
+func (m *TxManager) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if parent, ok := ctx.Value(txKey{}).(*txState); ok {
+		state := &txState{}
+		err := parent.tx.Transaction(func(tx *gorm.DB) error {
+			state.tx = tx
+			return fn(context.WithValue(ctx, txKey{}, state))
+		})
+		if err == nil {
+			// Действия точки сохранения выполнятся после фиксации внешней транзакции
+			parent.afterCommit = append(parent.afterCommit, state.afterCommit...)
+		}
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		state := &txState{}
+		err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			state.tx = tx
+			return fn(context.WithValue(ctx, txKey{}, state))
+		}, m.Options)
+		if err == nil {
+			for _, action := range state.afterCommit {
+				action()
+			}
+			return nil
+		}
+		if attempt >= m.MaxRetries || !IsSerializationFailure(err) {
+			return err
+		}
+		// Случайная пауза разводит повторы конфликтующих транзакций
+		delay := time.Duration(attempt+1) * (10*time.Millisecond + rand.N(10*time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// IsSerializationFailure сообщает, что транзакцию можно повторить: Postgres
+// прервал ее из-за конфликта сериализации (40001) или взаимоблокировки (40P01)
+func IsSerializationFailure(err error) bool {
+	var sqlErr interface{ SQLState() string }
+	if !errors.As(err, &sqlErr) {
+		return false
+	}
+	code := sqlErr.SQLState()
+	return code == "40001" || code == "40P01"
+}
+
+// InTransaction сообщает, выполняется ли ctx внутри TxManager.Transaction
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txState)
+	return ok
+}
+
+// AfterCommit откладывает action до фиксации транзакции из ctx; при откате
+// action не выполняется. Вне транзакции action выполняется сразу
+func AfterCommit(ctx context.Context, action func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, action)
+		return
+	}
+	action()
+}
+
+// conn возвращает транзакцию из ctx, а вне транзакции - db с контекстом ctx
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,174 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multilayer/internal/audit"
+	"multilayer/internal/cache"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTxManager(t *testing.T) (*repository.TxManager, *repository.UserRepository, *gorm.DB) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}, &entity.UserAuditEntry{}))
+
+	userRepo := repository.NewUserRepository(db)
+	userRepo.Events = audit.NewRecorder()
+	return repository.NewTxManager(db), userRepo, db
+}
+
+func countRows(t *testing.T, db *gorm.DB, model any) int64 {
+	var count int64
+	require.NoError(t, db.Model(model).Count(&count).Error)
+	return count
+}
+
+func TestTxManager_CommitAndRollback(t *testing.T) {
+	manager, userRepo, db := setupTxManager(t)
+	ctx := tenantContext()
+	errAbort := errors.New("abort")
+
+	committed := false
+	err := manager.Transaction(ctx, func(ctx context.Context) error {
+		assert.True(t, repository.InTransaction(ctx))
+		repository.AfterCommit(ctx, func() { committed = true })
+		return userRepo.Create(ctx, &entity.User{Username: "alice", Email: "alice@example.com"})
+	})
+	require.NoError(t, err)
+	assert.True(t, committed)
+
+	// Откат отменяет и пользователя, и запись журнала аудита
+	rolledBack := false
+	err = manager.Transaction(ctx, func(ctx context.Context) error {
+		repository.AfterCommit(ctx, func() { rolledBack = true })
+		user := &entity.User{Username: "bob", Email: "bob@example.com"}
+		if err := userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		user.DisplayName = "Bob"
+		if err := userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	assert.False(t, rolledBack)
+	assert.Equal(t, int64(1), countRows(t, db, &entity.User{}))
+	assert.Equal(t, int64(1), countRows(t, db, &entity.UserAuditEntry{}))
+}
+
+func TestTxManager_NestedSavepoint(t *testing.T) {
+	manager, userRepo, db := setupTxManager(t)
+	ctx := tenantContext()
+	errInner := errors.New("inner failed")
+
+	var actions []string
+	err := manager.Transaction(ctx, func(ctx context.Context) error {
+		if err := userRepo.Create(ctx, &entity.User{Username: "alice", Email: "alice@example.com"}); err != nil {
+			return err
+		}
+		err := manager.Transaction(ctx, func(ctx context.Context) error {
+			repository.AfterCommit(ctx, func() { actions = append(actions, "failed inner") })
+			if err := userRepo.Create(ctx, &entity.User{Username: "bob", Email: "bob@example.com"}); err != nil {
+				return err
+			}
+			return errInner
+		})
+		assert.ErrorIs(t, err, errInner)
+
+		return manager.Transaction(ctx, func(ctx context.Context) error {
+			repository.AfterCommit(ctx, func() { actions = append(actions, "inner") })
+			return userRepo.Create(ctx, &entity.User{Username: "carol", Email: "carol@example.com"})
+		})
+	})
+	require.NoError(t, err)
+
+	var usernames []string
+	require.NoError(t, db.Model(&entity.User{}).Order("username").Pluck("username", &usernames).Error)
+	assert.Equal(t, []string{"alice", "carol"}, usernames)
+	assert.Equal(t, []string{"inner"}, actions)
+}
+
+// sqlStateError имитирует ошибку драйвера Postgres с кодом SQLSTATE
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestTxManager_RetriesSerializationFailures(t *testing.T) {
+	manager, userRepo, db := setupTxManager(t)
+	ctx := tenantContext()
+
+	attempts := 0
+	err := manager.Transaction(ctx, func(ctx context.Context) error {
+		attempts++
+		if err := userRepo.Create(ctx, &entity.User{Username: "alice", Email: "alice@example.com"}); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return fmt.Errorf("update: %w", sqlStateError("40001"))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, int64(1), countRows(t, db, &entity.User{}))
+
+	t.Run("Gives up after MaxRetries", func(t *testing.T) {
+		attempts = 0
+		manager.MaxRetries = 1
+		err := manager.Transaction(ctx, func(ctx context.Context) error {
+			attempts++
+			return sqlStateError("40P01")
+		})
+		assert.True(t, repository.IsSerializationFailure(err))
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("Other errors are not retried", func(t *testing.T) {
+		attempts = 0
+		err := manager.Transaction(ctx, func(ctx context.Context) error {
+			attempts++
+			return sqlStateError("23505")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestTxManager_CacheWritesAfterCommit(t *testing.T) {
+	manager, userRepo, _ := setupTxManager(t)
+	lru := cache.NewLRU(10)
+	cached := repository.NewCachedUserRepository(userRepo, lru, time.Minute)
+	ctx := tenantContext()
+
+	err := manager.Transaction(ctx, func(ctx context.Context) error {
+		if err := cached.Create(ctx, &entity.User{Username: "ghost", Email: "ghost@example.com"}); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 0, lru.Len(), "rolled back user must not be cached")
+
+	user := &entity.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, manager.Transaction(ctx, func(ctx context.Context) error {
+		if err := cached.Create(ctx, user); err != nil {
+			return err
+		}
+		assert.Equal(t, 0, lru.Len())
+		return nil
+	}))
+	assert.Equal(t, 1, lru.Len())
+}
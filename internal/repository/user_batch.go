@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// userUpsertColumns - колонки, которые UpdateBatch перезаписывает у существующих строк
+var userUpsertColumns = []string{
+	"username", "email", "username_key", "email_key",
+	"display_name", "locale", "timezone", "avatar_url", "updated_at",
+}
+
+// CreateInBatches вставляет пользователей многострочными INSERT по batchSize строк
+// в одной транзакции. В отличие от CreateBatch ошибка любой строки откатывает всю вставку
+func (r *UserRepository) CreateInBatches(ctx context.Context, users []*entity.User, batchSize int) error {
+	if len(users) == 0 {
+		return nil
+	}
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	for _, user := range users {
+		user.TenantID = tenantID
+	}
+	r.setEmailKeys(users...)
+	err := r.transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(users, batchSize).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := r.recordEvent(tx, entity.UserRegistered, user, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, user := range users {
+			user.ID = 0
+		}
+	}
+	return translateError(r.DB, err)
+}
+
+// UpdateBatch сохраняет пользователей одним многострочным upsert по ID. Если кого-то
+// из них нет в тенанте из ctx, возвращает gorm.ErrRecordNotFound и ничего не меняет
+func (r *UserRepository) UpdateBatch(ctx context.Context, users []*entity.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	r.setEmailKeys(users...)
+	return translateError(r.DB, r.transaction(ctx, func(tx *gorm.DB) error {
+		// Предыдущие состояния нужны для журнала аудита, а их наличие в тенанте
+		// не дает upsert по ID вставить строку или задеть чужой тенант
+		var previous []entity.User
+		if err := tx.Scopes(forTenant(ctx)).Where("id IN ?", ids).Find(&previous).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*entity.User, len(previous))
+		for i := range previous {
+			byID[previous[i].ID] = &previous[i]
+		}
+		now := time.Now()
+		for _, user := range users {
+			prev, ok := byID[user.ID]
+			if !ok {
+				return gorm.ErrRecordNotFound
+			}
+			user.TenantID = prev.TenantID
+			user.CreatedAt = prev.CreatedAt
+			// Статус меняется только через Update после entity.User.TransitionTo
+			user.Status, user.StatusReason, user.StatusChangedAt = prev.Status, prev.StatusReason, prev.StatusChangedAt
+			// Create не обновляет заполненный UpdatedAt
+			user.UpdatedAt = now
+		}
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns(userUpsertColumns),
+		}).Create(users).Error
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := r.recordEvent(tx, entity.UserUpdated, user, byID[user.ID]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// DeleteBatch удаляет пользователей одним запросом. Если кого-то из них нет в
+// тенанте из ctx, возвращает gorm.ErrRecordNotFound и ничего не удаляет
+func (r *UserRepository) DeleteBatch(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return translateError(r.DB, r.transaction(ctx, func(tx *gorm.DB) error {
+		// Последние состояния нужны для событий UserDeleted
+		var users []entity.User
+		if err := tx.Scopes(forTenant(ctx)).Where("id IN ?", ids).Find(&users).Error; err != nil {
+			return err
+		}
+		if len(users) != len(uniqueIDs(ids)) {
+			return gorm.ErrRecordNotFound
+		}
+		result := tx.Scopes(forTenant(ctx)).Where("id IN ?", ids).Delete(&entity.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(users)) {
+			return gorm.ErrRecordNotFound
+		}
+		for i := range users {
+			if err := r.recordEvent(tx, entity.UserDeleted, &users[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func uniqueIDs(ids []uint) map[uint]struct{} {
+	unique := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		unique[id] = struct{}{}
+	}
+	return unique
+}
@@ -0,0 +1,114 @@
+package repository_test
+
+import (
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUserRepository_CreateInBatches(t *testing.T) {
+	_, userRepo, db := setupTxManager(t)
+	ctx := tenancy.WithTenant(tenantContext(), 7)
+
+	users := []*entity.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+		{Username: "carol", Email: "carol@example.com"},
+	}
+	require.NoError(t, userRepo.CreateInBatches(ctx, users, 2))
+	for _, user := range users {
+		assert.NotZero(t, user.ID)
+		assert.Equal(t, uint(7), user.TenantID)
+	}
+	assert.Equal(t, int64(3), countRows(t, db, &entity.UserAuditEntry{}))
+
+	// Дубликат откатывает всю вставку
+	duplicates := []*entity.User{
+		{Username: "dave", Email: "dave@example.com"},
+		{Username: "ALICE", Email: "alice2@example.com"},
+	}
+	err := userRepo.CreateInBatches(ctx, duplicates, 10)
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	assert.Zero(t, duplicates[0].ID)
+	assert.Equal(t, int64(3), countRows(t, db, &entity.User{}))
+}
+
+func TestUserRepository_UpdateBatch(t *testing.T) {
+	_, userRepo, db := setupTxManager(t)
+	ctx := tenantContext()
+
+	users := []*entity.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+	}
+	require.NoError(t, userRepo.CreateInBatches(ctx, users, 10))
+
+	users[0].Email = "alice@corp.example.com"
+	users[1].Username = "Robert"
+	require.NoError(t, userRepo.UpdateBatch(ctx, users))
+
+	found, err := userRepo.FindByIDs(ctx, []uint{users[0].ID, users[1].ID})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, "alice@corp.example.com", found[0].Email)
+	assert.Equal(t, "Robert", found[1].Username)
+	assert.Equal(t, "robert", found[1].UsernameKey)
+	assert.Equal(t, users[1].CreatedAt.Unix(), found[1].CreatedAt.Unix())
+
+	history, err := userRepo.ListHistory(ctx, users[1].ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, entity.UserUpdated, history[0].Action)
+
+	t.Run("Missing user", func(t *testing.T) {
+		missing := &entity.User{ID: 999, Username: "ghost", Email: "ghost@example.com"}
+		users[0].DisplayName = "Alice"
+		err := userRepo.UpdateBatch(ctx, []*entity.User{users[0], missing})
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Equal(t, int64(2), countRows(t, db, &entity.User{}))
+
+		found, err := userRepo.FindByID(ctx, users[0].ID)
+		require.NoError(t, err)
+		assert.Empty(t, found.DisplayName)
+	})
+
+	t.Run("Other tenant", func(t *testing.T) {
+		other := tenancy.WithTenant(ctx, 2)
+		err := userRepo.UpdateBatch(other, []*entity.User{users[0]})
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Unique conflict", func(t *testing.T) {
+		users[0].Username = "robert"
+		err := userRepo.UpdateBatch(ctx, []*entity.User{users[0]})
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	})
+}
+
+func TestUserRepository_DeleteBatch(t *testing.T) {
+	_, userRepo, db := setupTxManager(t)
+	ctx := tenantContext()
+
+	users := []*entity.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+		{Username: "carol", Email: "carol@example.com"},
+	}
+	require.NoError(t, userRepo.CreateInBatches(ctx, users, 10))
+
+	err := userRepo.DeleteBatch(ctx, []uint{users[0].ID, 999})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, int64(3), countRows(t, db, &entity.User{}))
+
+	require.NoError(t, userRepo.DeleteBatch(ctx, []uint{users[0].ID, users[1].ID}))
+	assert.Equal(t, int64(1), countRows(t, db, &entity.User{}))
+
+	history, err := userRepo.ListHistory(ctx, users[0].ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, entity.UserDeleted, history[0].Action)
+}
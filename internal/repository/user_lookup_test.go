@@ -0,0 +1,51 @@
+package repository_test
+
+import (
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUserRepository_FindByUsernameAndEmail(t *testing.T) {
+	_, userRepo, _ := setupTxManager(t)
+	ctx := tenantContext()
+	users := []*entity.User{
+		{Username: "Alice", Email: "Alice.Smith@Example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+		{Username: "carol", Email: "carol@example.com"},
+	}
+	require.NoError(t, userRepo.CreateInBatches(ctx, users, 10))
+
+	found, err := userRepo.FindByUsername(ctx, "  ALICE ")
+	require.NoError(t, err)
+	assert.Equal(t, users[0].ID, found.ID)
+
+	found, err = userRepo.FindByEmail(ctx, "alice.smith@EXAMPLE.COM")
+	require.NoError(t, err)
+	assert.Equal(t, users[0].ID, found.ID)
+
+	_, err = userRepo.FindByUsername(ctx, "ghost")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = userRepo.FindByEmail(tenancy.WithTenant(ctx, 2), "bob@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	t.Run("Many identifiers", func(t *testing.T) {
+		found, err := userRepo.FindByUsernamesOrEmails(ctx, []string{"BOB", "ghost"}, []string{"CAROL@example.com", "bob@example.com"})
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		assert.Equal(t, "bob", found[0].Username)
+		assert.Equal(t, "carol", found[1].Username)
+
+		found, err = userRepo.FindByUsernamesOrEmails(ctx, nil, []string{"alice.smith@example.com"})
+		require.NoError(t, err)
+		assert.Len(t, found, 1)
+
+		found, err = userRepo.FindByUsernamesOrEmails(ctx, nil, nil)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+	})
+}
@@ -2,19 +2,88 @@
 package repository
 
 import (
+	"context"
 	"gorm.io/gorm"
 	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"strings"
+	"time"
 )
 
-// UserRepositoryInterface определяет контракт для репозитория
+// UserRepositoryInterface определяет контракт для репозитория. Все методы
+// работают только с данными тенанта из ctx (см. tenancy.FromContext)
 type UserRepositoryInterface interface {
-	Create(user *entity.User) error
-	FindByID(id uint) (*entity.User, error)
-	Update(user *entity.User) error
+	Create(ctx context.Context, user *entity.User) error
+	FindByID(ctx context.Context, id uint) (*entity.User, error)
+	FindVersionAt(ctx context.Context, id uint, at time.Time) (*entity.User, error)
+	FindByIDs(ctx context.Context, ids []uint) ([]entity.User, error)
+	FindByUsername(ctx context.Context, username string) (*entity.User, error)
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	EmailKey(email string) string
+	FindByUsernamesOrEmails(ctx context.Context, usernames, emails []string) ([]entity.User, error)
+	Update(ctx context.Context, user *entity.User) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, filter UserFilter, afterID uint, limit int) ([]entity.User, error)
+	CreateBatch(ctx context.Context, users []*entity.User) ([]error, error)
+	CreateInBatches(ctx context.Context, users []*entity.User, batchSize int) error
+	UpdateBatch(ctx context.Context, users []*entity.User) error
+	DeleteBatch(ctx context.Context, ids []uint) error
+	UpsertByEmail(ctx context.Context, user *entity.User) (bool, error)
+	FindInBatches(ctx context.Context, batchSize int, fn func(users []entity.User) error) error
+	ListHistory(ctx context.Context, userID, beforeID uint, limit int) ([]entity.UserAuditEntry, error)
+	Search(ctx context.Context, query string, limit int) ([]UserSearchHit, error)
+}
+
+// UserFilter задает условия выборки пользователей; пустые поля не фильтруют
+type UserFilter struct {
+	Username string // подстрока username без учета регистра
+	Email    string // подстрока email без учета регистра
+	// Statuses - допустимые статусы; пустой список скрывает пользователей,
+	// которых нет в списках по умолчанию (см. entity.UserStatus.IsListed)
+	Statuses []entity.UserStatus
+}
+
+// EventRecorder сохраняет доменное событие в рамках транзакции изменения пользователя
+type EventRecorder interface {
+	Record(tx *gorm.DB, event entity.UserEvent) error
+}
+
+// EventRecorders передает событие всем регистраторам по очереди (например, outbox и аудиту)
+type EventRecorders []EventRecorder
+
+func (r EventRecorders) Record(tx *gorm.DB, event entity.UserEvent) error {
+	for _, recorder := range r {
+		if err := recorder.Record(tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type UserRepository struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Events EventRecorder // если задан, изменения сопровождаются событиями в той же транзакции
+
+	// RowLevelSecurity включает режим Postgres, в котором каждая операция идет в
+	// транзакции с тенантом в переменной tenancy.SessionSetting, а изоляцию
+	// дополнительно обеспечивают политики migrations.ApplyRowLevelSecurity
+	RowLevelSecurity bool
+
+	// EmailPolicy - политика, по которой вычисляется users.email_key; nil - по
+	// умолчанию. Должна совпадать с примененной к базе (migrations.ApplyEmailPolicy)
+	EmailPolicy *entity.EmailCanonicalization
+}
+
+// EmailKey возвращает ключ уникальности email по политике репозитория
+func (r *UserRepository) EmailKey(email string) string {
+	return entity.CanonicalEmail(email, r.EmailPolicy)
+}
+
+// setEmailKeys пересчитывает email_key перед записью: BeforeSave не знает политики
+func (r *UserRepository) setEmailKeys(users ...*entity.User) {
+	for _, user := range users {
+		user.EmailKey = r.EmailKey(user.Email)
+	}
 }
 
 // NewUserRepository - конструктор для UserRepository
@@ -22,16 +91,290 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{DB: db}
 }
 
-func (r *UserRepository) Update(user *entity.User) error {
-	return r.DB.Save(user).Error
+// Update сохраняет пользователя; возвращает gorm.ErrRecordNotFound, если записи
+// нет в тенанте из ctx
+func (r *UserRepository) Update(ctx context.Context, user *entity.User) error {
+	return translateError(r.DB, r.transaction(ctx, func(tx *gorm.DB) error {
+		// Предыдущее состояние нужно для diff в журнале аудита
+		var previous entity.User
+		if err := tx.Scopes(forTenant(ctx)).First(&previous, user.ID).Error; err != nil {
+			return err
+		}
+		// Пользователь не может быть перенесен в другой тенант
+		user.TenantID = previous.TenantID
+		r.setEmailKeys(user)
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return r.recordEvent(tx, entity.UserUpdated, user, &previous)
+	}))
 }
 
-func (r *UserRepository) Create(user *entity.User) error {
-	return r.DB.Create(user).Error
+// Create сохраняет пользователя в тенанте из ctx
+func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	user.TenantID = tenantID
+	r.setEmailKeys(user)
+	return translateError(r.DB, r.transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return r.recordEvent(tx, entity.UserRegistered, user, nil)
+	}))
 }
 
-func (r *UserRepository) FindByID(id uint) (*entity.User, error) {
+func (r *UserRepository) FindByID(ctx context.Context, id uint) (*entity.User, error) {
 	var user entity.User
-	err := r.DB.First(&user, id).Error
+	err := r.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).First(&user, id).Error
+	})
 	return &user, err
 }
+
+// FindVersionAt восстанавливает пользователя по версии, действовавшей в момент at;
+// возвращает gorm.ErrRecordNotFound, если пользователь тогда не существовал
+func (r *UserRepository) FindVersionAt(ctx context.Context, id uint, at time.Time) (*entity.User, error) {
+	var version entity.UserVersion
+	err := r.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where("user_id = ? AND valid_from <= ?", id, at.UTC()).
+			Where("valid_to IS NULL OR valid_to > ?", at.UTC()).
+			Order("valid_from DESC, id DESC").
+			First(&version).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &version.Data, nil
+}
+
+// FindByIDs загружает пользователей одним запросом; отсутствующие ID пропускаются
+func (r *UserRepository) FindByIDs(ctx context.Context, ids []uint) ([]entity.User, error) {
+	var users []entity.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where("id IN ?", ids).Find(&users).Error
+	})
+	return users, err
+}
+
+// FindByUsername ищет пользователя по username без учета регистра и
+// неотличимых на вид символов (по entity.CanonicalUsername)
+func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*entity.User, error) {
+	var user entity.User
+	err := r.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where("username_key = ?", entity.CanonicalUsername(username)).Take(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByEmail ищет пользователя по ключу email (см. EmailKey)
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
+	var user entity.User
+	err := r.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where("email_key = ?", r.EmailKey(email)).Take(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByUsernamesOrEmails загружает одним запросом пользователей, у которых
+// username или email совпадает с одним из переданных (как в FindByUsername и
+// FindByEmail); ненайденные значения пропускаются
+func (r *UserRepository) FindByUsernamesOrEmails(ctx context.Context, usernames, emails []string) ([]entity.User, error) {
+	var users []entity.User
+	usernameKeys := make([]string, len(usernames))
+	for i, username := range usernames {
+		usernameKeys[i] = entity.CanonicalUsername(username)
+	}
+	emailKeys := make([]string, len(emails))
+	for i, email := range emails {
+		emailKeys[i] = r.EmailKey(email)
+	}
+
+	var conditions []string
+	var args []any
+	if len(usernameKeys) > 0 {
+		conditions = append(conditions, "username_key IN ?")
+		args = append(args, usernameKeys)
+	}
+	if len(emailKeys) > 0 {
+		conditions = append(conditions, "email_key IN ?")
+		args = append(args, emailKeys)
+	}
+	if len(conditions) == 0 {
+		return users, nil
+	}
+	err := r.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where(strings.Join(conditions, " OR "), args...).Order("id").Find(&users).Error
+	})
+	return users, err
+}
+
+// Delete удаляет пользователя, возвращает gorm.ErrRecordNotFound если его нет
+func (r *UserRepository) Delete(ctx context.Context, id uint) error {
+	return translateError(r.DB, r.transaction(ctx, func(tx *gorm.DB) error {
+		// Последнее состояние нужно для события UserDeleted
+		var user entity.User
+		if err := tx.Scopes(forTenant(ctx)).First(&user, id).Error; err != nil {
+			return err
+		}
+		result := tx.Scopes(forTenant(ctx)).Delete(&entity.User{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return r.recordEvent(tx, entity.UserDeleted, &user, nil)
+	}))
+}
+
+// List возвращает до limit пользователей с ID больше afterID в порядке возрастания ID
+func (r *UserRepository) List(ctx context.Context, filter UserFilter, afterID uint, limit int) ([]entity.User, error) {
+	var users []entity.User
+	err := r.read(ctx, func(db *gorm.DB) error {
+		query := db.Scopes(forTenant(ctx)).Where("id > ?", afterID)
+		if filter.Username != "" {
+			query = query.Where("LOWER(username) LIKE ? ESCAPE '\\'", containsPattern(filter.Username))
+		}
+		if filter.Email != "" {
+			query = query.Where("LOWER(email) LIKE ? ESCAPE '\\'", containsPattern(filter.Email))
+		}
+		if len(filter.Statuses) > 0 {
+			query = query.Where("status IN ?", filter.Statuses)
+		} else {
+			query = query.Scopes(listedUsers)
+		}
+		return query.Order("id").Limit(limit).Find(&users).Error
+	})
+	return users, err
+}
+
+// listedUsers скрывает пользователей, которых нет в списках по умолчанию
+func listedUsers(db *gorm.DB) *gorm.DB {
+	return db.Where("users.status NOT IN ?", entity.UnlistedUserStatuses())
+}
+
+// CreateBatch вставляет пользователей в одной транзакции. Ошибка отдельной строки
+// (например, gorm.ErrDuplicatedKey) откатывается до точки сохранения и попадает
+// в срез результатов, не прерывая остальные вставки; второе значение - ошибка транзакции
+func (r *UserRepository) CreateBatch(ctx context.Context, users []*entity.User) ([]error, error) {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return nil, tenancy.ErrNoTenant
+	}
+	rowErrs := make([]error, len(users))
+	for _, user := range users {
+		user.TenantID = tenantID
+	}
+	r.setEmailKeys(users...)
+	err := r.transaction(ctx, func(tx *gorm.DB) error {
+		for i, user := range users {
+			if err := tx.SavePoint("batch_row").Error; err != nil {
+				return err
+			}
+			if err := tx.Create(user).Error; err != nil {
+				if rollbackErr := tx.RollbackTo("batch_row").Error; rollbackErr != nil {
+					return rollbackErr
+				}
+				user.ID = 0
+				rowErrs[i] = translateError(r.DB, err)
+				continue
+			}
+			if err := r.recordEvent(tx, entity.UserRegistered, user, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rowErrs, nil
+}
+
+// FindInBatches обходит всех пользователей по возрастанию ID порциями по batchSize,
+// не загружая таблицу в память целиком
+func (r *UserRepository) FindInBatches(ctx context.Context, batchSize int, fn func(users []entity.User) error) error {
+	var users []entity.User
+	return r.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).FindInBatches(&users, batchSize, func(_ *gorm.DB, _ int) error {
+			return fn(users)
+		}).Error
+	})
+}
+
+// ListHistory возвращает журнал изменений пользователя, новые записи первыми;
+// beforeID > 0 продолжает выборку с записей старше указанной
+func (r *UserRepository) ListHistory(ctx context.Context, userID, beforeID uint, limit int) ([]entity.UserAuditEntry, error) {
+	var entries []entity.UserAuditEntry
+	err := r.read(ctx, func(db *gorm.DB) error {
+		query := db.Scopes(forTenant(ctx)).Where("user_id = ?", userID)
+		if beforeID > 0 {
+			query = query.Where("id < ?", beforeID)
+		}
+		return query.Order("id DESC").Limit(limit).Find(&entries).Error
+	})
+	return entries, err
+}
+
+// transaction выполняет fn в транзакции с тенантом из ctx (см. tenantTransaction)
+func (r *UserRepository) transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return tenantTransaction(ctx, r.DB, r.RowLevelSecurity, fn)
+}
+
+// read выполняет чтение; без RowLevelSecurity транзакция для него не нужна
+func (r *UserRepository) read(ctx context.Context, fn func(db *gorm.DB) error) error {
+	return tenantRead(ctx, r.DB, r.RowLevelSecurity, fn)
+}
+
+// forTenant ограничивает запрос строками тенанта из ctx; у всех таблиц,
+// к которым обращается репозиторий, есть колонка tenant_id. Без тенанта в ctx
+// запрос завершается ошибкой tenancy.ErrNoTenant
+func forTenant(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	tenantID, ok := tenancy.FromContext(ctx)
+	return func(db *gorm.DB) *gorm.DB {
+		if !ok {
+			db.AddError(tenancy.ErrNoTenant)
+			return db
+		}
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
+
+// containsPattern строит LIKE-шаблон подстроки с экранированием спецсимволов
+func containsPattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(value))
+	return "%" + escaped + "%"
+}
+
+// recordEvent сохраняет событие изменения в транзакции tx; previous - состояние до изменения
+func (r *UserRepository) recordEvent(tx *gorm.DB, eventType entity.UserEventType, user, previous *entity.User) error {
+	if r.Events == nil {
+		return nil
+	}
+	event := entity.NewUserEvent(eventType, user)
+	event.Previous = previous
+	return r.Events.Record(tx, event)
+}
+
+// translateError приводит ошибки драйвера к ошибкам gorm (например, gorm.ErrDuplicatedKey)
+func translateError(db *gorm.DB, err error) error {
+	if err == nil {
+		return nil
+	}
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		return translator.Translate(err)
+	}
+	return err
+}
@@ -1,9 +1,14 @@
 package repository_test
 
 import (
+	"context"
+	"multilayer/internal/audit"
 	"multilayer/internal/entity"
+	"multilayer/internal/outbox"
 	"multilayer/internal/repository"
+	"multilayer/internal/tenancy"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
@@ -22,6 +27,11 @@ func setupTestDB() *gorm.DB {
 	return db
 }
 
+// tenantContext - контекст тенанта по умолчанию, как его задает ResolveTenant
+func tenantContext() context.Context {
+	return tenancy.WithTenant(context.Background(), entity.DefaultTenantID)
+}
+
 func TestUserRepository_Create(t *testing.T) {
 	db := setupTestDB()
 	repo := repository.NewUserRepository(db)
@@ -31,24 +41,39 @@ func TestUserRepository_Create(t *testing.T) {
 		Email:    "test@example.com",
 	}
 
-	err := repo.Create(user)
+	err := repo.Create(tenantContext(), user)
 
 	assert.NoError(t, err)
 	assert.NotZero(t, user.ID)
 }
 
+func TestUserRepository_RequiresTenant(t *testing.T) {
+	db := setupTestDB()
+	repo := repository.NewUserRepository(db)
+	user := &entity.User{Username: "tenantless", Email: "tenantless@example.com"}
+	assert.NoError(t, repo.Create(tenantContext(), user))
+
+	// Без тенанта в контексте запросы не выполняются, а не попадают в тенант по умолчанию
+	assert.ErrorIs(t, repo.Create(context.Background(), &entity.User{Username: "nobody", Email: "nobody@example.com"}), tenancy.ErrNoTenant)
+	_, err := repo.FindByID(context.Background(), user.ID)
+	assert.ErrorIs(t, err, tenancy.ErrNoTenant)
+	_, err = repo.List(context.Background(), repository.UserFilter{}, 0, 10)
+	assert.ErrorIs(t, err, tenancy.ErrNoTenant)
+	assert.ErrorIs(t, repo.Delete(context.Background(), user.ID), tenancy.ErrNoTenant)
+}
+
 func TestUserRepository_Update(t *testing.T) {
 	db := setupTestDB()
 	repo := repository.NewUserRepository(db)
 
 	// Создаём пользователя для теста
 	user := &entity.User{Username: "old", Email: "old@example.com"}
-	err := repo.Create(user)
+	err := repo.Create(tenantContext(), user)
 	assert.NoError(t, err)
 
 	// Обновляем
 	user.Username = "new"
-	err = repo.Update(user)
+	err = repo.Update(tenantContext(), user)
 
 	assert.NoError(t, err)
 
@@ -57,3 +82,237 @@ func TestUserRepository_Update(t *testing.T) {
 	db.First(&updatedUser, user.ID)
 	assert.Equal(t, "new", updatedUser.Username)
 }
+
+func TestUserRepository_Delete(t *testing.T) {
+	db := setupTestDB()
+	repo := repository.NewUserRepository(db)
+
+	user := &entity.User{Username: "todelete", Email: "todelete@example.com"}
+	assert.NoError(t, repo.Create(tenantContext(), user))
+
+	assert.NoError(t, repo.Delete(tenantContext(), user.ID))
+
+	// Повторное удаление сообщает об отсутствии записи
+	assert.ErrorIs(t, repo.Delete(tenantContext(), user.ID), gorm.ErrRecordNotFound)
+}
+
+func TestUserRepository_List(t *testing.T) {
+	db := setupTestDB()
+	repo := repository.NewUserRepository(db)
+
+	first := &entity.User{Username: "listuser1", Email: "listuser1@example.com"}
+	second := &entity.User{Username: "listuser2", Email: "listuser2@example.com"}
+	assert.NoError(t, repo.Create(tenantContext(), first))
+	assert.NoError(t, repo.Create(tenantContext(), second))
+
+	users, err := repo.List(tenantContext(), repository.UserFilter{}, first.ID, 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, second.ID, users[0].ID)
+
+	// Фильтр по подстроке не зависит от регистра
+	users, err = repo.List(tenantContext(), repository.UserFilter{Email: "LISTUSER1@"}, 0, 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, first.ID, users[0].ID)
+}
+
+func TestUserRepository_Create_Duplicate(t *testing.T) {
+	db := setupTestDB()
+	repo := repository.NewUserRepository(db)
+
+	assert.NoError(t, repo.Create(tenantContext(), &entity.User{Username: "dupuser", Email: "dupuser@example.com"}))
+
+	err := repo.Create(tenantContext(), &entity.User{Username: "dupuser", Email: "other@example.com"})
+
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+func TestUserRepository_EmailPolicy(t *testing.T) {
+	db := setupTestDB()
+	repo := repository.NewUserRepository(db)
+	repo.EmailPolicy = &entity.EmailCanonicalization{IgnorePlusTag: true}
+
+	user := &entity.User{Username: "policyuser", Email: "policyuser+a@example.com"}
+	assert.NoError(t, repo.Create(tenantContext(), user))
+	assert.Equal(t, "policyuser@example.com", user.EmailKey)
+
+	found, err := repo.FindByEmail(tenantContext(), "PolicyUser+b@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	err = repo.Create(tenantContext(), &entity.User{Username: "policyuser2", Email: "policyuser+c@example.com"})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+func TestUserRepository_CreateBatch(t *testing.T) {
+	db := setupTestDB()
+	repo := repository.NewUserRepository(db)
+
+	users := []*entity.User{
+		{Username: "batchuser1", Email: "batchuser1@example.com"},
+		{Username: "batchuser1", Email: "batchuser1-dup@example.com"},
+		{Username: "batchuser2", Email: "batchuser2@example.com"},
+	}
+
+	rowErrs, err := repo.CreateBatch(tenantContext(), users)
+
+	assert.NoError(t, err)
+	assert.NoError(t, rowErrs[0])
+	assert.ErrorIs(t, rowErrs[1], gorm.ErrDuplicatedKey)
+	assert.NoError(t, rowErrs[2])
+	assert.NotZero(t, users[2].ID)
+	assert.Zero(t, users[1].ID)
+
+	// Ошибка одной строки не откатывает остальные
+	var count int64
+	db.Model(&entity.User{}).Where("username LIKE ?", "batchuser%").Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestUserRepository_FindInBatches(t *testing.T) {
+	db := setupTestDB()
+	repo := repository.NewUserRepository(db)
+
+	assert.NoError(t, repo.Create(tenantContext(), &entity.User{Username: "eachuser1", Email: "eachuser1@example.com"}))
+	assert.NoError(t, repo.Create(tenantContext(), &entity.User{Username: "eachuser2", Email: "eachuser2@example.com"}))
+
+	var total int64
+	db.Model(&entity.User{}).Count(&total)
+
+	seen := 0
+	err := repo.FindInBatches(tenantContext(), 1, func(users []entity.User) error {
+		assert.Len(t, users, 1)
+		seen += len(users)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int(total), seen)
+}
+
+func TestUserRepository_RecordsEvents(t *testing.T) {
+	db := setupTestDB()
+	assert.NoError(t, db.AutoMigrate(&outbox.Message{}))
+	repo := repository.NewUserRepository(db)
+	repo.Events = outbox.NewStore()
+
+	countEvents := func(user *entity.User, eventType entity.UserEventType) int64 {
+		var count int64
+		db.Model(&outbox.Message{}).
+			Where("aggregate_id = ? AND event_type = ?", user.ID, string(eventType)).
+			Count(&count)
+		return count
+	}
+
+	user := &entity.User{Username: "eventuser", Email: "eventuser@example.com"}
+	assert.NoError(t, repo.Create(tenantContext(), user))
+	assert.Equal(t, int64(1), countEvents(user, entity.UserRegistered))
+
+	user.Email = "eventuser2@example.com"
+	assert.NoError(t, repo.Update(tenantContext(), user))
+	assert.Equal(t, int64(1), countEvents(user, entity.UserUpdated))
+
+	// Неудачная запись не оставляет события в outbox
+	duplicate := &entity.User{Username: "eventuser", Email: "other-event@example.com"}
+	assert.ErrorIs(t, repo.Create(tenantContext(), duplicate), gorm.ErrDuplicatedKey)
+	var total int64
+	db.Model(&outbox.Message{}).Where("payload LIKE ?", "%other-event%").Count(&total)
+	assert.Zero(t, total)
+
+	assert.NoError(t, repo.Delete(tenantContext(), user.ID))
+	assert.Equal(t, int64(1), countEvents(user, entity.UserDeleted))
+}
+
+func TestUserRepository_RecordsAuditTrail(t *testing.T) {
+	db := setupTestDB()
+	assert.NoError(t, db.AutoMigrate(&entity.UserAuditEntry{}))
+	repo := repository.NewUserRepository(db)
+	repo.Events = repository.EventRecorders{audit.NewRecorder()}
+
+	ctx := audit.WithMetadata(tenantContext(), audit.Metadata{Actor: "admin", RequestID: "req-1"})
+	user := &entity.User{Username: "audituser", Email: "audituser@example.com"}
+	assert.NoError(t, repo.Create(ctx, user))
+
+	user.Email = "audituser2@example.com"
+	assert.NoError(t, repo.Update(ctx, user))
+	// Сохранение без изменений не попадает в журнал
+	assert.NoError(t, repo.Update(ctx, user))
+	assert.NoError(t, repo.Delete(tenantContext(), user.ID))
+
+	entries, err := repo.ListHistory(tenantContext(), user.ID, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		deleted, updated, created := entries[0], entries[1], entries[2]
+		assert.Equal(t, entity.UserDeleted, deleted.Action)
+		assert.Equal(t, audit.SystemActor, deleted.Actor)
+
+		assert.Equal(t, entity.UserUpdated, updated.Action)
+		assert.Equal(t, "admin", updated.Actor)
+		assert.Equal(t, "req-1", updated.RequestID)
+		assert.Equal(t, []entity.FieldChange{
+			{Field: "email", Before: "audituser@example.com", After: "audituser2@example.com"},
+		}, updated.Changes)
+
+		assert.Equal(t, entity.UserRegistered, created.Action)
+		assert.Len(t, created.Changes, 3) // username, email и статус
+	}
+
+	page, err := repo.ListHistory(tenantContext(), user.ID, entries[1].ID, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, page, 1) {
+		assert.Equal(t, entity.UserRegistered, page[0].Action)
+	}
+}
+
+func TestUserRepository_FindVersionAt(t *testing.T) {
+	db := setupTestDB()
+	assert.NoError(t, db.AutoMigrate(&entity.UserVersion{}))
+	repo := repository.NewUserRepository(db)
+	repo.Events = audit.NewVersionRecorder()
+
+	user := &entity.User{Username: "versionuser", Email: "versionuser@example.com"}
+	beforeCreate := time.Now()
+	assert.NoError(t, repo.Create(tenantContext(), user))
+	afterCreate := time.Now()
+
+	user.Email = "versionuser2@example.com"
+	assert.NoError(t, repo.Update(tenantContext(), user))
+	afterUpdate := time.Now()
+
+	assert.NoError(t, repo.Delete(tenantContext(), user.ID))
+	afterDelete := time.Now()
+
+	_, err := repo.FindVersionAt(tenantContext(), user.ID, beforeCreate)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	version, err := repo.FindVersionAt(tenantContext(), user.ID, afterCreate)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "versionuser@example.com", version.Email)
+	}
+
+	// Момент передается в любой временной зоне
+	version, err = repo.FindVersionAt(tenantContext(), user.ID, afterUpdate.In(time.FixedZone("UTC+3", 3*60*60)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "versionuser2@example.com", version.Email)
+		assert.Equal(t, user.ID, version.ID)
+	}
+
+	_, err = repo.FindVersionAt(tenantContext(), user.ID, afterDelete)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUserRepository_Create_CanonicalDuplicate(t *testing.T) {
+	db := setupTestDB()
+	repo := repository.NewUserRepository(db)
+
+	assert.NoError(t, repo.Create(tenantContext(), &entity.User{Username: "CanonUser", Email: "Canon@Example.com"}))
+
+	err := repo.Create(tenantContext(), &entity.User{Username: "canonuser", Email: "canon-other@example.com"})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+	err = repo.Create(tenantContext(), &entity.User{Username: "canonother", Email: "canon@EXAMPLE.com"})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/search"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// UserSearchFTSTable - таблица полнотекстового индекса пользователей в SQLite
+// (FTS5 с токенизатором trigram). Создается миграцией, если драйвер собран с FTS5
+const UserSearchFTSTable = "users_fts"
+
+// UserSearchDocument - выражение Postgres, по которому построены индексы поиска
+const UserSearchDocument = "lower(username || ' ' || coalesce(display_name, '') || ' ' || email)"
+
+// UserSearchHit - найденный пользователь и релевантность от 0 до 1
+type UserSearchHit struct {
+	User  entity.User
+	Score float64
+}
+
+// userSearcher - реализация поиска для конкретной СУБД; db уже ограничен тенантом
+type userSearcher interface {
+	search(db *gorm.DB, query string, limit int) ([]UserSearchHit, error)
+}
+
+// Search ищет пользователей тенанта из ctx по username, display_name и email
+// с учетом частичных совпадений и опечаток; результаты упорядочены по убыванию
+// релевантности. Пользователи, скрытые из списков по умолчанию, не находятся.
+// Реализация выбирается по СУБД: pg_trgm и tsvector в Postgres, FTS5 в SQLite,
+// а без них - перебор с оценкой в Go
+func (r *UserRepository) Search(ctx context.Context, query string, limit int) ([]UserSearchHit, error) {
+	var hits []UserSearchHit
+	err := r.read(ctx, func(db *gorm.DB) error {
+		var err error
+		hits, err = r.searcher(db).search(db.Scopes(forTenant(ctx), listedUsers), query, limit)
+		return err
+	})
+	return hits, err
+}
+
+func (r *UserRepository) searcher(db *gorm.DB) userSearcher {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return postgresUserSearch{}
+	case "sqlite":
+		if db.Migrator().HasTable(UserSearchFTSTable) {
+			return sqliteUserSearch{}
+		}
+	}
+	return scanUserSearch{}
+}
+
+// postgresUserSearch ранжирует по word_similarity из pg_trgm (частичные
+// совпадения и опечатки) с добавкой ts_rank за совпадение целых слов
+type postgresUserSearch struct{}
+
+func (postgresUserSearch) search(db *gorm.DB, query string, limit int) ([]UserSearchHit, error) {
+	query = strings.ToLower(query)
+	var rows []struct {
+		entity.User
+		Score float64
+	}
+	document := UserSearchDocument
+	err := db.Model(&entity.User{}).
+		Select(fmt.Sprintf("users.*, LEAST(1, word_similarity(?, %[1]s) + ts_rank(to_tsvector('simple', %[1]s), plainto_tsquery('simple', ?))) AS score", document), query, query).
+		Where(fmt.Sprintf("? <%% %[1]s OR to_tsvector('simple', %[1]s) @@ plainto_tsquery('simple', ?)", document), query, query).
+		Order("score DESC, id").
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]UserSearchHit, len(rows))
+	for i, row := range rows {
+		hits[i] = UserSearchHit{User: row.User, Score: row.Score}
+	}
+	return hits, nil
+}
+
+// sqliteUserSearch отбирает кандидатов по общим триграммам через FTS5 (bm25),
+// а окончательный порядок и порог задает оценка search.Score: bm25 по
+// триграммам не отличает опечатку от случайного совпадения. В отличие от
+// pg_trgm, FTS5 не дополняет слова пробелами, поэтому слово с опечаткой
+// найдется, только если у него осталась общая триграмма с исходным
+type sqliteUserSearch struct{}
+
+// sqliteCandidatesPerResult - во сколько раз кандидатов больше, чем нужно результатов
+const sqliteCandidatesPerResult = 5
+
+func (sqliteUserSearch) search(db *gorm.DB, query string, limit int) ([]UserSearchHit, error) {
+	match := ftsTrigramQuery(query)
+	if match == "" {
+		// Триграммный индекс не находит слова короче трех символов
+		return scanUserSearch{}.search(db, query, limit)
+	}
+	var candidates []entity.User
+	err := db.Model(&entity.User{}).
+		Joins(fmt.Sprintf("JOIN %[1]s ON %[1]s.rowid = users.id", UserSearchFTSTable)).
+		Where(UserSearchFTSTable+" MATCH ?", match).
+		Order("bm25(" + UserSearchFTSTable + ")").
+		Limit(limit * sqliteCandidatesPerResult).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	return rankUsers(candidates, query, limit), nil
+}
+
+// ftsTrigramQuery строит запрос FTS5, совпадающий с документами, у которых
+// есть хотя бы одна общая триграмма со словами запроса
+func ftsTrigramQuery(query string) string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, word := range search.Words(query) {
+		runes := []rune(word)
+		for i := 0; i+3 <= len(runes); i++ {
+			trigram := string(runes[i : i+3])
+			if !seen[trigram] {
+				seen[trigram] = true
+				terms = append(terms, `"`+trigram+`"`)
+			}
+		}
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// scanUserSearch перебирает всех пользователей тенанта и оценивает их в Go;
+// подходит для небольших баз и SQLite без FTS5
+type scanUserSearch struct{}
+
+// scanBatchSize - размер порции пользователей при переборе
+const scanBatchSize = 500
+
+func (scanUserSearch) search(db *gorm.DB, query string, limit int) ([]UserSearchHit, error) {
+	var hits []UserSearchHit
+	var users []entity.User
+	err := db.FindInBatches(&users, scanBatchSize, func(_ *gorm.DB, _ int) error {
+		hits = append(hits, rankUsers(users, query, limit)...)
+		// Держим в памяти не больше limit лучших результатов
+		sortHits(hits)
+		if len(hits) > limit {
+			hits = hits[:limit]
+		}
+		return nil
+	}).Error
+	return hits, err
+}
+
+// rankUsers оценивает пользователей, отбрасывает не прошедших порог
+// search.MinScore и возвращает limit лучших
+func rankUsers(users []entity.User, query string, limit int) []UserSearchHit {
+	var hits []UserSearchHit
+	for _, user := range users {
+		if score := search.Score(query, user.Username, user.DisplayName, user.Email); score >= search.MinScore {
+			hits = append(hits, UserSearchHit{User: user, Score: score})
+		}
+	}
+	sortHits(hits)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+func sortHits(hits []UserSearchHit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].User.ID < hits[j].User.ID
+	})
+}
@@ -0,0 +1,104 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/migrations"
+	"multilayer/internal/repository"
+	"multilayer/internal/tenancy"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupSearchRepository создает отдельную базу со всеми миграциями: при сборке
+// с тегом sqlite_fts5 поиск идет через FTS5, иначе - перебором
+func setupSearchRepository(t *testing.T) *repository.UserRepository {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}))
+	require.NoError(t, migrations.Run(db, migrations.All))
+
+	repo := repository.NewUserRepository(db)
+	users := []*entity.User{
+		{Username: "alice", Email: "alice@example.com", DisplayName: "Alice Smith"},
+		{Username: "jdoe", Email: "jdoe@example.com", DisplayName: "Jonathan Doe"},
+		{Username: "bob", Email: "bob@mail.test"},
+		{Username: "alicia", Email: "alicia@example.com"},
+	}
+	for _, user := range users {
+		require.NoError(t, repo.Create(tenantContext(), user))
+	}
+	return repo
+}
+
+func searchUsernames(t *testing.T, repo *repository.UserRepository, ctx context.Context, query string) []string {
+	hits, err := repo.Search(ctx, query, 10)
+	require.NoError(t, err)
+	usernames := make([]string, len(hits))
+	for i, hit := range hits {
+		usernames[i] = hit.User.Username
+	}
+	return usernames
+}
+
+func TestUserRepository_Search(t *testing.T) {
+	repo := setupSearchRepository(t)
+	ctx := tenantContext()
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"Exact match first", "alice", []string{"alice", "alicia"}},
+		{"Partial", "ali", []string{"alice", "alicia"}},
+		{"Typo in display name", "jonathon", []string{"jdoe"}},
+		{"Typo in surname", "smiht", []string{"alice"}},
+		{"Email domain", "mail.test", []string{"bob"}},
+		{"Short query", "bo", []string{"bob"}},
+		{"No match", "zzz", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, searchUsernames(t, repo, ctx, tt.query))
+		})
+	}
+
+	t.Run("Limit", func(t *testing.T) {
+		hits, err := repo.Search(ctx, "example", 2)
+		require.NoError(t, err)
+		assert.Len(t, hits, 2)
+	})
+
+	t.Run("Reflects updates and deletes", func(t *testing.T) {
+		user, err := repo.FindByID(ctx, 3)
+		require.NoError(t, err)
+		user.DisplayName = "Robert Paulson"
+		require.NoError(t, repo.Update(ctx, user))
+		assert.Equal(t, []string{"bob"}, searchUsernames(t, repo, ctx, "paulson"))
+
+		require.NoError(t, repo.Delete(ctx, user.ID))
+		assert.Empty(t, searchUsernames(t, repo, ctx, "paulson"))
+	})
+}
+
+func TestUserRepository_Search_TenantIsolation(t *testing.T) {
+	repo := setupSearchRepository(t)
+	other := tenancy.WithTenant(tenantContext(), 2)
+	require.NoError(t, repo.Create(other, &entity.User{Username: "alice", Email: "alice@other.test"}))
+
+	hits, err := repo.Search(other, "alice", 10)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "alice@other.test", hits[0].User.Email)
+
+	assert.Equal(t, []string{"alice", "alicia"}, searchUsernames(t, repo, tenantContext(), "alice"))
+}
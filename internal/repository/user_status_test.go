@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRepository_StatusVisibility(t *testing.T) {
+	_, userRepo, _ := setupTxManager(t)
+	ctx := tenantContext()
+	users := []*entity.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+		{Username: "carol", Email: "carol@example.com"},
+		{Username: "dave", Email: "dave@example.com"},
+	}
+	require.NoError(t, userRepo.CreateInBatches(ctx, users, 10))
+	assert.Equal(t, entity.UserActive, users[0].Status)
+
+	transitions := map[*entity.User]entity.UserStatus{
+		users[1]: entity.UserSuspended,
+		users[2]: entity.UserDeactivated,
+		users[3]: entity.UserLocked,
+	}
+	for user, status := range transitions {
+		require.NoError(t, user.TransitionTo(status, "test", time.Now()))
+		require.NoError(t, userRepo.Update(ctx, user))
+	}
+
+	usernames := func(users []entity.User) []string {
+		names := make([]string, len(users))
+		for i, user := range users {
+			names[i] = user.Username
+		}
+		return names
+	}
+
+	listed, err := userRepo.List(ctx, repository.UserFilter{}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "dave"}, usernames(listed), "suspended and deactivated users are hidden by default")
+
+	listed, err = userRepo.List(ctx, repository.UserFilter{Statuses: []entity.UserStatus{entity.UserSuspended, entity.UserDeactivated}}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob", "carol"}, usernames(listed))
+
+	hits, err := userRepo.Search(ctx, "bob", 10)
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	// Поиск по ID и идентификаторам видит всех: имя заблокированного пользователя остается занятым
+	found, err := userRepo.FindByUsername(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserSuspended, found.Status)
+	assert.Equal(t, "test", found.StatusReason)
+	assert.NotNil(t, found.StatusChangedAt)
+
+	t.Run("Writes keep status", func(t *testing.T) {
+		_, err := userRepo.UpsertByEmail(ctx, &entity.User{Username: "bob", Email: "bob@example.com", DisplayName: "Bob"})
+		require.NoError(t, err)
+
+		carol := *users[2]
+		carol.Status = entity.UserActive
+		carol.DisplayName = "Carol"
+		require.NoError(t, userRepo.UpdateBatch(ctx, []*entity.User{&carol}))
+		assert.Equal(t, entity.UserDeactivated, carol.Status)
+
+		found, err := userRepo.FindByIDs(ctx, []uint{users[1].ID, users[2].ID})
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		assert.Equal(t, "Bob", found[0].DisplayName)
+		assert.Equal(t, entity.UserSuspended, found[0].Status)
+		assert.Equal(t, "Carol", found[1].DisplayName)
+		assert.Equal(t, entity.UserDeactivated, found[1].Status)
+	})
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// upsertUserSQL вставляет пользователя или обновляет найденного по уникальному
+// индексу (tenant_id, email_key). created_at и статус существующей строки не меняются
+const upsertUserSQL = `INSERT INTO users
+	(tenant_id, username, email, username_key, email_key, display_name, locale, timezone, avatar_url, status, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (tenant_id, email_key) DO UPDATE SET
+	username = excluded.username,
+	email = excluded.email,
+	username_key = excluded.username_key,
+	display_name = excluded.display_name,
+	locale = excluded.locale,
+	timezone = excluded.timezone,
+	avatar_url = excluded.avatar_url,
+	updated_at = excluded.updated_at
+`
+
+// upsertReturning - как узнать, вставлена ли строка: Postgres сообщает это
+// через xmax (0 у строки, созданной текущей транзакцией), а в SQLite вставленная
+// строка возвращает переданный created_at, обновленная - прежний
+var upsertReturning = map[string]string{
+	"postgres": "RETURNING id, created_at, (xmax = 0) AS inserted",
+	"sqlite":   "RETURNING id, created_at, false AS inserted",
+}
+
+// UpsertByEmail создает пользователя в тенанте из ctx или обновляет пользователя
+// с тем же email (без учета регистра) одним запросом INSERT ... ON CONFLICT.
+// Возвращает true, если пользователь создан. Конфликт по username дает
+// gorm.ErrDuplicatedKey
+func (r *UserRepository) UpsertByEmail(ctx context.Context, user *entity.User) (bool, error) {
+	returning, ok := upsertReturning[r.DB.Dialector.Name()]
+	if !ok {
+		return false, fmt.Errorf("upsert is not supported for %s", r.DB.Dialector.Name())
+	}
+
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return false, tenancy.ErrNoTenant
+	}
+	user.TenantID = tenantID
+	user.UsernameKey = entity.CanonicalUsername(user.Username)
+	user.EmailKey = r.EmailKey(user.Email)
+	user.Status = user.EffectiveStatus()
+	now := time.Now().UTC()
+	user.CreatedAt, user.UpdatedAt = now, now
+
+	var created bool
+	err := r.transaction(ctx, func(tx *gorm.DB) error {
+		// Предыдущее состояние нужно для diff в журнале аудита; если строку
+		// вставят параллельно после этого чтения, запрос обновит ее без diff
+		var previous *entity.User
+		var existing entity.User
+		err := tx.Scopes(forTenant(ctx)).Where("email_key = ?", user.EmailKey).Take(&existing).Error
+		switch {
+		case err == nil:
+			previous = &existing
+			user.Status, user.StatusReason, user.StatusChangedAt = existing.Status, existing.StatusReason, existing.StatusChangedAt
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		var row struct {
+			ID        uint
+			CreatedAt time.Time
+			Inserted  bool
+		}
+		err = tx.Raw(upsertUserSQL+returning,
+			user.TenantID, user.Username, user.Email, user.UsernameKey, user.EmailKey,
+			user.DisplayName, user.Locale, user.Timezone, user.AvatarURL, user.Status, user.CreatedAt, user.UpdatedAt,
+		).Scan(&row).Error
+		if err != nil {
+			return err
+		}
+		created = row.Inserted || row.CreatedAt.Equal(now)
+		user.ID = row.ID
+		user.CreatedAt = row.CreatedAt
+
+		if created {
+			return r.recordEvent(tx, entity.UserRegistered, user, nil)
+		}
+		return r.recordEvent(tx, entity.UserUpdated, user, previous)
+	})
+	return created, translateError(r.DB, err)
+}
@@ -0,0 +1,75 @@
+package repository_test
+
+import (
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUserRepository_UpsertByEmail(t *testing.T) {
+	_, userRepo, db := setupTxManager(t)
+	ctx := tenantContext()
+
+	user := &entity.User{Username: "alice", Email: "alice@example.com", Locale: "en", Timezone: "UTC"}
+	created, err := userRepo.UpsertByEmail(ctx, user)
+	require.NoError(t, err)
+	assert.True(t, created)
+	require.NotZero(t, user.ID)
+	createdAt := user.CreatedAt
+
+	// Email сравнивается без учета регистра; created_at сохраняется
+	update := &entity.User{Username: "Alice", Email: "ALICE@example.com", DisplayName: "Alice", Locale: "de", Timezone: "UTC"}
+	created, err = userRepo.UpsertByEmail(ctx, update)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, user.ID, update.ID)
+	assert.True(t, createdAt.Equal(update.CreatedAt))
+	assert.Equal(t, int64(1), countRows(t, db, &entity.User{}))
+
+	found, err := userRepo.FindByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Username)
+	assert.Equal(t, "alice", found.UsernameKey)
+	assert.Equal(t, "de", found.Locale)
+	assert.Equal(t, "Alice", found.DisplayName)
+
+	history, err := userRepo.ListHistory(ctx, user.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, entity.UserUpdated, history[0].Action)
+	assert.NotEmpty(t, history[0].Changes)
+	assert.Equal(t, entity.UserRegistered, history[1].Action)
+
+	t.Run("Username conflict", func(t *testing.T) {
+		_, err := userRepo.UpsertByEmail(ctx, &entity.User{Username: "alice", Email: "other@example.com"})
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+		assert.Equal(t, int64(1), countRows(t, db, &entity.User{}))
+	})
+
+	t.Run("Tenants are separate", func(t *testing.T) {
+		other := &entity.User{Username: "alice", Email: "alice@example.com"}
+		created, err := userRepo.UpsertByEmail(tenancy.WithTenant(ctx, 2), other)
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.NotEqual(t, user.ID, other.ID)
+		assert.Equal(t, uint(2), other.TenantID)
+	})
+
+	t.Run("Unchanged user", func(t *testing.T) {
+		time.Sleep(time.Millisecond)
+		same := *update
+		same.ID = 0
+		created, err := userRepo.UpsertByEmail(ctx, &same)
+		require.NoError(t, err)
+		assert.False(t, created)
+
+		history, err := userRepo.ListHistory(ctx, user.ID, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, history, 2, "upsert without changes must not add audit entries")
+	})
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookRepositoryInterface определяет контракт хранилища подписок и доставок.
+// Подписки и доставки принадлежат тенанту из ctx; ClaimDueDeliveries забирает
+// доставки всех тенантов
+type WebhookRepositoryInterface interface {
+	CreateSubscription(ctx context.Context, subscription *entity.WebhookSubscription) error
+	FindSubscription(ctx context.Context, id uint) (*entity.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uint) error
+	CreateDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error
+	FindDelivery(ctx context.Context, subscriptionID, deliveryID uint) (*entity.WebhookDelivery, error)
+	ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]entity.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, subscriptionID, beforeID uint, limit int) ([]entity.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error
+}
+
+type WebhookRepository struct {
+	DB *gorm.DB
+	// RowLevelSecurity - см. UserRepository.RowLevelSecurity
+	RowLevelSecurity bool
+}
+
+// NewWebhookRepository - конструктор для WebhookRepository
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{DB: db}
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, subscription *entity.WebhookSubscription) error {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	subscription.TenantID = tenantID
+	return tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Create(subscription).Error
+	})
+}
+
+func (r *WebhookRepository) FindSubscription(ctx context.Context, id uint) (*entity.WebhookSubscription, error) {
+	var subscription entity.WebhookSubscription
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).First(&subscription, id).Error
+	})
+	return &subscription, err
+}
+
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	var subscriptions []entity.WebhookSubscription
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Order("id").Find(&subscriptions).Error
+	})
+	return subscriptions, err
+}
+
+// DeleteSubscription удаляет подписку вместе с журналом ее доставок
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id uint) error {
+	return tenantTransaction(ctx, r.DB, r.RowLevelSecurity, func(tx *gorm.DB) error {
+		result := tx.Scopes(forTenant(ctx)).Delete(&entity.WebhookSubscription{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("subscription_id = ?", id).Delete(&entity.WebhookDelivery{}).Error
+	})
+}
+
+// CreateDeliveries сохраняет доставки тенанта из ctx, пропуская уже существующие
+// пары (подписка, событие) - повторная публикация события из outbox не дублирует доставку
+func (r *WebhookRepository) CreateDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoTenant
+	}
+	for i := range deliveries {
+		deliveries[i].TenantID = tenantID
+	}
+	return tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+	})
+}
+
+func (r *WebhookRepository) FindDelivery(ctx context.Context, subscriptionID, deliveryID uint) (*entity.WebhookDelivery, error) {
+	var delivery entity.WebhookDelivery
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Where("subscription_id = ?", subscriptionID).First(&delivery, deliveryID).Error
+	})
+	return &delivery, err
+}
+
+// ClaimDueDeliveries забирает ожидающие доставки всех тенантов, время попытки
+// которых наступило, и переносит их следующую попытку на lease вперед, чтобы
+// другие воркеры их не взяли. На Postgres строки, заблокированные другим
+// воркером, пропускаются (SKIP LOCKED)
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]entity.WebhookDelivery, error) {
+	var deliveries []entity.WebhookDelivery
+	err := allTenantsTransaction(ctx, r.DB, r.RowLevelSecurity, func(tx *gorm.DB) error {
+		query := tx.Where("status = ? AND next_attempt_at <= ?", entity.DeliveryPending, now).
+			Order("next_attempt_at, id").
+			Limit(limit)
+		if tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+			deliveries[i].NextAttemptAt = now.Add(lease)
+		}
+		return tx.Model(&entity.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return deliveries, err
+}
+
+// ListDeliveries возвращает журнал доставок подписки, новые первыми;
+// beforeID > 0 продолжает выборку с доставок старше указанной
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID, beforeID uint, limit int) ([]entity.WebhookDelivery, error) {
+	var deliveries []entity.WebhookDelivery
+	err := tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		query := db.Scopes(forTenant(ctx)).Where("subscription_id = ?", subscriptionID)
+		if beforeID > 0 {
+			query = query.Where("id < ?", beforeID)
+		}
+		return query.Order("id DESC").Limit(limit).Find(&deliveries).Error
+	})
+	return deliveries, err
+}
+
+// UpdateDelivery сохраняет доставку тенанта из ctx
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	return tenantRead(ctx, r.DB, r.RowLevelSecurity, func(db *gorm.DB) error {
+		return db.Scopes(forTenant(ctx)).Save(delivery).Error
+	})
+}
@@ -0,0 +1,150 @@
+// (Нечеткое сопоставление текста без поддержки СУБД)
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// MinScore - порог релевантности, ниже которого запись не считается
+// совпадением; совпадает с порогом pg_trgm по умолчанию
+const MinScore = 0.3
+
+// Words разбивает текст на слова в нижнем регистре: последовательности букв и
+// цифр, так что "Alice.Smith@example.com" дает alice, smith, example, com
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// trigrams возвращает множество триграмм слова, дополненного как в pg_trgm:
+// два пробела в начале и один в конце
+func trigrams(word string) map[string]bool {
+	runes := []rune("  " + word + " ")
+	set := make(map[string]bool, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// Similarity - доля общих триграмм двух слов от 0 до 1; устойчива к опечаткам
+func Similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	left, right := trigrams(a), trigrams(b)
+	common := 0
+	for trigram := range left {
+		if right[trigram] {
+			common++
+		}
+	}
+	union := len(left) + len(right) - common
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
+// wordScore оценивает совпадение слова запроса с текстом: вхождение как
+// подстроки дает 1, иначе - лучшее сходство со словами текста
+func wordScore(queryWord string, text string, textWords []string) float64 {
+	if strings.Contains(text, queryWord) {
+		return 1
+	}
+	best := 0.0
+	for _, word := range textWords {
+		if similarity := Similarity(queryWord, word); similarity > best {
+			best = similarity
+		}
+	}
+	return best
+}
+
+// Score оценивает релевантность полей записи запросу от 0 до 1: среднее по
+// словам запроса от лучшего совпадения слова в любом из полей
+func Score(query string, fields ...string) float64 {
+	queryWords := Words(query)
+	if len(queryWords) == 0 {
+		return 0
+	}
+	texts := make([]string, len(fields))
+	textWords := make([][]string, len(fields))
+	for i, field := range fields {
+		texts[i] = strings.ToLower(field)
+		textWords[i] = Words(field)
+	}
+
+	total := 0.0
+	for _, queryWord := range queryWords {
+		best := 0.0
+		for i := range fields {
+			if score := wordScore(queryWord, texts[i], textWords[i]); score > best {
+				best = score
+			}
+		}
+		total += best
+	}
+	return total / float64(len(queryWords))
+}
+
+// Highlight выделяет в тексте совпадения с запросом тегами <mark>: вхождения
+// слов запроса и слова текста, похожие на них не меньше чем на MinScore.
+// Остальной текст экранируется для HTML. ok = false, если выделять нечего
+func Highlight(text, query string) (highlighted string, ok bool) {
+	queryWords := Words(query)
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Смена регистра изменила число символов: позиции не сопоставить
+		lower = runes
+	}
+	marked := make([]bool, len(runes))
+
+	for start := 0; start < len(runes); {
+		if isSeparator(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && !isSeparator(runes[end]) {
+			end++
+		}
+		word := string(lower[start:end])
+		for _, queryWord := range queryWords {
+			if offset := strings.Index(word, queryWord); offset >= 0 {
+				from := start + len([]rune(word[:offset]))
+				for i := from; i < from+len([]rune(queryWord)); i++ {
+					marked[i] = true
+				}
+			} else if Similarity(queryWord, word) >= MinScore {
+				for i := start; i < end; i++ {
+					marked[i] = true
+				}
+			}
+		}
+		start = end
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			ok = true
+			builder.WriteString("<mark>" + segment + "</mark>")
+		} else {
+			builder.WriteString(segment)
+		}
+		i = j
+	}
+	return builder.String(), ok
+}
@@ -0,0 +1,71 @@
+package search_test
+
+import (
+	"multilayer/internal/search"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWords(t *testing.T) {
+	assert.Equal(t, []string{"alice", "smith", "example", "com"}, search.Words("Alice.Smith@example.com"))
+	assert.Empty(t, search.Words(" -_ "))
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, search.Similarity("smith", "smith"))
+	assert.GreaterOrEqual(t, search.Similarity("smyth", "smith"), search.MinScore)
+	assert.GreaterOrEqual(t, search.Similarity("jonathon", "jonathan"), search.MinScore)
+	assert.Less(t, search.Similarity("bob", "smith"), search.MinScore)
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		fields []string
+		match  bool
+	}{
+		{"Exact username", "alice", []string{"alice", "", "alice@example.com"}, true},
+		{"Prefix", "ali", []string{"alice", "", "alice@example.com"}, true},
+		{"Display name typo", "jonathon", []string{"jdoe", "Jonathan Doe", "jdoe@example.com"}, true},
+		{"Several words", "john smyth", []string{"jsmith", "John Smith", "js@example.com"}, true},
+		{"Unrelated", "bob", []string{"alice", "Alice Smith", "alice@example.com"}, false},
+		{"Empty query", " ", []string{"alice"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := search.Score(tt.query, tt.fields...)
+			assert.Equal(t, tt.match, score >= search.MinScore, "score %v", score)
+			assert.LessOrEqual(t, score, 1.0)
+		})
+	}
+
+	// Точное совпадение релевантнее опечатки
+	assert.Greater(t, search.Score("smith", "John Smith"), search.Score("smyth", "John Smith"))
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		expected string
+		ok       bool
+	}{
+		{"Substring", "alice@example.com", "ali", "<mark>ali</mark>ce@example.com", true},
+		{"Keeps case", "Jonathan Doe", "doe", "Jonathan <mark>Doe</mark>", true},
+		{"Typo marks whole word", "Jonathan Doe", "jonathon", "<mark>Jonathan</mark> Doe", true},
+		{"Escapes HTML", "<b>bob</b>", "bob", "&lt;b&gt;<mark>bob</mark>&lt;/b&gt;", true},
+		{"No match", "alice", "bob", "alice", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			highlighted, ok := search.Highlight(tt.text, tt.query)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, highlighted)
+		})
+	}
+}
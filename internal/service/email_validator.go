@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/tenancy"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MXResolver - DNS-запросы для проверки доставляемости; *net.Resolver ему соответствует
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DefaultDisposableDomains - распространенные сервисы одноразовой почты
+var DefaultDisposableDomains = []string{
+	"10minutemail.com", "dispostable.com", "guerrillamail.com", "mailinator.com", "maildrop.cc",
+	"sharklasers.com", "temp-mail.org", "tempmail.com", "throwawaymail.com", "trashmail.com", "yopmail.com",
+}
+
+// EmailDomainSource возвращает списки доменов email тенанта (см. entity.Tenant)
+type EmailDomainSource interface {
+	EmailDomains(ctx context.Context, tenantID uint) (allowed, denied []string, err error)
+}
+
+// EmailValidator - проверки email сверх синтаксиса: списки доменов развертывания
+// и тенанта и, если задан Resolver, наличие почтового сервера у домена. Домены
+// сравниваются в punycode-форме, правило для домена действует и на его поддомены
+type EmailValidator struct {
+	Resolver       MXResolver    // nil отключает DNS-проверку
+	LookupTimeout  time.Duration // ограничение на DNS-запросы одного адреса
+	AllowedDomains []string      // если не пуст, принимаются только эти домены
+	DeniedDomains  []string
+	// TenantDomains - списки тенанта из ctx, действуют вместе с общими; nil отключает их
+	TenantDomains EmailDomainSource
+
+	disposable map[string]bool
+}
+
+// emailCheckCache запоминает в пределах одного вызова сервиса результаты
+// DNS-проверки по доменам и списки тенантов, чтобы пакет из сотен адресов
+// одного домена не повторял одни и те же запросы
+type emailCheckCache struct {
+	mu            sync.Mutex
+	deliverable   map[string]error
+	tenantDomains map[uint][2][]string
+}
+
+type emailCheckCacheKey struct{}
+
+// withEmailCheckCache включает кэш проверок для ctx; вложенный вызов использует внешний кэш
+func withEmailCheckCache(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(emailCheckCacheKey{}).(*emailCheckCache); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, emailCheckCacheKey{}, &emailCheckCache{
+		deliverable:   map[string]error{},
+		tenantDomains: map[uint][2][]string{},
+	})
+}
+
+// NewEmailValidator создает валидатор, отклоняющий DefaultDisposableDomains, без DNS-проверки
+func NewEmailValidator() *EmailValidator {
+	return (&EmailValidator{LookupTimeout: 3 * time.Second}).BlockDisposable(DefaultDisposableDomains...)
+}
+
+// BlockDisposable добавляет домены одноразовой почты
+func (v *EmailValidator) BlockDisposable(domains ...string) *EmailValidator {
+	if v.disposable == nil {
+		v.disposable = map[string]bool{}
+	}
+	for _, domain := range domains {
+		if ascii, ok := asciiDomain(domain); ok {
+			v.disposable[ascii] = true
+		}
+	}
+	return v
+}
+
+// AllowDisposable снимает блокировку одноразовой почты
+func (v *EmailValidator) AllowDisposable() *EmailValidator {
+	v.disposable = nil
+	return v
+}
+
+// Validate проверяет адрес; нарушения возвращаются как *entity.ValidationError.
+// Временные ошибки DNS адрес не отклоняют: недоступность резолвера не должна
+// блокировать регистрацию
+func (v *EmailValidator) Validate(ctx context.Context, email string) error {
+	_, domain, err := entity.ParseEmail(email)
+	if err != nil {
+		return err
+	}
+
+	if len(v.AllowedDomains) > 0 && !matchesDomain(domain, v.AllowedDomains) {
+		return &entity.ValidationError{Field: "email", Message: "email domain is not allowed"}
+	}
+	if matchesDomain(domain, v.DeniedDomains) {
+		return &entity.ValidationError{Field: "email", Message: "email domain is not allowed"}
+	}
+	if v.TenantDomains != nil {
+		allowed, denied, err := v.tenantDomains(ctx)
+		if err != nil {
+			return err
+		}
+		if len(allowed) > 0 && !matchesDomain(domain, allowed) || matchesDomain(domain, denied) {
+			return &entity.ValidationError{Field: "email", Message: "email domain is not allowed for this tenant"}
+		}
+	}
+	for parent := domain; parent != ""; parent = parentDomain(parent) {
+		if v.disposable[parent] {
+			return &entity.ValidationError{Field: "email", Message: "disposable email addresses are not allowed"}
+		}
+	}
+
+	if v.Resolver == nil {
+		return nil
+	}
+	cache, _ := ctx.Value(emailCheckCacheKey{}).(*emailCheckCache)
+	if cache != nil {
+		cache.mu.Lock()
+		err, ok := cache.deliverable[domain]
+		cache.mu.Unlock()
+		if ok {
+			return err
+		}
+	}
+	lookupCtx := ctx
+	if v.LookupTimeout > 0 {
+		var cancel context.CancelFunc
+		lookupCtx, cancel = context.WithTimeout(ctx, v.LookupTimeout)
+		defer cancel()
+	}
+	err = v.checkDeliverable(lookupCtx, domain)
+	if cache != nil {
+		cache.mu.Lock()
+		cache.deliverable[domain] = err
+		cache.mu.Unlock()
+	}
+	return err
+}
+
+// tenantDomains возвращает списки тенанта из ctx, по возможности из кэша вызова
+func (v *EmailValidator) tenantDomains(ctx context.Context) (allowed, denied []string, err error) {
+	tenantID, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return nil, nil, tenancy.ErrNoTenant
+	}
+	cache, _ := ctx.Value(emailCheckCacheKey{}).(*emailCheckCache)
+	if cache != nil {
+		cache.mu.Lock()
+		lists, ok := cache.tenantDomains[tenantID]
+		cache.mu.Unlock()
+		if ok {
+			return lists[0], lists[1], nil
+		}
+	}
+	allowed, denied, err = v.TenantDomains.EmailDomains(ctx, tenantID)
+	if err == nil && cache != nil {
+		cache.mu.Lock()
+		cache.tenantDomains[tenantID] = [2][]string{allowed, denied}
+		cache.mu.Unlock()
+	}
+	return allowed, denied, err
+}
+
+// checkDeliverable ищет MX-записи домена; без них почта по RFC 5321 идет на
+// адрес самого домена. Null MX (RFC 7505) означает, что домен почту не принимает
+func (v *EmailValidator) checkDeliverable(ctx context.Context, domain string) error {
+	undeliverable := &entity.ValidationError{Field: "email", Message: "email domain does not accept mail"}
+
+	records, err := v.Resolver.LookupMX(ctx, domain)
+	switch {
+	case err == nil && len(records) == 1 && records[0].Host == ".":
+		return undeliverable
+	case err == nil && len(records) > 0:
+		return nil
+	case err != nil && !isNotFound(err):
+		return nil
+	}
+
+	if _, err := v.Resolver.LookupHost(ctx, domain); err != nil && isNotFound(err) {
+		return undeliverable
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func matchesDomain(domain string, list []string) bool {
+	for _, candidate := range list {
+		if ascii, ok := asciiDomain(candidate); ok && (domain == ascii || strings.HasSuffix(domain, "."+ascii)) {
+			return true
+		}
+	}
+	return false
+}
+
+func parentDomain(domain string) string {
+	_, parent, _ := strings.Cut(domain, ".")
+	return parent
+}
+
+// asciiDomain приводит домен из конфигурации к виду, который возвращает entity.ParseEmail
+func asciiDomain(domain string) (string, bool) {
+	_, ascii, err := entity.ParseEmail("x@" + strings.TrimSpace(domain))
+	return ascii, err == nil
+}
+
+// validateEmail применяет EmailValidator сервиса, если он задан
+func (s *UserService) validateEmail(ctx context.Context, email string) error {
+	if s.EmailValidator == nil {
+		return nil
+	}
+	return s.EmailValidator.Validate(ctx, email)
+}
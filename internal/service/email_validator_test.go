@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/tenancy"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubResolver отвечает из таблиц; отсутствующий домен - NXDOMAIN
+type stubResolver struct {
+	mx      map[string][]*net.MX
+	hosts   map[string][]string
+	err     error
+	lookups int
+}
+
+func (r *stubResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	r.lookups++
+	if r.err != nil {
+		return nil, r.err
+	}
+	if records, ok := r.mx[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *stubResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestEmailValidator_Domains(t *testing.T) {
+	validator := NewEmailValidator()
+	validator.DeniedDomains = []string{"competitor.com"}
+
+	assert.NoError(t, validator.Validate(context.Background(), "john@example.com"))
+	assert.EqualError(t, validator.Validate(context.Background(), "john@mailinator.com"), "disposable email addresses are not allowed")
+	assert.EqualError(t, validator.Validate(context.Background(), "john@eu.mailinator.com"), "disposable email addresses are not allowed")
+	assert.EqualError(t, validator.Validate(context.Background(), "john@mail.competitor.com"), "email domain is not allowed")
+	assert.EqualError(t, validator.Validate(context.Background(), "not-an-email"), "invalid email format")
+
+	validator.AllowDisposable()
+	assert.NoError(t, validator.Validate(context.Background(), "john@mailinator.com"))
+
+	validator.AllowedDomains = []string{"acme.com", "пример.рф"}
+	assert.NoError(t, validator.Validate(context.Background(), "john@corp.acme.com"))
+	assert.NoError(t, validator.Validate(context.Background(), "john@xn--e1afmkfd.xn--p1ai"))
+	assert.EqualError(t, validator.Validate(context.Background(), "john@example.com"), "email domain is not allowed")
+}
+
+func TestEmailValidator_Deliverability(t *testing.T) {
+	validator := NewEmailValidator()
+	validator.Resolver = &stubResolver{
+		mx: map[string][]*net.MX{
+			"example.com":  {{Host: "mx.example.com.", Pref: 10}},
+			"nomail.com":   {{Host: ".", Pref: 0}},
+			"emptymx.com":  {},
+			"implicit.com": nil,
+		},
+		hosts: map[string][]string{"implicit.com": {"192.0.2.1"}},
+	}
+
+	assert.NoError(t, validator.Validate(context.Background(), "john@example.com"))
+	// Без MX-записей почта доставляется на A/AAAA самого домена
+	assert.NoError(t, validator.Validate(context.Background(), "john@implicit.com"))
+	assert.EqualError(t, validator.Validate(context.Background(), "john@emptymx.com"), "email domain does not accept mail")
+	assert.EqualError(t, validator.Validate(context.Background(), "john@nomail.com"), "email domain does not accept mail")
+	assert.EqualError(t, validator.Validate(context.Background(), "john@missing.com"), "email domain does not accept mail")
+
+	// Сбой DNS не блокирует регистрацию
+	validator.Resolver = &stubResolver{err: errors.New("i/o timeout")}
+	assert.NoError(t, validator.Validate(context.Background(), "john@missing.com"))
+}
+
+// stubDomainSource - списки доменов по ID тенанта
+type stubDomainSource map[uint][2][]string
+
+func (s stubDomainSource) EmailDomains(_ context.Context, tenantID uint) (allowed, denied []string, err error) {
+	return s[tenantID][0], s[tenantID][1], nil
+}
+
+func TestEmailValidator_TenantDomains(t *testing.T) {
+	validator := NewEmailValidator()
+	validator.TenantDomains = stubDomainSource{
+		2: {[]string{"acme.com"}, nil},
+		3: {nil, []string{"gmail.com"}},
+	}
+	acme := tenancy.WithTenant(context.Background(), 2)
+	globex := tenancy.WithTenant(context.Background(), 3)
+
+	assert.NoError(t, validator.Validate(acme, "john@corp.acme.com"))
+	assert.EqualError(t, validator.Validate(acme, "john@gmail.com"), "email domain is not allowed for this tenant")
+	assert.NoError(t, validator.Validate(globex, "john@acme.com"))
+	assert.EqualError(t, validator.Validate(globex, "john@gmail.com"), "email domain is not allowed for this tenant")
+	// У тенанта без списков действуют только общие правила
+	assert.NoError(t, validator.Validate(tenancy.WithTenant(context.Background(), 4), "john@gmail.com"))
+}
+
+func TestEmailValidator_CachesLookupsWithinCall(t *testing.T) {
+	resolver := &stubResolver{mx: map[string][]*net.MX{"example.com": {{Host: "mx.example.com.", Pref: 10}}}}
+	validator := NewEmailValidator()
+	validator.Resolver = resolver
+
+	ctx := withEmailCheckCache(context.Background())
+	for _, email := range []string{"a@example.com", "b@example.com", "c@Example.com"} {
+		assert.NoError(t, validator.Validate(ctx, email))
+	}
+	assert.EqualError(t, validator.Validate(ctx, "a@missing.example"), "email domain does not accept mail")
+	assert.EqualError(t, validator.Validate(ctx, "b@missing.example"), "email domain does not accept mail")
+	assert.Equal(t, 2, resolver.lookups)
+
+	// Без кэша вызова каждый адрес проверяется заново
+	assert.NoError(t, validator.Validate(context.Background(), "a@example.com"))
+	assert.Equal(t, 3, resolver.lookups)
+}
+
+func TestUserService_RegisterUserRejectsDisposableEmail(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := &UserService{userRepo: mockRepo, EmailValidator: NewEmailValidator()}
+
+	_, err := service.RegisterUser(context.Background(), "john", "john@yopmail.com")
+
+	assert.EqualError(t, err, "disposable email addresses are not allowed")
+	mockRepo.AssertNotCalled(t, "Create")
+}
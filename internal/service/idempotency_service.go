@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ошибки ключей идемпотентности
+var (
+	ErrIdempotencyKeyReused    = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInFlight  = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyCollision = errors.New("idempotency key is being claimed concurrently")
+)
+
+type IdempotencyServiceInterface interface {
+	Begin(ctx context.Context, caller, key, requestHash string) (record *entity.IdempotencyRecord, replay bool, err error)
+	Complete(ctx context.Context, record *entity.IdempotencyRecord, status int, contentType string, body []byte) error
+	Release(ctx context.Context, record *entity.IdempotencyRecord) error
+	KeepAlive(ctx context.Context, record *entity.IdempotencyRecord)
+}
+
+// IdempotencyConfig задает сроки; нулевые значения заменяются значениями по умолчанию
+type IdempotencyConfig struct {
+	TTL time.Duration // сколько хранится ответ для повторов, по умолчанию 24h
+	// LockTimeout - через сколько без продления (KeepAlive) незавершенный запрос
+	// считается брошенным, например после падения экземпляра, и ключ можно
+	// занять заново, по умолчанию 1m. Выполняющийся запрос продлевает блокировку,
+	// поэтому срок не ограничивает длительность запросов
+	LockTimeout time.Duration
+}
+
+// IdempotencyService хранит первый ответ на запрос с ключом идемпотентности и
+// повторяет его клиенту, не выполняя запрос снова
+type IdempotencyService struct {
+	idempotencyRepo repository.IdempotencyRepositoryInterface
+	config          IdempotencyConfig
+	now             func() time.Time
+}
+
+func NewIdempotencyService(idempotencyRepo repository.IdempotencyRepositoryInterface, config IdempotencyConfig) *IdempotencyService {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = time.Minute
+	}
+	return &IdempotencyService{idempotencyRepo: idempotencyRepo, config: config, now: time.Now}
+}
+
+// Begin занимает ключ для нового запроса (replay = false) или возвращает
+// сохраненный ответ на прежний такой же запрос (replay = true). Ключ, занятый
+// другим запросом, дает ErrIdempotencyKeyReused, а еще выполняющимся -
+// ErrIdempotencyKeyInFlight
+func (s *IdempotencyService) Begin(ctx context.Context, caller, key, requestHash string) (*entity.IdempotencyRecord, bool, error) {
+	// Две попытки: вторая после удаления истекшей или брошенной записи
+	for attempt := 0; attempt < 2; attempt++ {
+		now := s.now()
+		record := &entity.IdempotencyRecord{
+			Caller:         caller,
+			IdempotencyKey: key,
+			RequestHash:    requestHash,
+			CreatedAt:      now,
+			LockedUntil:    now.Add(s.config.LockTimeout),
+			ExpiresAt:      now.Add(s.config.TTL),
+		}
+		err := s.idempotencyRepo.Create(ctx, record)
+		if err == nil {
+			return record, false, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, false, err
+		}
+
+		existing, err := s.idempotencyRepo.Find(ctx, caller, key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Запись удалили между вставкой и чтением
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		abandoned := !existing.Completed && !now.Before(existing.LockedUntil)
+		if !now.Before(existing.ExpiresAt) || abandoned {
+			if _, err := s.idempotencyRepo.Delete(ctx, existing); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+
+		if existing.RequestHash != requestHash {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		if !existing.Completed {
+			return nil, false, ErrIdempotencyKeyInFlight
+		}
+		return existing, true, nil
+	}
+	return nil, false, ErrIdempotencyKeyCollision
+}
+
+// Complete сохраняет ответ для повторов; срок хранения отсчитывается от ответа
+func (s *IdempotencyService) Complete(ctx context.Context, record *entity.IdempotencyRecord, status int, contentType string, body []byte) error {
+	record.ResponseStatus = status
+	record.ResponseContentType = contentType
+	record.ResponseBody = body
+	record.ExpiresAt = s.now().Add(s.config.TTL)
+	return s.idempotencyRepo.Complete(ctx, record)
+}
+
+// Release освобождает ключ незавершенного запроса, чтобы повтор выполнил его заново
+func (s *IdempotencyService) Release(ctx context.Context, record *entity.IdempotencyRecord) error {
+	_, err := s.idempotencyRepo.Delete(ctx, record)
+	return err
+}
+
+// KeepAlive продлевает блокировку ключа, пока ctx не отменен: каждую треть
+// LockTimeout - еще на LockTimeout. Запускается на время выполнения запроса;
+// если запись потеряна или недоступна, продление прекращается
+func (s *IdempotencyService) KeepAlive(ctx context.Context, record *entity.IdempotencyRecord) {
+	ticker := time.NewTicker(s.config.LockTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.extend(ctx, record); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("idempotency key %q: failed to extend lock: %v", record.IdempotencyKey, err)
+				}
+				return
+			}
+		}
+	}
+}
+
+func (s *IdempotencyService) extend(ctx context.Context, record *entity.IdempotencyRecord) error {
+	return s.idempotencyRepo.Extend(ctx, record, s.now().Add(s.config.LockTimeout))
+}
+
+// RunCleanup периодически удаляет истекшие записи, пока ctx не отменен
+func (s *IdempotencyService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.idempotencyRepo.DeleteExpired(ctx, s.now()); err != nil {
+				log.Printf("idempotency cleanup failed: %v", err)
+			}
+		}
+	}
+}
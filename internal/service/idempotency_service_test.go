@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"multilayer/internal/tenancy"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupIdempotencyService(t *testing.T) (*IdempotencyService, *time.Time) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.IdempotencyRecord{}))
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service := NewIdempotencyService(repository.NewIdempotencyRepository(db), IdempotencyConfig{TTL: time.Hour, LockTimeout: time.Minute})
+	service.now = func() time.Time { return now }
+	return service, &now
+}
+
+func TestIdempotencyService_Replay(t *testing.T) {
+	service, _ := setupIdempotencyService(t)
+	ctx := tenantContext()
+
+	record, replay, err := service.Begin(ctx, "caller", "key-1", "hash-a")
+	require.NoError(t, err)
+	assert.False(t, replay)
+
+	// Пока запрос выполняется, повтор отклоняется
+	_, _, err = service.Begin(ctx, "caller", "key-1", "hash-a")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyInFlight)
+
+	require.NoError(t, service.Complete(ctx, record, 201, "application/json", []byte(`{"id":1}`)))
+	replayed, replay, err := service.Begin(ctx, "caller", "key-1", "hash-a")
+	require.NoError(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, 201, replayed.ResponseStatus)
+	assert.Equal(t, "application/json", replayed.ResponseContentType)
+	assert.Equal(t, []byte(`{"id":1}`), replayed.ResponseBody)
+
+	// Тот же ключ с другим запросом
+	_, _, err = service.Begin(ctx, "caller", "key-1", "hash-b")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// Ключи других клиентов и тенантов независимы
+	_, replay, err = service.Begin(ctx, "other", "key-1", "hash-b")
+	require.NoError(t, err)
+	assert.False(t, replay)
+	_, replay, err = service.Begin(tenancy.WithTenant(ctx, 2), "caller", "key-1", "hash-b")
+	require.NoError(t, err)
+	assert.False(t, replay)
+}
+
+func TestIdempotencyService_Release(t *testing.T) {
+	service, _ := setupIdempotencyService(t)
+	ctx := tenantContext()
+
+	record, _, err := service.Begin(ctx, "caller", "key-1", "hash-a")
+	require.NoError(t, err)
+	require.NoError(t, service.Release(ctx, record))
+
+	_, replay, err := service.Begin(ctx, "caller", "key-1", "hash-a")
+	require.NoError(t, err)
+	assert.False(t, replay)
+}
+
+func TestIdempotencyService_Expiry(t *testing.T) {
+	service, now := setupIdempotencyService(t)
+	ctx := tenantContext()
+
+	t.Run("Abandoned request", func(t *testing.T) {
+		_, _, err := service.Begin(ctx, "caller", "crashed", "hash-a")
+		require.NoError(t, err)
+
+		*now = now.Add(59 * time.Second)
+		_, _, err = service.Begin(ctx, "caller", "crashed", "hash-a")
+		assert.ErrorIs(t, err, ErrIdempotencyKeyInFlight)
+
+		// После LockTimeout ключ можно занять заново
+		*now = now.Add(time.Second)
+		_, replay, err := service.Begin(ctx, "caller", "crashed", "hash-a")
+		require.NoError(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("Kept alive request", func(t *testing.T) {
+		record, _, err := service.Begin(ctx, "caller", "slow", "hash-a")
+		require.NoError(t, err)
+
+		// Продленная блокировка держится дольше LockTimeout от начала запроса
+		*now = now.Add(50 * time.Second)
+		require.NoError(t, service.extend(ctx, record))
+		*now = now.Add(50 * time.Second)
+		_, _, err = service.Begin(ctx, "caller", "slow", "hash-a")
+		assert.ErrorIs(t, err, ErrIdempotencyKeyInFlight)
+		require.NoError(t, service.Complete(ctx, record, 201, "", nil))
+	})
+
+	t.Run("Lost lock", func(t *testing.T) {
+		record, _, err := service.Begin(ctx, "caller", "stolen", "hash-a")
+		require.NoError(t, err)
+
+		// Ключ заняли заново: ответ первого запроса не сохраняется
+		*now = now.Add(time.Minute)
+		_, _, err = service.Begin(ctx, "caller", "stolen", "hash-a")
+		require.NoError(t, err)
+		assert.ErrorIs(t, service.extend(ctx, record), gorm.ErrRecordNotFound)
+		assert.ErrorIs(t, service.Complete(ctx, record, 201, "", nil), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Expired response", func(t *testing.T) {
+		record, _, err := service.Begin(ctx, "caller", "done", "hash-a")
+		require.NoError(t, err)
+		require.NoError(t, service.Complete(ctx, record, 201, "", nil))
+
+		*now = now.Add(time.Hour)
+		_, replay, err := service.Begin(ctx, "caller", "done", "hash-b")
+		require.NoError(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		*now = now.Add(2 * time.Hour)
+		deleted, err := service.idempotencyRepo.DeleteExpired(ctx, *now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), deleted)
+	})
+}
@@ -0,0 +1,174 @@
+// (Заглушки сервисов для тестов обработчиков)
+package mocks
+
+import (
+	"context"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"multilayer/internal/service"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// UserService - заглушка service.UserServiceInterface на testify/mock;
+// аргументы ожиданий - параметры метода без ctx
+type UserService struct {
+	mock.Mock
+}
+
+func (m *UserService) UpdateUser(_ context.Context, id uint, username, email string) (*entity.User, error) {
+	args := m.Called(id, username, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) RegisterUser(_ context.Context, username, email string) (*entity.User, error) {
+	args := m.Called(username, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) UpdateProfile(_ context.Context, id uint, profile entity.UserProfile) (*entity.User, error) {
+	args := m.Called(id, profile)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) GetUser(_ context.Context, id uint) (*entity.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) GetUserAt(_ context.Context, id uint, at time.Time) (*entity.User, error) {
+	args := m.Called(id, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) GetUsersByIDs(_ context.Context, ids []uint) ([]entity.User, error) {
+	args := m.Called(ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.User), args.Error(1)
+}
+
+func (m *UserService) ListUsers(_ context.Context, filter repository.UserFilter, afterID uint, limit int) ([]entity.User, error) {
+	args := m.Called(filter, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.User), args.Error(1)
+}
+
+func (m *UserService) DeleteUser(_ context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *UserService) ImportUsers(_ context.Context, rows []service.ImportRow) ([]service.ImportResult, error) {
+	args := m.Called(rows)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.ImportResult), args.Error(1)
+}
+
+func (m *UserService) BatchUsers(_ context.Context, mode service.BatchMode, ops []service.BatchOperation) ([]service.BatchResult, error) {
+	args := m.Called(mode, ops)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.BatchResult), args.Error(1)
+}
+
+func (m *UserService) UpsertUserByEmail(_ context.Context, email, username string, profile entity.UserProfile) (*entity.User, bool, error) {
+	args := m.Called(email, username, profile)
+	if args.Get(0) == nil {
+		return nil, false, args.Error(2)
+	}
+	return args.Get(0).(*entity.User), args.Bool(1), args.Error(2)
+}
+
+func (m *UserService) GetUserByUsername(_ context.Context, username string) (*entity.User, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) GetUserByEmail(_ context.Context, email string) (*entity.User, error) {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) LookupUsers(_ context.Context, usernames, emails []string) (*service.UserLookupResult, error) {
+	args := m.Called(usernames, emails)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.UserLookupResult), args.Error(1)
+}
+
+func (m *UserService) CheckAvailability(_ context.Context, username, email string) (*service.Availability, error) {
+	args := m.Called(username, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Availability), args.Error(1)
+}
+
+func (m *UserService) ChangeUserStatus(_ context.Context, id uint, status entity.UserStatus, reason string) (*entity.User, error) {
+	args := m.Called(id, status, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) AuthenticateUser(_ context.Context, id uint) (*entity.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *UserService) ExportUsers(_ context.Context, fn func(user *entity.User) error) error {
+	args := m.Called(fn)
+	return args.Error(0)
+}
+
+func (m *UserService) GetUserHistory(_ context.Context, id, beforeID uint, limit int) ([]entity.UserAuditEntry, error) {
+	args := m.Called(id, beforeID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.UserAuditEntry), args.Error(1)
+}
+
+func (m *UserService) SearchUsers(_ context.Context, query string, limit int) ([]service.UserSearchResult, error) {
+	args := m.Called(query, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.UserSearchResult), args.Error(1)
+}
+
+var _ service.UserServiceInterface = (*UserService)(nil)
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// Доменные ошибки организаций, команд и членства
+var (
+	ErrOrganizationNotFound      = errors.New("organization not found")
+	ErrOrganizationAlreadyExists = errors.New("organization with this slug already exists")
+	ErrOrganizationHasTeams      = errors.New("organization still has teams")
+	ErrTeamNotFound              = errors.New("team not found")
+	ErrTeamAlreadyExists         = errors.New("team with this slug already exists in the organization")
+	ErrMemberNotFound            = errors.New("membership not found")
+	ErrAlreadyMember             = errors.New("user is already a member")
+	ErrNotOrganizationMember     = errors.New("user is not a member of the organization")
+)
+
+type OrganizationServiceInterface interface {
+	CreateOrganization(ctx context.Context, slug, name string) (*entity.Organization, error)
+	GetOrganization(ctx context.Context, id uint) (*entity.Organization, error)
+	ListOrganizations(ctx context.Context) ([]entity.Organization, error)
+	DeleteOrganization(ctx context.Context, id uint) error
+	AddMember(ctx context.Context, organizationID, userID uint, role string) (*entity.OrganizationMember, error)
+	RemoveMember(ctx context.Context, organizationID, userID uint) error
+	ListMembers(ctx context.Context, organizationID uint) ([]entity.OrganizationMember, error)
+	ListUserMemberships(ctx context.Context, userID uint) ([]entity.UserMembership, error)
+}
+
+type OrganizationService struct {
+	organizationRepo repository.OrganizationRepositoryInterface
+	userRepo         repository.UserRepositoryInterface
+}
+
+func NewOrganizationService(organizationRepo repository.OrganizationRepositoryInterface, userRepo repository.UserRepositoryInterface) *OrganizationService {
+	return &OrganizationService{organizationRepo: organizationRepo, userRepo: userRepo}
+}
+
+func (s *OrganizationService) CreateOrganization(ctx context.Context, slug, name string) (*entity.Organization, error) {
+	organization, err := entity.NewOrganization(slug, name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.organizationRepo.Create(ctx, organization)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrOrganizationAlreadyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (s *OrganizationService) GetOrganization(ctx context.Context, id uint) (*entity.Organization, error) {
+	organization, err := s.organizationRepo.FindByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (s *OrganizationService) ListOrganizations(ctx context.Context) ([]entity.Organization, error) {
+	return s.organizationRepo.List(ctx)
+}
+
+// DeleteOrganization удаляет организацию и членство в ней; организацию с
+// командами удалить нельзя
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, id uint) error {
+	err := s.organizationRepo.Delete(ctx, id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrOrganizationNotFound
+	case errors.Is(err, repository.ErrInUse):
+		return ErrOrganizationHasTeams
+	}
+	return err
+}
+
+// AddMember добавляет пользователя тенанта в организацию; пустая роль означает member
+func (s *OrganizationService) AddMember(ctx context.Context, organizationID, userID uint, role string) (*entity.OrganizationMember, error) {
+	memberRole, err := entity.ParseMembershipRole(role)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.GetOrganization(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return nil, mapRepositoryError(err)
+	}
+
+	member := &entity.OrganizationMember{OrganizationID: organizationID, UserID: userID, Role: memberRole}
+	err = s.organizationRepo.AddMember(ctx, member)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrAlreadyMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// RemoveMember исключает пользователя из организации и из всех ее команд
+func (s *OrganizationService) RemoveMember(ctx context.Context, organizationID, userID uint) error {
+	if _, err := s.GetOrganization(ctx, organizationID); err != nil {
+		return err
+	}
+	err := s.organizationRepo.RemoveMember(ctx, organizationID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMemberNotFound
+	}
+	return err
+}
+
+func (s *OrganizationService) ListMembers(ctx context.Context, organizationID uint) ([]entity.OrganizationMember, error) {
+	if _, err := s.GetOrganization(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	return s.organizationRepo.ListMembers(ctx, organizationID)
+}
+
+// ListUserMemberships возвращает организации и команды пользователя
+func (s *OrganizationService) ListUserMemberships(ctx context.Context, userID uint) ([]entity.UserMembership, error) {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	return s.organizationRepo.ListUserMemberships(ctx, userID)
+}
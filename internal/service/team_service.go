@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type TeamServiceInterface interface {
+	CreateTeam(ctx context.Context, organizationID uint, slug, name string) (*entity.Team, error)
+	ListTeams(ctx context.Context, organizationID uint) ([]entity.Team, error)
+	DeleteTeam(ctx context.Context, organizationID, id uint) error
+	AddMember(ctx context.Context, organizationID, teamID, userID uint, role string) (*entity.TeamMember, error)
+	RemoveMember(ctx context.Context, organizationID, teamID, userID uint) error
+	ListMembers(ctx context.Context, organizationID, teamID uint) ([]entity.TeamMember, error)
+}
+
+// TeamService управляет командами организаций; команда всегда адресуется
+// вместе со своей организацией
+type TeamService struct {
+	teamRepo         repository.TeamRepositoryInterface
+	organizationRepo repository.OrganizationRepositoryInterface
+}
+
+func NewTeamService(teamRepo repository.TeamRepositoryInterface, organizationRepo repository.OrganizationRepositoryInterface) *TeamService {
+	return &TeamService{teamRepo: teamRepo, organizationRepo: organizationRepo}
+}
+
+func (s *TeamService) CreateTeam(ctx context.Context, organizationID uint, slug, name string) (*entity.Team, error) {
+	team, err := entity.NewTeam(organizationID, slug, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOrganization(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	err = s.teamRepo.Create(ctx, team)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrTeamAlreadyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *TeamService) ListTeams(ctx context.Context, organizationID uint) ([]entity.Team, error) {
+	if err := s.checkOrganization(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	return s.teamRepo.List(ctx, organizationID)
+}
+
+// DeleteTeam удаляет команду вместе с членством в ней
+func (s *TeamService) DeleteTeam(ctx context.Context, organizationID, id uint) error {
+	if err := s.checkOrganization(ctx, organizationID); err != nil {
+		return err
+	}
+	err := s.teamRepo.Delete(ctx, organizationID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTeamNotFound
+	}
+	return err
+}
+
+// AddMember добавляет в команду участника ее организации; пустая роль означает member
+func (s *TeamService) AddMember(ctx context.Context, organizationID, teamID, userID uint, role string) (*entity.TeamMember, error) {
+	memberRole, err := entity.ParseMembershipRole(role)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTeam(ctx, organizationID, teamID); err != nil {
+		return nil, err
+	}
+	_, err = s.organizationRepo.FindMember(ctx, organizationID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotOrganizationMember
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	member := &entity.TeamMember{TeamID: teamID, UserID: userID, Role: memberRole}
+	err = s.teamRepo.AddMember(ctx, member)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrAlreadyMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (s *TeamService) RemoveMember(ctx context.Context, organizationID, teamID, userID uint) error {
+	if err := s.checkTeam(ctx, organizationID, teamID); err != nil {
+		return err
+	}
+	err := s.teamRepo.RemoveMember(ctx, teamID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMemberNotFound
+	}
+	return err
+}
+
+func (s *TeamService) ListMembers(ctx context.Context, organizationID, teamID uint) ([]entity.TeamMember, error) {
+	if err := s.checkTeam(ctx, organizationID, teamID); err != nil {
+		return nil, err
+	}
+	return s.teamRepo.ListMembers(ctx, teamID)
+}
+
+// checkOrganization проверяет, что организация существует в тенанте из ctx
+func (s *TeamService) checkOrganization(ctx context.Context, organizationID uint) error {
+	_, err := s.organizationRepo.FindByID(ctx, organizationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrOrganizationNotFound
+	}
+	return err
+}
+
+// checkTeam проверяет, что команда существует и принадлежит организации
+func (s *TeamService) checkTeam(ctx context.Context, organizationID, teamID uint) error {
+	if err := s.checkOrganization(ctx, organizationID); err != nil {
+		return err
+	}
+	_, err := s.teamRepo.FindByID(ctx, organizationID, teamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTeamNotFound
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Доменные ошибки тенантов
+var (
+	ErrTenantNotFound      = errors.New("tenant not found")
+	ErrTenantAlreadyExists = errors.New("tenant with this slug already exists")
+)
+
+type TenantServiceInterface interface {
+	CreateTenant(slug, name string) (*entity.Tenant, error)
+	GetTenant(slug string) (*entity.Tenant, error)
+	ListTenants() ([]entity.Tenant, error)
+	SetEmailDomains(slug string, allowed, denied []string) (*entity.Tenant, error)
+}
+
+type TenantService struct {
+	tenantRepo repository.TenantRepositoryInterface
+}
+
+func NewTenantService(tenantRepo repository.TenantRepositoryInterface) *TenantService {
+	return &TenantService{tenantRepo: tenantRepo}
+}
+
+func (s *TenantService) CreateTenant(slug, name string) (*entity.Tenant, error) {
+	tenant, err := entity.NewTenant(slug, name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.tenantRepo.Create(tenant)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrTenantAlreadyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// GetTenant ищет тенанта по slug без учета регистра
+func (s *TenantService) GetTenant(slug string) (*entity.Tenant, error) {
+	tenant, err := s.tenantRepo.FindBySlug(strings.ToLower(strings.TrimSpace(slug)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (s *TenantService) ListTenants() ([]entity.Tenant, error) {
+	return s.tenantRepo.List()
+}
+
+// SetEmailDomains заменяет списки доменов email тенанта. Уже созданных
+// пользователей они не затрагивают
+func (s *TenantService) SetEmailDomains(slug string, allowed, denied []string) (*entity.Tenant, error) {
+	tenant, err := s.GetTenant(slug)
+	if err != nil {
+		return nil, err
+	}
+	if err := tenant.SetEmailDomains(allowed, denied); err != nil {
+		return nil, err
+	}
+	if err := s.tenantRepo.Update(tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// EmailDomains реализует EmailDomainSource; неизвестный тенант ограничений не имеет
+func (s *TenantService) EmailDomains(_ context.Context, tenantID uint) (allowed, denied []string, err error) {
+	tenant, err := s.tenantRepo.FindByID(tenantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return tenant.AllowedEmailDomains, tenant.DeniedEmailDomains, nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+	"strconv"
+	"unicode/utf8"
+)
+
+// UsernameSuggestions - сколько свободных вариантов username предлагает CheckAvailability
+const UsernameSuggestions = 3
+
+// suggestionCandidates - сколько вариантов с числовым суффиксом проверяется
+// одним запросом, чтобы набрать UsernameSuggestions свободных
+const suggestionCandidates = 20
+
+// Ошибки занятых значений в FieldAvailability.Error
+var (
+	errUsernameTaken = errors.New("username is already taken")
+	errEmailTaken    = errors.New("email is already registered")
+)
+
+// AvailabilityReason - почему значение недоступно
+type AvailabilityReason string
+
+const (
+	AvailabilityInvalid AvailabilityReason = "invalid"
+	AvailabilityTaken   AvailabilityReason = "taken"
+)
+
+// FieldAvailability - результат проверки одного поля; Value - значение после нормализации
+type FieldAvailability struct {
+	Value       string             `json:"value"`
+	Available   bool               `json:"available"`
+	Reason      AvailabilityReason `json:"reason,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	Suggestions []string           `json:"suggestions,omitempty"`
+}
+
+// Availability - результат CheckAvailability; непроверенные поля равны nil
+type Availability struct {
+	Username *FieldAvailability `json:"username,omitempty"`
+	Email    *FieldAvailability `json:"email,omitempty"`
+}
+
+// CheckAvailability проверяет, можно ли зарегистрироваться с username и email:
+// применяет те же правила, что и RegisterUser, и ищет занятые значения одним
+// запросом. Для недоступного username предлагаются свободные варианты с числовым
+// суффиксом. Пустой аргумент не проверяется
+func (s *UserService) CheckAvailability(ctx context.Context, username, email string) (*Availability, error) {
+	result := &Availability{}
+	var usernames, emails []string
+
+	if username != "" {
+		result.Username = &FieldAvailability{Value: entity.NormalizeUsername(username)}
+		err := entity.ValidateUsername(result.Username.Value, s.UsernamePolicy)
+		if err != nil {
+			if !isValidationError(err) {
+				return nil, err
+			}
+			result.Username.reject(AvailabilityInvalid, err)
+		} else {
+			usernames = append(usernames, result.Username.Value)
+		}
+		// Варианты нужны и для занятого, и для зарезервированного или короткого имени
+		usernames = append(usernames, s.usernameCandidates(result.Username.Value)...)
+	}
+	if email != "" {
+		result.Email = &FieldAvailability{Value: entity.NormalizeEmail(email)}
+		err := entity.ValidateEmail(result.Email.Value)
+		if err == nil {
+			err = s.validateEmail(ctx, result.Email.Value)
+		}
+		if err != nil {
+			if !isValidationError(err) {
+				return nil, err
+			}
+			result.Email.reject(AvailabilityInvalid, err)
+		} else {
+			emails = append(emails, result.Email.Value)
+		}
+	}
+
+	taken, err := s.userRepo.FindByUsernamesOrEmails(ctx, usernames, emails)
+	if err != nil {
+		return nil, err
+	}
+	takenUsernames := make(map[string]bool, len(taken))
+	takenEmails := make(map[string]bool, len(taken))
+	for _, user := range taken {
+		takenUsernames[user.UsernameKey] = true
+		takenEmails[user.EmailKey] = true
+	}
+
+	if field := result.Username; field != nil {
+		if field.Reason == "" {
+			if takenUsernames[entity.CanonicalUsername(field.Value)] {
+				field.reject(AvailabilityTaken, errUsernameTaken)
+			} else {
+				field.Available = true
+			}
+		}
+		if !field.Available {
+			for _, candidate := range usernames {
+				key := entity.CanonicalUsername(candidate)
+				if key == entity.CanonicalUsername(field.Value) || takenUsernames[key] {
+					continue
+				}
+				field.Suggestions = append(field.Suggestions, candidate)
+				if len(field.Suggestions) == UsernameSuggestions {
+					break
+				}
+			}
+		}
+	}
+	if field := result.Email; field != nil && field.Reason == "" {
+		if takenEmails[s.userRepo.EmailKey(field.Value)] {
+			field.reject(AvailabilityTaken, errEmailTaken)
+		} else {
+			field.Available = true
+		}
+	}
+	return result, nil
+}
+
+// usernameCandidates возвращает варианты username с суффиксами 1, 2, ...,
+// допустимые по UsernamePolicy; слишком длинная основа укорачивается
+func (s *UserService) usernameCandidates(username string) []string {
+	var candidates []string
+	for i := 1; i <= suggestionCandidates; i++ {
+		suffix := strconv.Itoa(i)
+		base := username
+		if s.UsernamePolicy != nil && s.UsernamePolicy.MaxLength > 0 {
+			for utf8.RuneCountInString(base)+len(suffix) > s.UsernamePolicy.MaxLength && base != "" {
+				_, size := utf8.DecodeLastRuneInString(base)
+				base = base[:len(base)-size]
+			}
+		}
+		if base == "" {
+			continue
+		}
+		candidate := base + suffix
+		if entity.ValidateUsername(candidate, s.UsernamePolicy) == nil {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+func (f *FieldAvailability) reject(reason AvailabilityReason, err error) {
+	f.Reason = reason
+	f.Error = err.Error()
+}
+
+func isValidationError(err error) bool {
+	var validationErr *entity.ValidationError
+	return errors.As(err, &validationErr)
+}
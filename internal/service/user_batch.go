@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multilayer/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+// MaxBatchOperations - наибольшее число операций в одном вызове BatchUsers
+const MaxBatchOperations = 1000
+
+// Ошибки пакетных операций
+var (
+	ErrBatchTooLarge           = fmt.Errorf("batch must contain at most %d operations", MaxBatchOperations)
+	ErrBatchAtomicUnsupported  = errors.New("atomic batches require a transaction manager")
+	errBatchAborted            = errors.New("batch aborted")
+	errBatchDuplicateReference = errors.New("user is referenced by more than one operation")
+)
+
+// BatchMode - режим выполнения пакета операций
+type BatchMode string
+
+const (
+	// BatchAtomic применяет либо все операции, либо ни одной
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort применяет все операции, которые удалось выполнить
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+// BatchOp - вид операции пакета
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchStatus - итог выполнения операции пакета
+type BatchStatus string
+
+const (
+	BatchCreated  BatchStatus = "created"
+	BatchUpdated  BatchStatus = "updated"
+	BatchDeleted  BatchStatus = "deleted"
+	BatchInvalid  BatchStatus = "invalid"
+	BatchNotFound BatchStatus = "not_found"
+	BatchConflict BatchStatus = "conflict"
+	BatchFailed   BatchStatus = "failed"
+	// BatchAborted - операция корректна, но не применена, потому что атомарный пакет откачен
+	BatchAborted BatchStatus = "aborted"
+)
+
+// BatchOperation - операция пакета; ID нужен для update и delete,
+// Username и Email - для create и update
+type BatchOperation struct {
+	Op       BatchOp
+	ID       uint
+	Username string
+	Email    string
+}
+
+// BatchResult - результат операции пакета; Index - ее номер во входном списке
+type BatchResult struct {
+	Index  int          `json:"index"`
+	Op     BatchOp      `json:"op"`
+	Status BatchStatus  `json:"status"`
+	UserID uint         `json:"user_id,omitempty"`
+	User   *entity.User `json:"-"` // состояние после create или update
+	Error  string       `json:"error,omitempty"`
+}
+
+// Succeeded сообщает, применена ли операция
+func (r BatchResult) Succeeded() bool {
+	return r.Status == BatchCreated || r.Status == BatchUpdated || r.Status == BatchDeleted
+}
+
+// batchPlan - прошедшие проверку операции, разложенные по видам; позиции
+// указывают на индексы в срезе результатов
+type batchPlan struct {
+	deletes, updates, creates []batchItem
+}
+
+type batchItem struct {
+	pos  int
+	user *entity.User
+}
+
+// BatchUsers выполняет пакет операций над пользователями. Операции применяются
+// многострочными запросами: сначала удаления, затем изменения, затем создания,
+// так что освобожденные username и email можно занять в том же пакете. Если
+// пакетный запрос не прошел, операции повторяются по одной в том же порядке,
+// чтобы определить результат каждой. В режиме BatchAtomic любая неудача
+// откатывает весь пакет, остальные операции получают статус BatchAborted
+func (s *UserService) BatchUsers(ctx context.Context, mode BatchMode, ops []BatchOperation) ([]BatchResult, error) {
+	if len(ops) > MaxBatchOperations {
+		return nil, ErrBatchTooLarge
+	}
+	if mode == BatchAtomic && s.Transactions == nil {
+		return nil, ErrBatchAtomicUnsupported
+	}
+	ctx = withEmailCheckCache(ctx)
+	emailErrs := s.checkEmails(ctx, ops)
+
+	var results []BatchResult
+	err := s.inTransaction(ctx, func(ctx context.Context) error {
+		// Транзакцию могут повторить после конфликта: результаты считаются заново
+		results = make([]BatchResult, len(ops))
+		plan, err := s.prepareBatch(ctx, ops, results, emailErrs)
+		if err != nil {
+			return err
+		}
+		if mode == BatchAtomic && !allPlanned(results, plan) {
+			return errBatchAborted
+		}
+
+		if s.Transactions != nil {
+			// Точка сохранения отменяет частично примененный пакет перед повтором по одной
+			err := s.Transactions.Transaction(ctx, func(ctx context.Context) error {
+				return s.applyBatch(ctx, plan)
+			})
+			if err == nil {
+				plan.each(func(item batchItem) { markApplied(&results[item.pos], item.user) })
+				return nil
+			}
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return err
+			}
+		}
+
+		if !s.applyEach(ctx, plan, results) && mode == BatchAtomic {
+			return errBatchAborted
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchAborted) {
+		for i := range results {
+			if results[i].Succeeded() || results[i].Status == "" {
+				results[i].Status = BatchAborted
+				results[i].User = nil
+				if results[i].Op == BatchCreate {
+					// ID откаченной вставки не принадлежит пользователю
+					results[i].UserID = 0
+				}
+			}
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// checkEmails проверяет адреса операций create и update до транзакции: проверки
+// обращаются к DNS и спискам доменов тенанта, и транзакция не должна их ждать.
+// Возвращает ошибки проверки по нормализованному адресу
+func (s *UserService) checkEmails(ctx context.Context, ops []BatchOperation) map[string]error {
+	emailErrs := make(map[string]error)
+	for _, op := range ops {
+		if op.Op != BatchCreate && op.Op != BatchUpdate {
+			continue
+		}
+		email := entity.NormalizeEmail(op.Email)
+		if _, ok := emailErrs[email]; !ok {
+			emailErrs[email] = s.validateEmail(ctx, email)
+		}
+	}
+	return emailErrs
+}
+
+// prepareBatch проверяет операции и загружает затрагиваемых пользователей одним
+// запросом. Результаты checkEmails применяются к новым адресам. Отклоненные
+// операции получают итоговый статус в results
+func (s *UserService) prepareBatch(ctx context.Context, ops []BatchOperation, results []BatchResult, emailErrs map[string]error) (*batchPlan, error) {
+	plan := &batchPlan{}
+	references := make(map[uint]int)
+	var ids []uint
+	for i, op := range ops {
+		results[i].Index = i
+		results[i].Op = op.Op
+		switch op.Op {
+		case BatchCreate:
+		case BatchUpdate, BatchDelete:
+			if op.ID == 0 {
+				results[i].fail(BatchInvalid, errors.New("id is required"))
+				continue
+			}
+			references[op.ID]++
+			if references[op.ID] == 1 {
+				ids = append(ids, op.ID)
+			}
+		default:
+			results[i].fail(BatchInvalid, fmt.Errorf("unknown operation %q", op.Op))
+		}
+	}
+
+	var existing []entity.User
+	if len(ids) > 0 {
+		var err error
+		if existing, err = s.userRepo.FindByIDs(ctx, ids); err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]*entity.User, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+	}
+
+	for i, op := range ops {
+		if results[i].Status != "" {
+			continue
+		}
+		results[i].UserID = op.ID
+		if op.Op != BatchCreate {
+			if references[op.ID] > 1 {
+				results[i].fail(BatchInvalid, errBatchDuplicateReference)
+				continue
+			}
+			if byID[op.ID] == nil {
+				results[i].fail(BatchNotFound, ErrUserNotFound)
+				continue
+			}
+		}
+
+		switch op.Op {
+		case BatchCreate:
+			user, err := entity.NewUser(op.Username, op.Email, s.UsernamePolicy)
+			if err == nil {
+				err = emailErrs[user.Email]
+			}
+			if err != nil {
+				results[i].fail(BatchInvalid, err)
+				continue
+			}
+			plan.creates = append(plan.creates, batchItem{pos: i, user: user})
+		case BatchUpdate:
+			user := byID[op.ID]
+			previousEmail := user.Email
+			err := user.Update(op.Username, op.Email, s.UsernamePolicy)
+			if err == nil && s.userRepo.EmailKey(user.Email) != s.userRepo.EmailKey(previousEmail) {
+				err = emailErrs[user.Email]
+			}
+			if err != nil {
+				results[i].fail(BatchInvalid, err)
+				continue
+			}
+			plan.updates = append(plan.updates, batchItem{pos: i, user: user})
+		case BatchDelete:
+			plan.deletes = append(plan.deletes, batchItem{pos: i, user: byID[op.ID]})
+		}
+	}
+	return plan, nil
+}
+
+// applyBatch применяет план многострочными запросами
+func (s *UserService) applyBatch(ctx context.Context, plan *batchPlan) error {
+	if len(plan.deletes) > 0 {
+		ids := make([]uint, len(plan.deletes))
+		for i, item := range plan.deletes {
+			ids[i] = item.user.ID
+		}
+		if err := s.userRepo.DeleteBatch(ctx, ids); err != nil {
+			return err
+		}
+	}
+	if err := s.userRepo.UpdateBatch(ctx, plan.users(plan.updates)); err != nil {
+		return err
+	}
+	return s.userRepo.CreateInBatches(ctx, plan.users(plan.creates), ImportBatchSize)
+}
+
+// applyEach применяет операции плана по одной; каждый вызов репозитория
+// откатывается сам, не затрагивая остальные. Возвращает false, если какая-то не прошла
+func (s *UserService) applyEach(ctx context.Context, plan *batchPlan, results []BatchResult) bool {
+	ok := true
+	plan.each(func(item batchItem) {
+		var err error
+		switch results[item.pos].Op {
+		case BatchDelete:
+			err = s.userRepo.Delete(ctx, item.user.ID)
+		case BatchUpdate:
+			err = s.userRepo.Update(ctx, item.user)
+		case BatchCreate:
+			err = s.userRepo.Create(ctx, item.user)
+		}
+		switch {
+		case err == nil:
+			markApplied(&results[item.pos], item.user)
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			results[item.pos].fail(BatchConflict, ErrUserAlreadyExists)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			results[item.pos].fail(BatchNotFound, ErrUserNotFound)
+		default:
+			results[item.pos].fail(BatchFailed, err)
+		}
+		if err != nil {
+			ok = false
+		}
+	})
+	return ok
+}
+
+func (p *batchPlan) each(fn func(item batchItem)) {
+	for _, items := range [][]batchItem{p.deletes, p.updates, p.creates} {
+		for _, item := range items {
+			fn(item)
+		}
+	}
+}
+
+func (p *batchPlan) users(items []batchItem) []*entity.User {
+	users := make([]*entity.User, len(items))
+	for i, item := range items {
+		users[i] = item.user
+	}
+	return users
+}
+
+func (r *BatchResult) fail(status BatchStatus, err error) {
+	r.Status = status
+	r.Error = err.Error()
+}
+
+func markApplied(result *BatchResult, user *entity.User) {
+	result.UserID = user.ID
+	switch result.Op {
+	case BatchCreate:
+		result.Status = BatchCreated
+		result.User = user
+	case BatchUpdate:
+		result.Status = BatchUpdated
+		result.User = user
+	case BatchDelete:
+		result.Status = BatchDeleted
+	}
+}
+
+// allPlanned сообщает, что все операции прошли проверку и вошли в план
+func allPlanned(results []BatchResult, plan *batchPlan) bool {
+	return len(plan.deletes)+len(plan.updates)+len(plan.creates) == len(results)
+}
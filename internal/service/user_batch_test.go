@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"multilayer/internal/tenancy"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupBatchService(t *testing.T) (*UserService, []*entity.User) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}))
+
+	service := NewUserService(repository.NewUserRepository(db))
+	service.Transactions = repository.NewTxManager(db)
+
+	var users []*entity.User
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user, err := service.RegisterUser(tenantContext(), name, name+"@example.com")
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+	return service, users
+}
+
+// tenantContext - контекст тенанта по умолчанию, как его задает ResolveTenant
+func tenantContext() context.Context {
+	return tenancy.WithTenant(context.Background(), entity.DefaultTenantID)
+}
+
+func batchStatuses(results []BatchResult) []BatchStatus {
+	statuses := make([]BatchStatus, len(results))
+	for i, result := range results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+func TestUserService_BatchUsers(t *testing.T) {
+	service, users := setupBatchService(t)
+	ctx := tenantContext()
+
+	results, err := service.BatchUsers(ctx, BatchAtomic, []BatchOperation{
+		{Op: BatchCreate, Username: "alice", Email: "alice2@example.com"},
+		{Op: BatchDelete, ID: users[0].ID},
+		{Op: BatchUpdate, ID: users[1].ID, Username: "robert", Email: "bob@example.com"},
+		{Op: BatchCreate, Username: "dave", Email: "dave@example.com"},
+	})
+	require.NoError(t, err)
+	// Удаление применяется раньше создания: имя alice освобождается в том же пакете
+	assert.Equal(t, []BatchStatus{BatchCreated, BatchDeleted, BatchUpdated, BatchCreated}, batchStatuses(results))
+	assert.NotZero(t, results[0].UserID)
+	assert.Equal(t, "robert", results[2].User.Username)
+	assert.Equal(t, 3, results[3].Index)
+
+	_, err = service.GetUser(ctx, users[0].ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	found, err := service.GetUser(ctx, users[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "robert", found.Username)
+}
+
+func TestUserService_BatchUsers_Atomic(t *testing.T) {
+	service, users := setupBatchService(t)
+	ctx := tenantContext()
+
+	t.Run("Validation error aborts the batch", func(t *testing.T) {
+		results, err := service.BatchUsers(ctx, BatchAtomic, []BatchOperation{
+			{Op: BatchCreate, Username: "dave", Email: "dave@example.com"},
+			{Op: BatchUpdate, ID: users[0].ID, Username: "alice", Email: "not-an-email"},
+			{Op: BatchDelete, ID: 999},
+			{Op: "rename", ID: users[1].ID},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []BatchStatus{BatchAborted, BatchInvalid, BatchNotFound, BatchInvalid}, batchStatuses(results))
+		assert.Empty(t, results[0].Error)
+		assert.NotEmpty(t, results[1].Error)
+
+		list, err := service.ListUsers(ctx, repository.UserFilter{}, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, list, 3)
+	})
+
+	t.Run("Database conflict is attributed to the operation", func(t *testing.T) {
+		results, err := service.BatchUsers(ctx, BatchAtomic, []BatchOperation{
+			{Op: BatchDelete, ID: users[2].ID},
+			{Op: BatchCreate, Username: "erin", Email: "erin@example.com"},
+			{Op: BatchCreate, Username: "Bob", Email: "bob2@example.com"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []BatchStatus{BatchAborted, BatchAborted, BatchConflict}, batchStatuses(results))
+		assert.Equal(t, ErrUserAlreadyExists.Error(), results[2].Error)
+		assert.Nil(t, results[1].User)
+		assert.Zero(t, results[1].UserID)
+
+		_, err = service.GetUser(ctx, users[2].ID)
+		assert.NoError(t, err, "rolled back delete must keep the user")
+	})
+
+	t.Run("Requires transactions", func(t *testing.T) {
+		plain := NewUserService(new(MockUserRepository))
+		_, err := plain.BatchUsers(ctx, BatchAtomic, []BatchOperation{{Op: BatchDelete, ID: 1}})
+		assert.ErrorIs(t, err, ErrBatchAtomicUnsupported)
+	})
+}
+
+func TestUserService_BatchUsers_BestEffort(t *testing.T) {
+	service, users := setupBatchService(t)
+	ctx := tenantContext()
+
+	results, err := service.BatchUsers(ctx, BatchBestEffort, []BatchOperation{
+		{Op: BatchCreate, Username: "dave", Email: "dave@example.com"},
+		{Op: BatchCreate, Username: "dave", Email: "dave2@example.com"},
+		{Op: BatchUpdate, ID: users[0].ID, Username: "alice", Email: "alice@corp.example.com"},
+		{Op: BatchUpdate, ID: users[1].ID, Username: "carol", Email: "bob@example.com"},
+		{Op: BatchDelete, ID: users[2].ID},
+		{Op: BatchDelete, ID: users[2].ID},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []BatchStatus{
+		BatchCreated, BatchConflict, BatchUpdated, BatchConflict, BatchInvalid, BatchInvalid,
+	}, batchStatuses(results))
+	// Оба удаления carol отклонены, поэтому имя carol осталось занятым
+	assert.Equal(t, ErrUserAlreadyExists.Error(), results[3].Error)
+
+	list, err := service.ListUsers(ctx, repository.UserFilter{}, 0, 10)
+	require.NoError(t, err)
+	usernames := make([]string, len(list))
+	for i, user := range list {
+		usernames[i] = user.Username
+	}
+	assert.ElementsMatch(t, []string{"alice", "bob", "carol", "dave"}, usernames)
+}
+
+func TestUserService_BatchUsers_TooLarge(t *testing.T) {
+	service := NewUserService(new(MockUserRepository))
+	_, err := service.BatchUsers(tenantContext(), BatchBestEffort, make([]BatchOperation, MaxBatchOperations+1))
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+}
+
+// transactionAwareResolver запоминает, выполнялась ли DNS-проверка внутри транзакции
+type transactionAwareResolver struct {
+	stubResolver
+	inTransaction bool
+}
+
+func (r *transactionAwareResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.inTransaction = r.inTransaction || repository.InTransaction(ctx)
+	return r.stubResolver.LookupMX(ctx, name)
+}
+
+func TestUserService_ValidatesEmailOutsideTransaction(t *testing.T) {
+	service, users := setupBatchService(t)
+	ctx := tenantContext()
+	resolver := &transactionAwareResolver{stubResolver: stubResolver{mx: map[string][]*net.MX{
+		"corp.example": {{Host: "mx.corp.example.", Pref: 10}},
+	}}}
+	// Адреса пользователей из setupBatchService (example.com) теперь не прошли бы проверку
+	service.EmailValidator.Resolver = resolver
+
+	results, err := service.BatchUsers(ctx, BatchBestEffort, []BatchOperation{
+		{Op: BatchCreate, Username: "dave", Email: "dave@corp.example"},
+		{Op: BatchCreate, Username: "erin", Email: "erin@nowhere.example"},
+		{Op: BatchUpdate, ID: users[0].ID, Username: "alice", Email: "alice@nowhere.example"},
+		// Проверка применяется только к новому адресу
+		{Op: BatchUpdate, ID: users[1].ID, Username: "robert", Email: "bob@example.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []BatchStatus{BatchCreated, BatchInvalid, BatchInvalid, BatchUpdated}, batchStatuses(results))
+
+	_, err = service.UpdateUser(ctx, users[2].ID, "carol", "carol@nowhere.example")
+	assert.True(t, isValidationError(err))
+	_, err = service.UpdateUser(ctx, users[2].ID, "caroline", "carol@example.com")
+	require.NoError(t, err)
+
+	assert.NotZero(t, resolver.lookups)
+	assert.False(t, resolver.inTransaction)
+}
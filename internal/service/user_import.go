@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"multilayer/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+// ImportBatchSize - число строк импорта, вставляемых в одной транзакции
+const ImportBatchSize = 100
+
+// ImportStatus - итог обработки строки импорта
+type ImportStatus string
+
+const (
+	ImportCreated   ImportStatus = "created"
+	ImportDuplicate ImportStatus = "duplicate"
+	ImportInvalid   ImportStatus = "invalid"
+)
+
+// ImportRow - строка входных данных импорта; Row - ее номер в исходном файле
+type ImportRow struct {
+	Row      int
+	Username string
+	Email    string
+}
+
+// ImportResult - результат обработки одной строки импорта
+type ImportResult struct {
+	Row    int          `json:"row"`
+	Status ImportStatus `json:"status"`
+	UserID uint         `json:"user_id,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// ImportUsers валидирует строки через entity.NewUser и вставляет корректные
+// транзакциями по ImportBatchSize строк. Результаты возвращаются в порядке rows
+func (s *UserService) ImportUsers(ctx context.Context, rows []ImportRow) ([]ImportResult, error) {
+	ctx = withEmailCheckCache(ctx)
+	results := make([]ImportResult, len(rows))
+	for start := 0; start < len(rows); start += ImportBatchSize {
+		end := min(start+ImportBatchSize, len(rows))
+		if err := s.importBatch(ctx, rows[start:end], results[start:end]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (s *UserService) importBatch(ctx context.Context, rows []ImportRow, results []ImportResult) error {
+	var users []*entity.User
+	var positions []int
+	for i, row := range rows {
+		results[i].Row = row.Row
+		user, err := entity.NewUser(row.Username, row.Email, s.UsernamePolicy)
+		if err == nil {
+			err = s.validateEmail(ctx, user.Email)
+		}
+		if err != nil {
+			results[i].Status = ImportInvalid
+			results[i].Error = err.Error()
+			continue
+		}
+		users = append(users, user)
+		positions = append(positions, i)
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	rowErrs, err := s.userRepo.CreateBatch(ctx, users)
+	if err != nil {
+		return err
+	}
+	for j, pos := range positions {
+		switch rowErr := rowErrs[j]; {
+		case rowErr == nil:
+			results[pos].Status = ImportCreated
+			results[pos].UserID = users[j].ID
+		case errors.Is(rowErr, gorm.ErrDuplicatedKey):
+			results[pos].Status = ImportDuplicate
+			results[pos].Error = ErrUserAlreadyExists.Error()
+		default:
+			results[pos].Status = ImportInvalid
+			results[pos].Error = rowErr.Error()
+		}
+	}
+	return nil
+}
+
+// ExportUsers передает всех пользователей в fn по одному, читая их из хранилища порциями
+func (s *UserService) ExportUsers(ctx context.Context, fn func(user *entity.User) error) error {
+	return s.userRepo.FindInBatches(ctx, ImportBatchSize, func(users []entity.User) error {
+		for i := range users {
+			if err := fn(&users[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"multilayer/internal/entity"
+)
+
+// MaxLookupIdentifiers - наибольшее число username и email в одном вызове LookupUsers
+const MaxLookupIdentifiers = 1000
+
+var ErrLookupTooLarge = fmt.Errorf("lookup must contain at most %d identifiers", MaxLookupIdentifiers)
+
+// UserLookupResult сопоставляет запрошенные username и email (в том виде, в каком
+// их передали) с найденными пользователями; ненайденные значения отсутствуют
+type UserLookupResult struct {
+	ByUsername map[string]*entity.User
+	ByEmail    map[string]*entity.User
+}
+
+// GetUserByUsername ищет пользователя по username без учета регистра
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*entity.User, error) {
+	user, err := s.userRepo.FindByUsername(ctx, username)
+	if err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	return user, nil
+}
+
+// GetUserByEmail ищет пользователя по email без учета регистра
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	return user, nil
+}
+
+// LookupUsers находит пользователей по списку username и email одним запросом.
+// Значения сравниваются так же, как в GetUserByUsername и GetUserByEmail
+func (s *UserService) LookupUsers(ctx context.Context, usernames, emails []string) (*UserLookupResult, error) {
+	if len(usernames)+len(emails) > MaxLookupIdentifiers {
+		return nil, ErrLookupTooLarge
+	}
+	users, err := s.userRepo.FindByUsernamesOrEmails(ctx, usernames, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	byUsernameKey := make(map[string]*entity.User, len(users))
+	byEmailKey := make(map[string]*entity.User, len(users))
+	for i := range users {
+		byUsernameKey[users[i].UsernameKey] = &users[i]
+		byEmailKey[users[i].EmailKey] = &users[i]
+	}
+
+	result := &UserLookupResult{
+		ByUsername: make(map[string]*entity.User, len(usernames)),
+		ByEmail:    make(map[string]*entity.User, len(emails)),
+	}
+	for _, username := range usernames {
+		if user, ok := byUsernameKey[entity.CanonicalUsername(username)]; ok {
+			result.ByUsername[username] = user
+		}
+	}
+	for _, email := range emails {
+		if user, ok := byEmailKey[s.userRepo.EmailKey(email)]; ok {
+			result.ByEmail[email] = user
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"multilayer/internal/entity"
+	"multilayer/internal/search"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxSearchQueryLength - ограничение длины поискового запроса в символах
+const MaxSearchQueryLength = 100
+
+// UserSearchResult - найденный пользователь, релевантность от 0 до 1 и поля
+// с совпадениями, выделенными тегами <mark> (остальной текст экранирован для HTML)
+type UserSearchResult struct {
+	User       entity.User
+	Score      float64
+	Highlights map[string]string
+}
+
+// SearchUsers ищет пользователей по частичному совпадению или с опечатками в
+// username, display_name и email; более релевантные результаты идут первыми
+func (s *UserService) SearchUsers(ctx context.Context, query string, limit int) ([]UserSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, &entity.ValidationError{Field: "q", Message: "search query is required"}
+	}
+	if utf8.RuneCountInString(query) > MaxSearchQueryLength {
+		return nil, &entity.ValidationError{Field: "q", Message: "search query is too long"}
+	}
+
+	hits, err := s.userRepo.Search(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]UserSearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = UserSearchResult{User: hit.User, Score: hit.Score, Highlights: map[string]string{}}
+		fields := map[string]string{"username": hit.User.Username, "display_name": hit.User.DisplayName, "email": hit.User.Email}
+		for name, value := range fields {
+			if highlighted, ok := search.Highlight(value, query); ok {
+				results[i].Highlights[name] = highlighted
+			}
+		}
+	}
+	return results, nil
+}
@@ -2,49 +2,219 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"multilayer/internal/entity"
 	"multilayer/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Доменные ошибки сервиса
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserAlreadyExists = errors.New("user with this username or email already exists")
 )
 
 type UserServiceInterface interface {
-	UpdateUser(id uint, username, email string) (*entity.User, error)
-	RegisterUser(username, email string) (*entity.User, error)
-	GetUser(id uint) (*entity.User, error)
+	UpdateUser(ctx context.Context, id uint, username, email string) (*entity.User, error)
+	RegisterUser(ctx context.Context, username, email string) (*entity.User, error)
+	UpdateProfile(ctx context.Context, id uint, profile entity.UserProfile) (*entity.User, error)
+	UpsertUserByEmail(ctx context.Context, email, username string, profile entity.UserProfile) (*entity.User, bool, error)
+	GetUser(ctx context.Context, id uint) (*entity.User, error)
+	GetUserAt(ctx context.Context, id uint, at time.Time) (*entity.User, error)
+	GetUsersByIDs(ctx context.Context, ids []uint) ([]entity.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*entity.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*entity.User, error)
+	LookupUsers(ctx context.Context, usernames, emails []string) (*UserLookupResult, error)
+	CheckAvailability(ctx context.Context, username, email string) (*Availability, error)
+	ListUsers(ctx context.Context, filter repository.UserFilter, afterID uint, limit int) ([]entity.User, error)
+	DeleteUser(ctx context.Context, id uint) error
+	ChangeUserStatus(ctx context.Context, id uint, status entity.UserStatus, reason string) (*entity.User, error)
+	AuthenticateUser(ctx context.Context, id uint) (*entity.User, error)
+	ImportUsers(ctx context.Context, rows []ImportRow) ([]ImportResult, error)
+	BatchUsers(ctx context.Context, mode BatchMode, ops []BatchOperation) ([]BatchResult, error)
+	ExportUsers(ctx context.Context, fn func(user *entity.User) error) error
+	GetUserHistory(ctx context.Context, id, beforeID uint, limit int) ([]entity.UserAuditEntry, error)
+	SearchUsers(ctx context.Context, query string, limit int) ([]UserSearchResult, error)
 }
 
 type UserService struct {
-	userRepo repository.UserRepositoryInterface // Используем интерфейс
+	userRepo       repository.UserRepositoryInterface // Используем интерфейс
+	UsernamePolicy *entity.UsernamePolicy             // правила выбора username для развертывания
+	EmailValidator *EmailValidator                    // проверки email сверх синтаксиса; nil отключает их
+	// Transactions объединяет чтение и запись пользователя в одну транзакцию;
+	// nil - каждый вызов репозитория в своей транзакции
+	Transactions repository.Transactor
+}
+
+func NewUserService(userRepo repository.UserRepositoryInterface) *UserService {
+	return &UserService{userRepo: userRepo, UsernamePolicy: entity.DefaultUsernamePolicy(), EmailValidator: NewEmailValidator()}
+}
+
+func (s *UserService) UpdateUser(ctx context.Context, id uint, username, email string) (*entity.User, error) {
+	// Проверки email обращаются к DNS и спискам доменов тенанта и не должны
+	// держать транзакцию: адрес проверяется до нее, а результат применяется,
+	// только если адрес меняется
+	emailErr := s.validateEmail(ctx, entity.NormalizeEmail(email))
+
+	var user *entity.User
+	err := s.inTransaction(ctx, func(ctx context.Context) error {
+		// Сначала получаем пользователя
+		var err error
+		user, err = s.userRepo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// Обновляем поля с валидацией
+		previousEmail := user.Email
+		if err := user.Update(username, email, s.UsernamePolicy); err != nil {
+			return err
+		}
+		// Правила для email применяются только к новому адресу
+		if s.userRepo.EmailKey(user.Email) != s.userRepo.EmailKey(previousEmail) && emailErr != nil {
+			return emailErr
+		}
+
+		// Сохраняем изменения
+		return s.userRepo.Update(ctx, user)
+	})
+	if err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	return user, nil
+}
+
+// UpdateProfile заменяет поля профиля пользователя
+func (s *UserService) UpdateProfile(ctx context.Context, id uint, profile entity.UserProfile) (*entity.User, error) {
+	var user *entity.User
+	err := s.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.userRepo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := user.UpdateProfile(profile); err != nil {
+			return err
+		}
+		return s.userRepo.Update(ctx, user)
+	})
+	if err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	return user, nil
+}
+
+// UpsertUserByEmail приводит пользователя с email к переданным username и профилю:
+// создает его, если такого email нет, иначе заменяет поля. Пустые locale и
+// timezone заменяются значениями по умолчанию. Возвращает true, если пользователь создан
+func (s *UserService) UpsertUserByEmail(ctx context.Context, email, username string, profile entity.UserProfile) (*entity.User, bool, error) {
+	user := &entity.User{
+		Username: entity.NormalizeUsername(username),
+		Email:    entity.NormalizeEmail(email),
+	}
+	if err := user.UpdateProfile(profile); err != nil {
+		return nil, false, err
+	}
+	if user.Locale == "" {
+		user.Locale = entity.DefaultLocale
+	}
+	if user.Timezone == "" {
+		user.Timezone = entity.DefaultTimezone
+	}
+	if err := user.Validate(s.UsernamePolicy); err != nil {
+		return nil, false, err
+	}
+	if err := s.validateEmail(ctx, user.Email); err != nil {
+		return nil, false, err
+	}
+
+	created, err := s.userRepo.UpsertByEmail(ctx, user)
+	if err != nil {
+		return nil, false, mapRepositoryError(err)
+	}
+	return user, created, nil
 }
 
-func NewUserService(userRepo *repository.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+// inTransaction выполняет fn в транзакции Transactions, если она задана
+func (s *UserService) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.Transactions == nil {
+		return fn(ctx)
+	}
+	return s.Transactions.Transaction(ctx, fn)
 }
 
-func (s *UserService) UpdateUser(id uint, username, email string) (*entity.User, error) {
-	// Сначала получаем пользователя
-	user, err := s.userRepo.FindByID(id)
+func (s *UserService) RegisterUser(ctx context.Context, username, email string) (*entity.User, error) {
+	user, err := entity.NewUser(username, email, s.UsernamePolicy)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.validateEmail(ctx, user.Email); err != nil {
+		return nil, err
+	}
+	err = s.userRepo.Create(ctx, user)
+	return user, mapRepositoryError(err)
+}
+
+func (s *UserService) GetUser(ctx context.Context, id uint) (*entity.User, error) {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	return user, nil
+}
+
+// GetUserAt возвращает пользователя в состоянии на момент at
+func (s *UserService) GetUserAt(ctx context.Context, id uint, at time.Time) (*entity.User, error) {
+	user, err := s.userRepo.FindVersionAt(ctx, id, at)
+	if err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	return user, nil
+}
 
-	// Обновляем поля
-	user.Username = username
-	user.Email = email
+// GetUsersByIDs загружает несколько пользователей одним запросом
+func (s *UserService) GetUsersByIDs(ctx context.Context, ids []uint) ([]entity.User, error) {
+	return s.userRepo.FindByIDs(ctx, ids)
+}
 
-	// Сохраняем изменения
-	err = s.userRepo.Update(user)
-	return user, err
+// ListUsers возвращает страницу пользователей, следующих за afterID
+func (s *UserService) ListUsers(ctx context.Context, filter repository.UserFilter, afterID uint, limit int) ([]entity.User, error) {
+	return s.userRepo.List(ctx, filter, afterID, limit)
 }
 
-func (s *UserService) RegisterUser(username, email string) (*entity.User, error) {
-	user := &entity.User{
-		Username: username,
-		Email:    email,
+func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
+	return mapRepositoryError(s.userRepo.Delete(ctx, id))
+}
+
+// GetUserHistory возвращает журнал изменений пользователя, новые записи первыми.
+// История удаленного пользователя остается доступной
+func (s *UserService) GetUserHistory(ctx context.Context, id, beforeID uint, limit int) ([]entity.UserAuditEntry, error) {
+	entries, err := s.userRepo.ListHistory(ctx, id, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 && beforeID == 0 {
+		// Пустой журнал: различаем пользователя без истории и несуществующего
+		if _, err := s.userRepo.FindByID(ctx, id); err != nil {
+			return nil, mapRepositoryError(err)
+		}
 	}
-	err := s.userRepo.Create(user)
-	return user, err
+	return entries, nil
 }
 
-func (s *UserService) GetUser(id uint) (*entity.User, error) {
-	return s.userRepo.FindByID(id)
+// mapRepositoryError переводит ошибки хранилища в доменные ошибки сервиса
+func mapRepositoryError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrUserAlreadyExists
+	default:
+		return err
+	}
 }
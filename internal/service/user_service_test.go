@@ -1,10 +1,19 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"multilayer/internal/cache"
 	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"strings"
 	"testing"
+	"time"
 )
 
 // MockUserRepository должен реализовывать интерфейс репозитория
@@ -12,21 +21,113 @@ type MockUserRepository struct {
 	mock.Mock
 }
 
-func (m *MockUserRepository) Create(user *entity.User) error {
+func (m *MockUserRepository) Create(_ context.Context, user *entity.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) FindByID(id uint) (*entity.User, error) {
+func (m *MockUserRepository) FindByID(_ context.Context, id uint) (*entity.User, error) {
 	args := m.Called(id)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Update(user *entity.User) error {
+func (m *MockUserRepository) FindVersionAt(_ context.Context, id uint, at time.Time) (*entity.User, error) {
+	args := m.Called(id, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Update(_ context.Context, user *entity.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Delete(_ context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) FindByIDs(_ context.Context, ids []uint) ([]entity.User, error) {
+	args := m.Called(ids)
+	return args.Get(0).([]entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) CreateInBatches(_ context.Context, users []*entity.User, batchSize int) error {
+	args := m.Called(users, batchSize)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateBatch(_ context.Context, users []*entity.User) error {
+	args := m.Called(users)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DeleteBatch(_ context.Context, ids []uint) error {
+	args := m.Called(ids)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpsertByEmail(_ context.Context, user *entity.User) (bool, error) {
 	args := m.Called(user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) FindByUsername(_ context.Context, username string) (*entity.User, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) FindByEmail(_ context.Context, email string) (*entity.User, error) {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+// EmailKey вычисляет ключ по политике по умолчанию, как репозиторий без EmailPolicy
+func (m *MockUserRepository) EmailKey(email string) string {
+	return entity.CanonicalEmail(email, nil)
+}
+
+func (m *MockUserRepository) FindByUsernamesOrEmails(_ context.Context, usernames, emails []string) ([]entity.User, error) {
+	args := m.Called(usernames, emails)
+	return args.Get(0).([]entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) CreateBatch(_ context.Context, users []*entity.User) ([]error, error) {
+	args := m.Called(users)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]error), args.Error(1)
+}
+
+func (m *MockUserRepository) FindInBatches(_ context.Context, batchSize int, fn func(users []entity.User) error) error {
+	args := m.Called(batchSize, fn)
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) List(_ context.Context, filter repository.UserFilter, afterID uint, limit int) ([]entity.User, error) {
+	args := m.Called(filter, afterID, limit)
+	return args.Get(0).([]entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) ListHistory(_ context.Context, userID, beforeID uint, limit int) ([]entity.UserAuditEntry, error) {
+	args := m.Called(userID, beforeID, limit)
+	return args.Get(0).([]entity.UserAuditEntry), args.Error(1)
+}
+
+func (m *MockUserRepository) Search(_ context.Context, query string, limit int) ([]repository.UserSearchHit, error) {
+	args := m.Called(query, limit)
+	return args.Get(0).([]repository.UserSearchHit), args.Error(1)
+}
+
 func TestUserService_UpdateUser(t *testing.T) {
 	// Создаем mock репозитория
 	mockRepo := new(MockUserRepository)
@@ -48,7 +149,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 	mockRepo.On("Update", mock.AnythingOfType("*entity.User")).Return(nil)
 
 	// Вызываем метод
-	updatedUser, err := service.UpdateUser(1, "new", "new@example.com")
+	updatedUser, err := service.UpdateUser(context.Background(), 1, "new", "new@example.com")
 
 	// Проверяем результаты
 	assert.NoError(t, err)
@@ -56,3 +157,255 @@ func TestUserService_UpdateUser(t *testing.T) {
 	assert.Equal(t, "new@example.com", updatedUser.Email)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestUserService_RegisterUser_Validation(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := &UserService{
+		userRepo: mockRepo,
+	}
+
+	// Невалидные данные не должны доходить до репозитория
+	user, err := service.RegisterUser(context.Background(), "ab", "invalid-email")
+
+	var validationErr *entity.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Nil(t, user)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestUserService_UpsertUserByEmail(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	mockRepo.On("UpsertByEmail", mock.AnythingOfType("*entity.User")).Return(true, nil).Once()
+	user, created, err := service.UpsertUserByEmail(context.Background(), " Alice@Example.com ", "alice", entity.UserProfile{
+		DisplayName: " Alice ",
+		Locale:      "en-us",
+	})
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "Alice@example.com", user.Email)
+	assert.Equal(t, "Alice", user.DisplayName)
+	assert.Equal(t, "en-US", user.Locale)
+	assert.Equal(t, entity.DefaultTimezone, user.Timezone)
+
+	mockRepo.On("UpsertByEmail", mock.AnythingOfType("*entity.User")).Return(false, gorm.ErrDuplicatedKey).Once()
+	_, _, err = service.UpsertUserByEmail(context.Background(), "bob@example.com", "alice", entity.UserProfile{})
+	assert.ErrorIs(t, err, ErrUserAlreadyExists)
+
+	// Невалидные данные не должны доходить до репозитория
+	var validationErr *entity.ValidationError
+	_, _, err = service.UpsertUserByEmail(context.Background(), "carol@example.com", "carol", entity.UserProfile{Timezone: "Mars/Olympus"})
+	assert.ErrorAs(t, err, &validationErr)
+	_, _, err = service.UpsertUserByEmail(context.Background(), "dave@mailinator.com", "dave", entity.UserProfile{})
+	assert.ErrorAs(t, err, &validationErr)
+	mockRepo.AssertNumberOfCalls(t, "UpsertByEmail", 2)
+}
+
+func TestUserService_LookupUsers(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := &UserService{
+		userRepo: mockRepo,
+	}
+
+	alice := entity.User{ID: 1, Username: "Alice", Email: "alice@example.com", UsernameKey: "alice", EmailKey: "alice@example.com"}
+	bob := entity.User{ID: 2, Username: "bob", Email: "bob@example.com", UsernameKey: "bob", EmailKey: "bob@example.com"}
+	usernames := []string{"ALICE", "ghost"}
+	emails := []string{"Bob@Example.com", "alice@example.com"}
+	mockRepo.On("FindByUsernamesOrEmails", usernames, emails).Return([]entity.User{alice, bob}, nil)
+
+	result, err := service.LookupUsers(context.Background(), usernames, emails)
+	assert.NoError(t, err)
+	// Ключи - значения в том виде, в каком их запросили
+	assert.Equal(t, uint(1), result.ByUsername["ALICE"].ID)
+	assert.NotContains(t, result.ByUsername, "ghost")
+	assert.Equal(t, uint(2), result.ByEmail["Bob@Example.com"].ID)
+	assert.Equal(t, uint(1), result.ByEmail["alice@example.com"].ID)
+	mockRepo.AssertNumberOfCalls(t, "FindByUsernamesOrEmails", 1)
+
+	_, err = service.LookupUsers(context.Background(), make([]string, MaxLookupIdentifiers), []string{"x@example.com"})
+	assert.ErrorIs(t, err, ErrLookupTooLarge)
+}
+
+func TestUserService_GetUserByUsername_NotFound(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := &UserService{
+		userRepo: mockRepo,
+	}
+
+	mockRepo.On("FindByUsername", "ghost").Return(nil, gorm.ErrRecordNotFound)
+
+	user, err := service.GetUserByUsername(context.Background(), "ghost")
+
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.Nil(t, user)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_CheckAvailability(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+	ctx := context.Background()
+
+	// Все проверяемые значения уходят в репозиторий одним запросом
+	mockRepo.On("FindByUsernamesOrEmails", mock.Anything, mock.Anything).Return([]entity.User{
+		{Username: "alice", UsernameKey: "alice", Email: "alice@example.com", EmailKey: "alice@example.com"},
+		{Username: "Alice1", UsernameKey: "alice1", Email: "a1@example.com", EmailKey: "a1@example.com"},
+	}, nil)
+
+	t.Run("Taken", func(t *testing.T) {
+		availability, err := service.CheckAvailability(ctx, " ALICE ", "Alice@Example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, &FieldAvailability{
+			Value:       "ALICE",
+			Reason:      AvailabilityTaken,
+			Error:       errUsernameTaken.Error(),
+			Suggestions: []string{"ALICE2", "ALICE3", "ALICE4"},
+		}, availability.Username)
+		assert.False(t, availability.Email.Available)
+		assert.Equal(t, AvailabilityTaken, availability.Email.Reason)
+	})
+
+	t.Run("Available", func(t *testing.T) {
+		availability, err := service.CheckAvailability(ctx, "bob", "")
+		assert.NoError(t, err)
+		assert.True(t, availability.Username.Available)
+		assert.Empty(t, availability.Username.Suggestions)
+		assert.Nil(t, availability.Email)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		availability, err := service.CheckAvailability(ctx, "admin", "bob@mailinator.com")
+		assert.NoError(t, err)
+		assert.Equal(t, AvailabilityInvalid, availability.Username.Reason)
+		assert.Equal(t, []string{"admin1", "admin2", "admin3"}, availability.Username.Suggestions)
+		assert.Equal(t, AvailabilityInvalid, availability.Email.Reason)
+		assert.NotEmpty(t, availability.Email.Error)
+	})
+
+	t.Run("Suggestions respect max length", func(t *testing.T) {
+		long := strings.Repeat("a", service.UsernamePolicy.MaxLength)
+		availability, err := service.CheckAvailability(ctx, long+"a", "")
+		assert.NoError(t, err)
+		assert.Equal(t, AvailabilityInvalid, availability.Username.Reason)
+		assert.Equal(t, long[:len(long)-1]+"1", availability.Username.Suggestions[0])
+	})
+}
+
+func TestUserService_ChangeUserStatus(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := &UserService{
+		userRepo: mockRepo,
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(&entity.User{ID: 1, Status: entity.UserActive}, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(user *entity.User) bool {
+		return user.Status == entity.UserSuspended && user.StatusReason == "spam"
+	})).Return(nil).Once()
+
+	user, err := service.ChangeUserStatus(context.Background(), 1, entity.UserSuspended, " spam ")
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.UserSuspended, user.Status)
+	assert.NotNil(t, user.StatusChangedAt)
+	mockRepo.AssertExpectations(t)
+
+	// Запрещенный переход не доходит до записи
+	mockRepo.On("FindByID", uint(2)).Return(&entity.User{ID: 2, Status: entity.UserDeactivated}, nil)
+	_, err = service.ChangeUserStatus(context.Background(), 2, entity.UserLocked, "x")
+	assert.ErrorIs(t, err, entity.ErrInvalidStatusTransition)
+	mockRepo.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func TestUserService_AuthenticateUser(t *testing.T) {
+	tests := []struct {
+		status  entity.UserStatus
+		wantErr error
+	}{
+		{status: entity.UserActive},
+		{status: entity.UserLocked, wantErr: ErrUserInactive},
+		{status: entity.UserSuspended, wantErr: ErrUserInactive},
+		{status: entity.UserDeactivated, wantErr: ErrUserInactive},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			service := &UserService{
+				userRepo: mockRepo,
+			}
+			mockRepo.On("FindByID", uint(1)).Return(&entity.User{ID: 1, Status: tt.status}, nil)
+
+			user, err := service.AuthenticateUser(context.Background(), 1)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantErr == nil, user != nil)
+		})
+	}
+}
+
+func TestUserService_AuthenticateUser_Replicas(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}))
+
+	// Два экземпляра сервиса над одной базой, у каждого свой локальный кэш
+	replica := func() *UserService {
+		return NewUserService(repository.NewCachedUserRepository(repository.NewUserRepository(db), cache.NewLRU(100), time.Hour))
+	}
+	first, second := replica(), replica()
+
+	user, err := first.RegisterUser(tenantContext(), "alice", "alice@example.com")
+	require.NoError(t, err)
+	_, err = second.GetUser(tenantContext(), user.ID) // пользователь попал в кэш второго
+	require.NoError(t, err)
+
+	_, err = first.ChangeUserStatus(tenantContext(), user.ID, entity.UserSuspended, "spam")
+	require.NoError(t, err)
+
+	_, err = second.AuthenticateUser(tenantContext(), user.ID)
+	assert.ErrorIs(t, err, ErrUserInactive)
+}
+
+func TestUserService_GetUser_NotFound(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := &UserService{
+		userRepo: mockRepo,
+	}
+
+	mockRepo.On("FindByID", uint(42)).Return(&entity.User{}, gorm.ErrRecordNotFound)
+
+	user, err := service.GetUser(context.Background(), 42)
+
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.Nil(t, user)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_ImportUsers(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := &UserService{
+		userRepo: mockRepo,
+	}
+
+	// Строка с невалидным email не передается в репозиторий
+	mockRepo.On("CreateBatch", mock.AnythingOfType("[]*entity.User")).Run(func(args mock.Arguments) {
+		users := args.Get(0).([]*entity.User)
+		users[0].ID = 10
+	}).Return([]error{nil, gorm.ErrDuplicatedKey}, nil)
+
+	results, err := service.ImportUsers(context.Background(), []ImportRow{
+		{Row: 1, Username: "alice", Email: "alice@example.com"},
+		{Row: 2, Username: "bob", Email: "not-an-email"},
+		{Row: 3, Username: "alice", Email: "alice2@example.com"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ImportResult{
+		{Row: 1, Status: ImportCreated, UserID: 10},
+		{Row: 2, Status: ImportInvalid, Error: "invalid email format"},
+		{Row: 3, Status: ImportDuplicate, Error: ErrUserAlreadyExists.Error()},
+	}, results)
+	mockRepo.AssertNumberOfCalls(t, "CreateBatch", 1)
+}
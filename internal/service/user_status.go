@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multilayer/internal/entity"
+	"multilayer/internal/repository"
+	"time"
+)
+
+// ErrUserInactive - статус пользователя не позволяет ему входить в систему
+var ErrUserInactive = errors.New("user account is not active")
+
+// ChangeUserStatus переводит пользователя в статус status по правилам
+// entity.User.TransitionTo; запрещенный переход дает entity.ErrInvalidStatusTransition
+func (s *UserService) ChangeUserStatus(ctx context.Context, id uint, status entity.UserStatus, reason string) (*entity.User, error) {
+	var user *entity.User
+	err := s.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.userRepo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := user.TransitionTo(status, reason, time.Now()); err != nil {
+			return err
+		}
+		return s.userRepo.Update(ctx, user)
+	})
+	if err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	return user, nil
+}
+
+// AuthenticateUser проверяет, что пользователь из токена существует и может
+// входить в систему; для suspended, locked и deactivated возвращает ErrUserInactive.
+// Статус читается из базы мимо кэша: блокировка через другой экземпляр сервиса
+// должна действовать сразу, а не по истечении TTL его локального кэша
+func (s *UserService) AuthenticateUser(ctx context.Context, id uint) (*entity.User, error) {
+	user, err := s.userRepo.FindByID(repository.BypassCache(ctx), id)
+	if err != nil {
+		return nil, mapRepositoryError(err)
+	}
+	if !user.CanAuthenticate() {
+		return nil, fmt.Errorf("%w: %s", ErrUserInactive, user.EffectiveStatus())
+	}
+	return user, nil
+}
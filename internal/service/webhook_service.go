@@ -0,0 +1,354 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"multilayer/internal/entity"
+	"multilayer/internal/outbox"
+	"multilayer/internal/repository"
+	"multilayer/internal/tenancy"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Заголовки исходящих вебхуков
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Доменные ошибки вебхуков
+var (
+	ErrWebhookNotFound  = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+	// ErrWebhookDestination - адрес получателя не публичный (loopback, частная сеть, метаданные облака)
+	ErrWebhookDestination = errors.New("webhook destination address is not allowed")
+)
+
+type WebhookServiceInterface interface {
+	CreateSubscription(ctx context.Context, url string, eventTypes []string, secret string) (*entity.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id uint) (*entity.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uint) error
+	ListDeliveries(ctx context.Context, subscriptionID, beforeID uint, limit int) ([]entity.WebhookDelivery, error)
+	Redeliver(ctx context.Context, subscriptionID, deliveryID uint) (*entity.WebhookDelivery, error)
+}
+
+// WebhookConfig задает политику повторов; нулевые значения заменяются значениями по умолчанию
+type WebhookConfig struct {
+	MaxAttempts int           // попыток до перевода в dead, по умолчанию 8
+	BaseBackoff time.Duration // задержка после первой неудачи, по умолчанию 30s
+	MaxBackoff  time.Duration // верхняя граница задержки, по умолчанию 1h
+	Timeout     time.Duration // таймаут одного запроса, по умолчанию 10s
+	BatchSize   int           // доставок за один проход воркера, по умолчанию 50
+	// ClaimTimeout - на сколько забранные доставки скрываются от других воркеров,
+	// по умолчанию Timeout * BatchSize (худший по времени проход)
+	ClaimTimeout time.Duration
+	// AllowPrivateDestinations разрешает адреса loopback и частных сетей; только для разработки
+	AllowPrivateDestinations bool
+}
+
+// WebhookService управляет подписками, раскладывает события outbox по
+// подпискам (реализует outbox.Sink) и доставляет их с повторами
+type WebhookService struct {
+	webhookRepo repository.WebhookRepositoryInterface
+	client      *http.Client
+	config      WebhookConfig
+	now         func() time.Time
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepositoryInterface, config WebhookConfig) *WebhookService {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Hour
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+	if config.ClaimTimeout <= 0 {
+		config.ClaimTimeout = config.Timeout * time.Duration(config.BatchSize)
+	}
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		client:      newWebhookClient(config.Timeout, config.AllowPrivateDestinations),
+		config:      config,
+		now:         time.Now,
+	}
+}
+
+// CreateSubscription создает подписку; пустой secret генерируется автоматически
+func (s *WebhookService) CreateSubscription(ctx context.Context, url string, eventTypes []string, secret string) (*entity.WebhookSubscription, error) {
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return nil, err
+		}
+	}
+	subscription, err := entity.NewWebhookSubscription(url, eventTypes, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !s.config.AllowPrivateDestinations {
+		if err := checkWebhookHost(subscription.URL); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.webhookRepo.CreateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *WebhookService) GetSubscription(ctx context.Context, id uint) (*entity.WebhookSubscription, error) {
+	subscription, err := s.webhookRepo.FindSubscription(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	return s.webhookRepo.ListSubscriptions(ctx)
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id uint) error {
+	err := s.webhookRepo.DeleteSubscription(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// ListDeliveries возвращает журнал доставок подписки, новые первыми
+func (s *WebhookService) ListDeliveries(ctx context.Context, subscriptionID, beforeID uint, limit int) ([]entity.WebhookDelivery, error) {
+	if _, err := s.GetSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.ListDeliveries(ctx, subscriptionID, beforeID, limit)
+}
+
+// Redeliver ставит доставку (в том числе dead) в очередь на немедленную отправку
+func (s *WebhookService) Redeliver(ctx context.Context, subscriptionID, deliveryID uint) (*entity.WebhookDelivery, error) {
+	if _, err := s.GetSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	delivery, err := s.webhookRepo.FindDelivery(ctx, subscriptionID, deliveryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	delivery.Redeliver(s.now())
+	if err := s.webhookRepo.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Publish создает доставки события для подходящих подписок тенанта события.
+// Вызывается релеем outbox; повторный вызов для того же события доставки не дублирует
+func (s *WebhookService) Publish(ctx context.Context, envelope outbox.Envelope) error {
+	ctx = tenancy.WithTenant(ctx, envelope.TenantID)
+	subscriptions, err := s.webhookRepo.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	var deliveries []entity.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(envelope.Type) {
+			continue
+		}
+		deliveries = append(deliveries, entity.WebhookDelivery{
+			TenantID:       subscription.TenantID,
+			SubscriptionID: subscription.ID,
+			EventID:        envelope.ID,
+			EventType:      envelope.Type,
+			Payload:        payload,
+			Status:         entity.DeliveryPending,
+			NextAttemptAt:  now,
+		})
+	}
+	return s.webhookRepo.CreateDeliveries(ctx, deliveries)
+}
+
+// RunDeliveryWorker отправляет наступившие доставки каждые interval до отмены ctx
+func (s *WebhookService) RunDeliveryWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.DeliverDue(ctx); err != nil {
+			log.Printf("webhook worker: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue выполняет одну попытку для каждой наступившей доставки и возвращает их число.
+// Доставки забираются с блокировкой (см. ClaimDueDeliveries), поэтому несколько
+// реплик не отправляют одно событие дважды. Доставка удаленной подписки переводится
+// в dead и не мешает остальным
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.webhookRepo.ClaimDueDeliveries(ctx, s.now(), s.config.BatchSize, s.config.ClaimTimeout)
+	if err != nil {
+		return 0, err
+	}
+
+	subscriptions := make(map[uint]*entity.WebhookSubscription)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		tenantCtx := tenancy.WithTenant(ctx, delivery.TenantID)
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			subscription, err = s.webhookRepo.FindSubscription(tenantCtx, delivery.SubscriptionID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				subscription = nil
+			} else if err != nil {
+				return i, err
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+
+		if subscription == nil {
+			delivery.MarkDead(ErrWebhookNotFound.Error(), s.now())
+		} else if statusCode, sendErr := s.send(ctx, subscription, delivery); sendErr == nil {
+			delivery.MarkSucceeded(statusCode, s.now())
+		} else {
+			delivery.MarkFailed(statusCode, sendErr.Error(), s.now(), s.config.MaxAttempts, s.backoff(delivery.Attempts+1))
+		}
+		if err := s.webhookRepo.UpdateDelivery(tenantCtx, delivery); err != nil {
+			return i, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// send выполняет подписанный POST и возвращает код ответа (0 при сетевой ошибке)
+func (s *WebhookService) send(ctx context.Context, subscription *entity.WebhookSubscription, delivery *entity.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := s.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+entity.SignWebhookPayload(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff возвращает задержку после attempts-й неудачи: BaseBackoff * 2^(attempts-1), не больше MaxBackoff
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.config.BaseBackoff
+	for i := 1; i < attempts && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.config.MaxBackoff)
+}
+
+// checkWebhookHost отклоняет URL, хост которого - непубличный IP или localhost.
+// Имена, разрешающиеся в такие адреса, отсекаются при соединении (newWebhookClient)
+func checkWebhookHost(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return &entity.ValidationError{Field: "url", Message: err.Error()}
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &entity.ValidationError{Field: "url", Message: ErrWebhookDestination.Error()}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !publicAddress(addr) {
+		return &entity.ValidationError{Field: "url", Message: ErrWebhookDestination.Error()}
+	}
+	return nil
+}
+
+// newWebhookClient создает HTTP-клиент, который проверяет каждый фактический адрес
+// соединения - в том числе после DNS и редиректов
+func newWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddress(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrWebhookDestination, addrPort.Addr())
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// cgnatPrefix - разделяемое адресное пространство провайдеров (RFC 6598)
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress сообщает, можно ли отправлять вебхуки на адрес: loopback, частные,
+// link-local (включая 169.254.169.254) и служебные диапазоны запрещены
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !cgnatPrefix.Contains(addr)
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
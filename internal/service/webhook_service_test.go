@@ -0,0 +1,247 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"multilayer/internal/entity"
+	"multilayer/internal/outbox"
+	"multilayer/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// webhookReceiver - локальный получатель, проверяющий подпись и отвечающий
+// кодами из очереди responses (после ее исчерпания - 200)
+type webhookReceiver struct {
+	mu        sync.Mutex
+	secret    string
+	responses []int
+	received  []string
+	invalid   int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	unix, _ := strconv.ParseInt(req.Header.Get(WebhookTimestampHeader), 10, 64)
+	signature := strings.TrimPrefix(req.Header.Get(WebhookSignatureHeader), "sha256=")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !entity.VerifyWebhookSignature(r.secret, time.Unix(unix, 0), body, signature) {
+		r.invalid++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	r.received = append(r.received, req.Header.Get(WebhookEventHeader))
+	status := http.StatusOK
+	if len(r.responses) > 0 {
+		status, r.responses = r.responses[0], r.responses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+const testWebhookSecret = "0123456789abcdef"
+
+func setupWebhookService(t *testing.T, receiver *webhookReceiver) (*WebhookService, *entity.WebhookSubscription, *time.Time) {
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.WebhookSubscription{}, &entity.WebhookDelivery{}))
+
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	svc := NewWebhookService(repository.NewWebhookRepository(db), WebhookConfig{
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+		// Получатель слушает на loopback
+		AllowPrivateDestinations: true,
+	})
+	// Управляемые часы позволяют проверить расписание повторов без ожидания
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	subscription, err := svc.CreateSubscription(tenantContext(), server.URL, []string{string(entity.UserRegistered)}, testWebhookSecret)
+	require.NoError(t, err)
+	return svc, subscription, &now
+}
+
+func testEnvelope(id, eventType string) outbox.Envelope {
+	return outbox.Envelope{ID: id, Type: eventType, TenantID: entity.DefaultTenantID, AggregateID: 1, OccurredAt: time.Now(), Data: []byte(`{"id":1}`)}
+}
+
+func TestWebhookService_DeliversSignedEvent(t *testing.T) {
+	receiver := &webhookReceiver{secret: testWebhookSecret}
+	svc, subscription, _ := setupWebhookService(t, receiver)
+
+	require.NoError(t, svc.Publish(tenantContext(), testEnvelope("evt-1", string(entity.UserRegistered))))
+	// Повторная публикация того же события и неподписанный тип доставок не добавляют
+	require.NoError(t, svc.Publish(tenantContext(), testEnvelope("evt-1", string(entity.UserRegistered))))
+	require.NoError(t, svc.Publish(tenantContext(), testEnvelope("evt-2", string(entity.UserDeleted))))
+	// Событие другого тенанта подпискам этого тенанта не доставляется
+	otherTenant := testEnvelope("evt-3", string(entity.UserRegistered))
+	otherTenant.TenantID = 2
+	require.NoError(t, svc.Publish(tenantContext(), otherTenant))
+
+	processed, err := svc.DeliverDue(tenantContext())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, []string{string(entity.UserRegistered)}, receiver.received)
+	assert.Zero(t, receiver.invalid)
+
+	deliveries, err := svc.ListDeliveries(tenantContext(), subscription.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, entity.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, http.StatusOK, deliveries[0].LastStatusCode)
+}
+
+func TestWebhookService_RetriesWithBackoffUntilDead(t *testing.T) {
+	receiver := &webhookReceiver{secret: testWebhookSecret, responses: []int{500, 502, 503}}
+	svc, subscription, now := setupWebhookService(t, receiver)
+	require.NoError(t, svc.Publish(tenantContext(), testEnvelope("evt-1", string(entity.UserRegistered))))
+
+	_, err := svc.DeliverDue(tenantContext())
+	require.NoError(t, err)
+	deliveries, _ := svc.ListDeliveries(tenantContext(), subscription.ID, 0, 10)
+	assert.Equal(t, entity.DeliveryPending, deliveries[0].Status)
+	assert.WithinDuration(t, now.Add(time.Minute), deliveries[0].NextAttemptAt, time.Second)
+
+	// До наступления времени повтора доставка не отправляется
+	processed, err := svc.DeliverDue(tenantContext())
+	require.NoError(t, err)
+	assert.Zero(t, processed)
+
+	*now = now.Add(time.Minute)
+	_, err = svc.DeliverDue(tenantContext())
+	require.NoError(t, err)
+	deliveries, _ = svc.ListDeliveries(tenantContext(), subscription.ID, 0, 10)
+	assert.WithinDuration(t, now.Add(2*time.Minute), deliveries[0].NextAttemptAt, time.Second)
+
+	*now = now.Add(2 * time.Minute)
+	_, err = svc.DeliverDue(tenantContext())
+	require.NoError(t, err)
+	deliveries, _ = svc.ListDeliveries(tenantContext(), subscription.ID, 0, 10)
+	assert.Equal(t, entity.DeliveryDead, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].LastStatusCode)
+
+	// Ручная переотправка возвращает доставку в очередь
+	_, err = svc.Redeliver(tenantContext(), subscription.ID, deliveries[0].ID)
+	require.NoError(t, err)
+	_, err = svc.DeliverDue(tenantContext())
+	require.NoError(t, err)
+	deliveries, _ = svc.ListDeliveries(tenantContext(), subscription.ID, 0, 10)
+	assert.Equal(t, entity.DeliverySucceeded, deliveries[0].Status)
+	assert.Len(t, receiver.received, 4)
+}
+
+func TestWebhookService_NotFound(t *testing.T) {
+	svc, subscription, _ := setupWebhookService(t, &webhookReceiver{secret: testWebhookSecret})
+
+	_, err := svc.Redeliver(tenantContext(), subscription.ID, 999)
+	assert.ErrorIs(t, err, ErrDeliveryNotFound)
+
+	_, err = svc.ListDeliveries(tenantContext(), 999, 0, 10)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+
+	require.NoError(t, svc.DeleteSubscription(tenantContext(), subscription.ID))
+	assert.ErrorIs(t, svc.DeleteSubscription(tenantContext(), subscription.ID), ErrWebhookNotFound)
+}
+
+func TestWebhookService_RejectsPrivateDestinations(t *testing.T) {
+	svc := NewWebhookService(nil, WebhookConfig{})
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:192.168.1.1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := svc.CreateSubscription(tenantContext(), url, []string{string(entity.UserRegistered)}, testWebhookSecret)
+		var validationErr *entity.ValidationError
+		if assert.ErrorAs(t, err, &validationErr, url) {
+			assert.Equal(t, "url", validationErr.Field)
+		}
+	}
+}
+
+func TestWebhookService_DialerBlocksPrivateAddresses(t *testing.T) {
+	receiver := &webhookReceiver{secret: testWebhookSecret}
+	svc, subscription, _ := setupWebhookService(t, receiver)
+	// Подписка уже сохранена; имя могло начать разрешаться в частный адрес позже
+	svc.client = newWebhookClient(time.Second, false)
+	require.NoError(t, svc.Publish(tenantContext(), testEnvelope("evt-1", string(entity.UserRegistered))))
+
+	_, err := svc.DeliverDue(tenantContext())
+
+	require.NoError(t, err)
+	assert.Empty(t, receiver.received)
+	deliveries, _ := svc.ListDeliveries(tenantContext(), subscription.ID, 0, 10)
+	require.Len(t, deliveries, 1)
+	assert.Contains(t, deliveries[0].LastError, ErrWebhookDestination.Error())
+}
+
+func TestWebhookService_ClaimsDeliveries(t *testing.T) {
+	receiver := &webhookReceiver{secret: testWebhookSecret}
+	svc, subscription, now := setupWebhookService(t, receiver)
+	require.NoError(t, svc.Publish(tenantContext(), testEnvelope("evt-1", string(entity.UserRegistered))))
+
+	// Другой воркер забрал доставку и еще не отметил результат
+	claimed, err := svc.webhookRepo.ClaimDueDeliveries(tenantContext(), *now, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	processed, err := svc.DeliverDue(tenantContext())
+	require.NoError(t, err)
+	assert.Zero(t, processed)
+
+	// Если воркер пропал, доставка возвращается по истечении аренды
+	*now = now.Add(time.Minute)
+	processed, err = svc.DeliverDue(tenantContext())
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	assert.Len(t, receiver.received, 1)
+
+	deliveries, _ := svc.ListDeliveries(tenantContext(), subscription.ID, 0, 10)
+	assert.Equal(t, entity.DeliverySucceeded, deliveries[0].Status)
+}
+
+func TestWebhookService_ParksOrphanedDeliveries(t *testing.T) {
+	receiver := &webhookReceiver{secret: testWebhookSecret}
+	svc, subscription, _ := setupWebhookService(t, receiver)
+	orphan := testEnvelope("evt-1", string(entity.UserRegistered))
+	require.NoError(t, svc.webhookRepo.CreateDeliveries(tenantContext(), []entity.WebhookDelivery{{
+		TenantID:       entity.DefaultTenantID,
+		SubscriptionID: subscription.ID + 100,
+		EventID:        orphan.ID,
+		EventType:      orphan.Type,
+		Status:         entity.DeliveryPending,
+		NextAttemptAt:  time.Now().Add(-time.Minute),
+	}}))
+	require.NoError(t, svc.Publish(tenantContext(), testEnvelope("evt-2", string(entity.UserRegistered))))
+
+	processed, err := svc.DeliverDue(tenantContext())
+
+	// Доставка без подписки не останавливает остальные
+	require.NoError(t, err)
+	assert.Equal(t, 2, processed)
+	assert.Len(t, receiver.received, 1)
+	processed, err = svc.DeliverDue(tenantContext())
+	require.NoError(t, err)
+	assert.Zero(t, processed)
+}
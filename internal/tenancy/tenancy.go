@@ -0,0 +1,40 @@
+// (Контекст тенанта запроса)
+package tenancy
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoTenant - в контексте нет тенанта; запросы к данным тенантов без него
+// не выполняются
+var ErrNoTenant = errors.New("tenant is not set in context")
+
+type tenantKey struct{}
+
+// WithTenant кладет ID тенанта в контекст; все запросы репозиториев с этим
+// контекстом ограничиваются данными тенанта
+func WithTenant(ctx context.Context, tenantID uint) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// FromContext возвращает ID тенанта; false - тенант не задан. Тенанта по
+// умолчанию здесь нет: его явно назначают точки входа (ResolveTenant,
+// TenantInterceptor), а запрос без тенанта должен завершиться ошибкой, а не
+// попасть в чужие данные
+func FromContext(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	tenantID, ok := ctx.Value(tenantKey{}).(uint)
+	return tenantID, ok
+}
+
+// SessionSetting - переменная сессии Postgres с ID тенанта транзакции; по ней
+// политики row-level security отбирают строки (см. migrations)
+const SessionSetting = "app.tenant_id"
+
+// AllTenantsSetting - переменная сессии, со значением on открывающая политикам
+// строки всех тенантов; ее задают только фоновые задачи (релей outbox, доставка
+// вебхуков, очистка ключей идемпотентности)
+const AllTenantsSetting = "app.all_tenants"
@@ -0,0 +1,116 @@
+package tenancy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken - токен не подписан ожидаемым ключом, истек или поврежден
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenVerifier извлекает тенанта и пользователя из claims токена JWT,
+// подписанного HS256 общим секретом. Токены с другим алгоритмом не принимаются
+type TokenVerifier struct {
+	Secret []byte
+	Claim  string // имя claim со slug тенанта, например "tenant"
+}
+
+// Tenant проверяет подпись и срок действия токена и возвращает значение claim;
+// пустая строка - claim в токене нет
+func (v *TokenVerifier) Tenant(token string) (string, error) {
+	claims, err := v.verify(token)
+	if err != nil {
+		return "", err
+	}
+	tenant, _ := claims[v.Claim].(string)
+	return tenant, nil
+}
+
+// Subject проверяет токен так же, как Tenant, и возвращает claim sub -
+// идентификатор пользователя; пустая строка - токен выдан не пользователю
+func (v *TokenVerifier) Subject(token string) (string, error) {
+	claims, err := v.verify(token)
+	if err != nil {
+		return "", err
+	}
+	subject, _ := claims["sub"].(string)
+	return subject, nil
+}
+
+// RoleClaim - claim со списком ролей пользователя
+const RoleClaim = "roles"
+
+// AdminRole - роль, открывающая административные маршруты тенанта
+const AdminRole = "admin"
+
+// PlatformAdminRole - роль оператора сервиса: управление самими тенантами.
+// Выдается отдельно от AdminRole, чтобы администратор тенанта не получал
+// доступ к чужим тенантам
+const PlatformAdminRole = "platform_admin"
+
+// HasRole проверяет токен так же, как Tenant, и сообщает, есть ли role в claim
+// roles: массиве строк или строке с ролями через пробел
+func (v *TokenVerifier) HasRole(token, role string) (bool, error) {
+	claims, err := v.verify(token)
+	if err != nil {
+		return false, err
+	}
+	switch roles := claims[RoleClaim].(type) {
+	case string:
+		return slices.Contains(strings.Fields(roles), role), nil
+	case []any:
+		return slices.Contains(roles, any(role)), nil
+	}
+	return false, nil
+}
+
+// verify проверяет подпись, exp и nbf и возвращает claims токена
+func (v *TokenVerifier) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, v.Secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now().Unix()
+	if exp, ok := claims["exp"].(float64); ok && now >= int64(exp) {
+		return nil, ErrInvalidToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < int64(nbf) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
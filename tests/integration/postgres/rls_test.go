@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"multilayer/internal/audit"
+	"multilayer/internal/entity"
+	"multilayer/internal/migrations"
+	"multilayer/internal/repository"
+	"multilayer/internal/tenancy"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	postgresImage    = "postgres:16-alpine"
+	postgresPassword = "postgres"
+	appRole          = "multilayer_app"
+	appPassword      = "app-password"
+)
+
+// startPostgres запускает контейнер Postgres через docker CLI и возвращает порт
+// на хосте; без Docker тест пропускается
+func startPostgres(t *testing.T) string {
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not available")
+	}
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		t.Skipf("docker daemon is not running: %v", err)
+	}
+
+	output, err := exec.Command("docker", "run", "-d", "--rm",
+		"-e", "POSTGRES_PASSWORD="+postgresPassword,
+		"-p", "127.0.0.1::5432",
+		postgresImage).Output()
+	require.NoError(t, err)
+	containerID := strings.TrimSpace(string(output))
+	t.Cleanup(func() { exec.Command("docker", "rm", "-f", containerID).Run() })
+
+	output, err = exec.Command("docker", "port", containerID, "5432/tcp").Output()
+	require.NoError(t, err)
+	// Вывод вида "127.0.0.1:49153"
+	address := strings.TrimSpace(strings.Split(string(output), "\n")[0])
+	return address[strings.LastIndex(address, ":")+1:]
+}
+
+// connect ждет готовности Postgres и подключается к нему под ролью user
+func connect(t *testing.T, port, user, password string) *gorm.DB {
+	dsn := fmt.Sprintf("host=127.0.0.1 port=%s user=%s password=%s dbname=postgres sslmode=disable", port, user, password)
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+		if err == nil {
+			sqlDB, _ := db.DB()
+			if err = sqlDB.Ping(); err == nil {
+				t.Cleanup(func() { sqlDB.Close() })
+				return db
+			}
+			sqlDB.Close()
+		}
+		if time.Now().After(deadline) {
+			require.NoError(t, err, "postgres did not become ready")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// setupRowLevelSecurity применяет схему и миграции владельцем таблиц и возвращает
+// подключение приложения под отдельной ролью, которой политики RLS касаются
+func setupRowLevelSecurity(t *testing.T) (owner, app *gorm.DB) {
+	port := startPostgres(t)
+	owner = connect(t, port, "postgres", postgresPassword)
+
+	require.NoError(t, owner.AutoMigrate(&entity.Tenant{}, &entity.User{}, &entity.UserAuditEntry{}, &entity.UserVersion{}))
+	require.NoError(t, migrations.Run(owner, migrations.All))
+	for _, statement := range []string{
+		fmt.Sprintf("CREATE ROLE %s LOGIN PASSWORD '%s'", appRole, appPassword),
+		fmt.Sprintf("GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO %s", appRole),
+		fmt.Sprintf("GRANT USAGE ON ALL SEQUENCES IN SCHEMA public TO %s", appRole),
+	} {
+		require.NoError(t, owner.Exec(statement).Error)
+	}
+	require.NoError(t, owner.Create(&entity.Tenant{Slug: "acme", Name: "Acme"}).Error)
+
+	return owner, connect(t, port, appRole, appPassword)
+}
+
+// inTenant выполняет fn в транзакции с тенантом в переменной сессии, как это
+// делает репозиторий в режиме RowLevelSecurity
+func inTenant(db *gorm.DB, tenantID uint, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config(?, ?, true)", tenancy.SessionSetting, fmt.Sprint(tenantID)).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+func TestRowLevelSecurity(t *testing.T) {
+	owner, app := setupRowLevelSecurity(t)
+
+	var acme entity.Tenant
+	require.NoError(t, owner.Where("slug = ?", "acme").First(&acme).Error)
+	defaultCtx := tenancy.WithTenant(context.Background(), entity.DefaultTenantID)
+	acmeCtx := tenancy.WithTenant(context.Background(), acme.ID)
+
+	userRepo := repository.NewUserRepository(app)
+	userRepo.RowLevelSecurity = true
+	userRepo.Events = repository.EventRecorders{audit.NewRecorder(), audit.NewVersionRecorder()}
+
+	alice, err := entity.NewUser("alice", "alice@example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, userRepo.Create(defaultCtx, alice))
+	bob, err := entity.NewUser("bob", "bob@example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, userRepo.Create(acmeCtx, bob))
+
+	t.Run("Repository reads own tenant", func(t *testing.T) {
+		found, err := userRepo.FindByID(acmeCtx, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "bob", found.Username)
+
+		history, err := userRepo.ListHistory(acmeCtx, bob.ID, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, history, 1)
+	})
+
+	t.Run("Query without tenant context returns nothing", func(t *testing.T) {
+		for _, table := range []string{"users", "user_versions", "user_audit_entries"} {
+			var count int64
+			require.NoError(t, app.Table(table).Count(&count).Error)
+			assert.Zero(t, count, table)
+		}
+
+		// Переменная транзакции не переживает ее завершения
+		var count int64
+		require.NoError(t, inTenant(app, acme.ID, func(tx *gorm.DB) error {
+			return tx.Table("users").Count(&count).Error
+		}))
+		assert.Equal(t, int64(1), count)
+		require.NoError(t, app.Table("users").Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Query with another tenant sees only its rows", func(t *testing.T) {
+		var usernames []string
+		require.NoError(t, inTenant(app, acme.ID, func(tx *gorm.DB) error {
+			// Условия на tenant_id нет: строки отбирает только политика
+			return tx.Table("users").Order("id").Pluck("username", &usernames).Error
+		}))
+		assert.Equal(t, []string{"bob"}, usernames)
+	})
+
+	t.Run("Cannot write rows of another tenant", func(t *testing.T) {
+		err := inTenant(app, acme.ID, func(tx *gorm.DB) error {
+			mallory, err := entity.NewUser("mallory", "mallory@example.com", nil)
+			if err != nil {
+				return err
+			}
+			mallory.TenantID = entity.DefaultTenantID
+			return tx.Create(mallory).Error
+		})
+		assert.Error(t, err)
+
+		result := inTenant(app, acme.ID, func(tx *gorm.DB) error {
+			update := tx.Table("users").Where("id = ?", alice.ID).Update("display_name", "Mallory")
+			if update.Error == nil && update.RowsAffected != 0 {
+				return fmt.Errorf("updated %d rows of another tenant", update.RowsAffected)
+			}
+			return update.Error
+		})
+		assert.NoError(t, result)
+	})
+
+	t.Run("Owner is not subject to policies", func(t *testing.T) {
+		var count int64
+		require.NoError(t, owner.Table("users").Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+	})
+}